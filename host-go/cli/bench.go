@@ -0,0 +1,338 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/runtimes"
+	"github.com/sourcenetwork/immutable/enumerable"
+)
+
+var benchCommand = &command{
+	name:    "bench",
+	args:    "<lensfile> <dataset>",
+	summary: "Measures the throughput of the lens file on each runtime, and the time spent in each of its lenses.",
+	nArgs:   2,
+	setup: func(fs *flag.FlagSet) runFunc {
+		iterations := fs.Int("n", 10, "the `number` of times the dataset is run through the lens file on each runtime")
+		runtimeNames := fs.String("runtime", "",
+			fmt.Sprintf("a comma separated list of the `names` of the runtimes to measure, defaults to all of %s",
+				strings.Join(runtimes.Names(), ", ")))
+		inputFormat := fs.String("input-format", formatJSONArray, "the `format` of the dataset, json-array or ndjson")
+		asJson := fs.Bool("json", false, "write the results as json")
+		return func(e *env, args []string) error {
+			names := runtimes.Names()
+			if *runtimeNames != "" {
+				names = strings.Split(*runtimeNames, ",")
+			}
+			if *iterations < 1 {
+				return newCliError(stageUsage, fmt.Errorf("-n must be at least 1, got %d", *iterations))
+			}
+			return bench(e, args[0], args[1], *inputFormat, names, *iterations, *asJson)
+		}
+	},
+}
+
+// benchResult holds the measurements of a lens file on a single runtime.
+type benchResult struct {
+	Runtime    string `json:"runtime"`
+	Iterations int    `json:"iterations"`
+	// Items is the number of items in the dataset.
+	Items          int     `json:"items"`
+	ItemsPerSecond float64 `json:"itemsPerSecond"`
+	// The percentiles of the time taken to yield each result item.
+	P50Nanos int64 `json:"p50Nanos"`
+	P99Nanos int64 `json:"p99Nanos"`
+	// HostTimeShare is the share of the time spent outside of the lenses, such as copying items
+	// between them.
+	HostTimeShare float64       `json:"hostTimeShare"`
+	Stages        []stageResult `json:"stages"`
+}
+
+// stageResult holds the measurements of a single lens of a lens file.
+type stageResult struct {
+	Index  int    `json:"index"`
+	Module string `json:"module"`
+	// TimeShare is the share of the time spent within the lens, excluding the time spent waiting
+	// on the lenses before it.
+	TimeShare float64 `json:"timeShare"`
+	// AllocsPerRun is the number of calls made to the alloc export of the lens per run.
+	AllocsPerRun int64 `json:"allocsPerRun"`
+	// PeakMemory is the largest size in bytes reached by the memory of the lens.
+	PeakMemory int64 `json:"peakMemory"`
+}
+
+// stageStats accumulates the measurements of a single lens across runs.
+type stageStats struct {
+	module     string
+	time       time.Duration
+	allocs     int64
+	peakMemory int64
+}
+
+// observe returns the given instance wrapped so that its calls are recorded in the stats.
+func (s *stageStats) observe(instance module.Instance) module.Instance {
+	observed := instance
+	observed.Alloc = func(size module.MemSize) (module.MemSize, error) {
+		s.allocs++
+		return instance.Alloc(size)
+	}
+	observed.Transform = func(next func() module.MemSize) (module.MemSize, error) {
+		// The time spent pulling items from the previous lens is excluded.
+		var waiting time.Duration
+		start := time.Now()
+		index, err := instance.Transform(func() module.MemSize {
+			nextStart := time.Now()
+			defer func() {
+				waiting += time.Since(nextStart)
+			}()
+			return next()
+		})
+		s.time += time.Since(start) - waiting
+
+		if memory, ok := instance.Memory().(module.SizedMemory); ok {
+			s.peakMemory = max(s.peakMemory, memory.Size())
+		}
+		return index, err
+	}
+	return observed
+}
+
+func bench(
+	e *env,
+	lensFilePath string,
+	datasetPath string,
+	inputFormat string,
+	runtimeNames []string,
+	iterations int,
+	asJson bool,
+) error {
+	items, err := readDataset(datasetPath, inputFormat)
+	if err != nil {
+		return err
+	}
+
+	results := make([]benchResult, 0, len(runtimeNames))
+	for _, name := range runtimeNames {
+		result, err := benchRuntime(e, lensFilePath, items, name, iterations)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+
+	if asJson {
+		encoder := json.NewEncoder(e.stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(results)
+	}
+	return writeBenchResults(e, results)
+}
+
+// readDataset reads all of the items of the dataset at the given path.
+func readDataset(path string, format string) ([]map[string]any, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, newCliError(stageInput, err)
+	}
+	defer file.Close()
+
+	src, err := newSource(file, format)
+	if err != nil {
+		return nil, newCliError(stageUsage, err)
+	}
+
+	inputError := func(index int, err error) error {
+		cliErr := newCliError(stageInput, fmt.Errorf("%s: %w", path, err))
+		cliErr.item = &index
+		return cliErr
+	}
+
+	items := []map[string]any{}
+	for {
+		hasNext, err := src.Next()
+		if err != nil {
+			return nil, inputError(src.count, err)
+		}
+		if !hasNext {
+			return items, nil
+		}
+
+		item, err := src.Value()
+		if err != nil {
+			// The item has already been counted.
+			index := src.count - 1
+			return nil, inputError(index, fmt.Errorf("reading input item %d: %w", index, err))
+		}
+		items = append(items, item)
+	}
+}
+
+// benchRuntime runs the given items through the lens file the given number of times on the named
+// runtime.
+//
+// The modules are compiled once, before the first run, and only the time taken to enumerate the
+// results of each run is measured.
+func benchRuntime(
+	e *env,
+	lensFilePath string,
+	items []map[string]any,
+	runtimeName string,
+	iterations int,
+) (benchResult, error) {
+	runtime, err := runtimes.New(runtimeName, e.runtimeOptions()...)
+	if err != nil {
+		return benchResult{}, newCliError(stageConfig, err)
+	}
+
+	stages := []*stageStats{}
+	hook := func(index int, moduleName string, instance module.Instance) module.Instance {
+		if index == len(stages) {
+			stages = append(stages, &stageStats{module: moduleName})
+		}
+		return stages[index].observe(instance)
+	}
+	opts := append(e.options(), config.WithInstanceHook(hook))
+
+	modulesByPath := map[string]module.Module{}
+	latencies := []time.Duration{}
+	var total time.Duration
+	for i := 0; i < iterations; i++ {
+		results, err := config.LoadIntoFromFile[map[string]any, map[string]any](
+			runtime,
+			modulesByPath,
+			lensFilePath,
+			enumerable.New(items),
+			opts...,
+		)
+		if err != nil {
+			return benchResult{}, loadError(err)
+		}
+
+		runStart := time.Now()
+		for {
+			start := time.Now()
+			hasNext, err := results.Next()
+			if err == nil && hasNext {
+				_, err = results.Value()
+			}
+			if err != nil {
+				return benchResult{}, newCliError(stageTransform, fmt.Errorf("%s: %w", runtimeName, err))
+			}
+			if !hasNext {
+				break
+			}
+			latencies = append(latencies, time.Since(start))
+		}
+		total += time.Since(runStart)
+	}
+
+	result := benchResult{
+		Runtime:    runtimeName,
+		Iterations: iterations,
+		Items:      len(items),
+		Stages:     make([]stageResult, len(stages)),
+	}
+	if total > 0 {
+		result.ItemsPerSecond = float64(len(items)*iterations) / total.Seconds()
+	}
+
+	slices.Sort(latencies)
+	result.P50Nanos = percentile(latencies, 0.5).Nanoseconds()
+	result.P99Nanos = percentile(latencies, 0.99).Nanoseconds()
+
+	hostTime := total
+	for i, stage := range stages {
+		hostTime -= stage.time
+		result.Stages[i] = stageResult{
+			Index:        i,
+			Module:       stage.module,
+			TimeShare:    share(stage.time, total),
+			AllocsPerRun: stage.allocs / int64(iterations),
+			PeakMemory:   stage.peakMemory,
+		}
+	}
+	result.HostTimeShare = share(max(hostTime, 0), total)
+
+	return result, nil
+}
+
+// percentile returns the given percentile of the given sorted durations, zero if there are none.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+func share(d time.Duration, total time.Duration) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(d) / float64(total)
+}
+
+func writeBenchResults(e *env, results []benchResult) error {
+	w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Runtime\tItems/s\tp50\tp99")
+	for _, result := range results {
+		fmt.Fprintf(
+			w,
+			"%s\t%.0f\t%s\t%s\n",
+			result.Runtime,
+			result.ItemsPerSecond,
+			time.Duration(result.P50Nanos),
+			time.Duration(result.P99Nanos),
+		)
+	}
+
+	for _, result := range results {
+		fmt.Fprintf(w, "\nStages (%s, %d items x %d runs):\n", result.Runtime, result.Items, result.Iterations)
+		fmt.Fprintln(w, "  Lens\tModule\tTime\tAllocs/run\tPeak memory")
+		for _, stage := range result.Stages {
+			fmt.Fprintf(
+				w,
+				"  %d\t%s\t%.1f%%\t%d\t%s\n",
+				stage.Index,
+				stage.Module,
+				stage.TimeShare*100,
+				stage.AllocsPerRun,
+				formatBytes(stage.PeakMemory),
+			)
+		}
+		fmt.Fprintf(w, "  -\thost\t%.1f%%\t\t\n", result.HostTimeShare*100)
+	}
+
+	return w.Flush()
+}
+
+// formatBytes formats the given number of bytes using binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	size := float64(n)
+	for _, suffix := range []string{"KiB", "MiB", "GiB"} {
+		size /= unit
+		if size < unit || suffix == "GiB" {
+			return fmt.Sprintf("%.1f %s", size, suffix)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchAsJson(t *testing.T) {
+	lensFile := writeWatLensFile(t, "", "")
+	dataset := writeFile(t, "dataset.ndjson", "{\"a\": 1}\n{\"a\": 2}\n{\"a\": 3}\n")
+
+	result := runCli(t, "", "bench", "-n", "2", "-json", "-runtime", "wasmtime,wazero", "-input-format", "ndjson",
+		lensFile, dataset)
+	require.Equal(t, exitOK, result.code, result.stderr)
+
+	var results []benchResult
+	require.NoError(t, json.Unmarshal([]byte(result.stdout), &results))
+	require.Len(t, results, 2)
+
+	for i, runtimeName := range []string{"wasmtime", "wazero"} {
+		result := results[i]
+		assert.Equal(t, runtimeName, result.Runtime)
+		assert.Equal(t, 2, result.Iterations)
+		assert.Equal(t, 3, result.Items)
+		assert.Greater(t, result.ItemsPerSecond, float64(0))
+		assert.LessOrEqual(t, result.P50Nanos, result.P99Nanos)
+
+		require.Len(t, result.Stages, 2)
+		for j, stage := range result.Stages {
+			assert.Equal(t, j, stage.Index)
+			assert.Equal(t, "wat", stage.Module)
+			assert.Greater(t, stage.AllocsPerRun, int64(0))
+			// The identity lens never grows its single page of memory.
+			assert.Equal(t, int64(64*1024), stage.PeakMemory)
+		}
+	}
+}
+
+func TestBench(t *testing.T) {
+	lensFile := writeWatLensFile(t, "")
+	dataset := writeFile(t, "dataset.json", `[{"a": 1}]`)
+
+	result := runCli(t, "", "bench", "-n", "1", "-runtime", "wazero", lensFile, dataset)
+
+	assert.Equal(t, exitOK, result.code, result.stderr)
+	assert.Contains(t, result.stdout, "Stages (wazero, 1 items x 1 runs):")
+	assert.Contains(t, result.stdout, "  Lens  Module  Time")
+	assert.Regexp(t, `\n  0     wat     [0-9.]+%\s+[0-9]+\s+64\.0 KiB\n`, result.stdout)
+}
+
+func TestBenchReturnsErrors(t *testing.T) {
+	lensFile := writeFile(t, "lens.json", `{
+		"lenses": [
+			{"path": "builtin:rename", "arguments": {"src": "a", "dst": "b"}}
+		]
+	}`)
+
+	tests := map[string]struct {
+		args         []string
+		dataset      string
+		expectedCode int
+		expected     string
+	}{
+		"iterations": {
+			args:         []string{"-n", "0"},
+			dataset:      `[]`,
+			expectedCode: exitUsage,
+			expected:     "-n must be at least 1, got 0",
+		},
+		"input format": {
+			args:         []string{"-input-format", "csv"},
+			dataset:      `[]`,
+			expectedCode: exitUsage,
+			expected:     `unknown input format "csv"`,
+		},
+		"invalid dataset": {
+			dataset:      `[{"a": 1}, 2]`,
+			expectedCode: exitInput,
+			expected:     "reading input item 1",
+		},
+		"transform error": {
+			args:         []string{"-runtime", "wazero"},
+			dataset:      `[{"b": 1}]`,
+			expectedCode: exitTransform,
+			expected:     "wazero: lenses[0] (builtin:rename): property not found: a",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			args := append([]string{"bench"}, test.args...)
+			result := runCli(t, "", append(args, lensFile, writeFile(t, "dataset.json", test.dataset))...)
+
+			assert.Equal(t, test.expectedCode, result.code)
+			assert.Contains(t, result.stderr, test.expected)
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	durations := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	assert.Equal(t, time.Duration(0), percentile(nil, 0.5))
+	assert.Equal(t, time.Duration(5), percentile(durations, 0.5))
+	assert.Equal(t, time.Duration(9), percentile(durations, 0.99))
+	assert.Equal(t, time.Duration(10), percentile(durations, 1))
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:                   "0 B",
+		1023:                "1023 B",
+		1024:                "1.0 KiB",
+		64 * 1024:           "64.0 KiB",
+		3 * 1024 * 1024 / 2: "1.5 MiB",
+		5 << 40:             "5120.0 GiB",
+	}
+
+	for n, expected := range tests {
+		assert.Equal(t, expected, formatBytes(n))
+	}
+}
@@ -0,0 +1,154 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lens-vm/lens/host-go/config"
+)
+
+// The exit codes of the cli, each identifying the stage at which it failed.
+const (
+	exitOK = 0
+	// exitError is returned for errors that do not belong to any other stage, such as failing
+	// to write the output.
+	exitError     = 1
+	exitUsage     = 2
+	exitConfig    = 3
+	exitModule    = 4
+	exitTransform = 5
+	exitInput     = 6
+)
+
+// The stages at which the cli may fail, reported alongside structured errors.
+const (
+	stageError     = "error"
+	stageUsage     = "usage"
+	stageConfig    = "config"
+	stageModule    = "module"
+	stageTransform = "transform"
+	stageInput     = "input"
+)
+
+var exitCodes = map[string]int{
+	stageError:     exitError,
+	stageUsage:     exitUsage,
+	stageConfig:    exitConfig,
+	stageModule:    exitModule,
+	stageTransform: exitTransform,
+	stageInput:     exitInput,
+}
+
+// The formats in which errors may be written to stderr.
+const (
+	errorsText = "text"
+	errorsJSON = "json"
+)
+
+// cliError is an error that occurred at a specific stage of a command.
+type cliError struct {
+	stage string
+	// lens is the index of the lens module at fault, if known.
+	lens *int
+	// module is the name or path of the lens module at fault, if known.
+	module string
+	// item is the index of the input item at fault, if known.
+	item *int
+	// itemsRead is the number of input items that had been read when a transform failed.
+	//
+	// The item being transformed is not known, as lenses may read ahead or hold on to items, it
+	// is at most the last item read.
+	itemsRead *int
+	err       error
+
+	// reported is true if the error has already been written to stderr.
+	reported bool
+}
+
+func (e *cliError) Error() string {
+	return e.err.Error()
+}
+
+func (e *cliError) Unwrap() error {
+	return e.err
+}
+
+// errorReport is the structured form of a cliError, written to stderr when errors are formatted
+// as json.
+type errorReport struct {
+	Stage     string `json:"stage"`
+	Lens      *int   `json:"lens,omitempty"`
+	Module    string `json:"module,omitempty"`
+	Item      *int   `json:"item,omitempty"`
+	ItemsRead *int   `json:"itemsRead,omitempty"`
+	Message   string `json:"message"`
+}
+
+// newCliError returns an error at the given stage, errors attributed to a specific lens module
+// being reported as such.
+func newCliError(stage string, err error) *cliError {
+	cliErr := &cliError{stage: stage, err: err}
+
+	var stageErr *config.StageError
+	if errors.As(err, &stageErr) {
+		cliErr.lens = &stageErr.Index
+		cliErr.module = stageErr.Module
+	}
+	return cliErr
+}
+
+// loadError returns an error raised whilst loading a lens file, errors raised by a specific lens
+// module are reported as module errors, all others as config errors.
+func loadError(err error) *cliError {
+	if errors.As(err, new(*config.StageError)) {
+		return newCliError(stageModule, err)
+	}
+	return newCliError(stageConfig, err)
+}
+
+// transformError returns an error raised whilst transforming the items read from the given
+// source, errors raised reading the source are reported as input errors.
+//
+// Only input errors identify the item at fault, transform errors report the number of items read
+// instead.
+func transformError(src *source, err error) *cliError {
+	if src.err != nil {
+		cliErr := newCliError(stageInput, src.err)
+		cliErr.item = &src.count
+		return cliErr
+	}
+
+	cliErr := newCliError(stageTransform, err)
+	cliErr.itemsRead = &src.count
+	return cliErr
+}
+
+// report writes the given error to stderr in the format declared by the flags.
+func (e *env) report(command string, err *cliError) {
+	if e.errorsFormat != errorsJSON {
+		fmt.Fprintf(e.stderr, "lens %s: %v\n", command, err)
+		return
+	}
+
+	message := err.Error()
+	var stageErr *config.StageError
+	if errors.As(err, &stageErr) {
+		// The lens module is reported separately.
+		message = stageErr.Err.Error()
+	}
+
+	report, _ := json.Marshal(errorReport{
+		Stage:     err.stage,
+		Lens:      err.lens,
+		Module:    err.module,
+		Item:      err.item,
+		ItemsRead: err.itemsRead,
+		Message:   message,
+	})
+	fmt.Fprintf(e.stderr, "%s\n", report)
+}
@@ -0,0 +1,55 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunReportsErrorsAsJson(t *testing.T) {
+	lensFile := writeFile(t, "lens.json", `{
+		"lenses": [
+			{"path": "builtin:filter-eq", "arguments": {"field": "keep", "value": true}},
+			{"path": "builtin:expr", "arguments": {"expression": "{a: number(.a)}"}}
+		]
+	}`)
+
+	tests := map[string]struct {
+		input        string
+		expectedCode int
+		expected     string
+	}{
+		"transform error": {
+			input:        `[{"keep": false, "a": "x"}, {"keep": true, "a": "1"}, {"keep": true, "a": "x"}]`,
+			expectedCode: exitTransform,
+			expected: `{"stage":"transform","lens":1,"module":"builtin:expr","itemsRead":3,` +
+				`"message":"number: cannot convert \"x\" to number"}`,
+		},
+		"input error": {
+			input:        `[{"keep": true, "a": "1"}, 7]`,
+			expectedCode: exitInput,
+			expected: `{"stage":"input","item":1,` +
+				`"message":"reading input item 1: json: cannot unmarshal number into Go value of type map[string]interface {}"}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := runCli(t, test.input, "run", "-errors", "json", lensFile)
+
+			assert.Equal(t, test.expectedCode, result.code)
+			assert.JSONEq(t, test.expected, result.stderr)
+		})
+	}
+}
+
+func TestRunReportsErrorsAsText(t *testing.T) {
+	result := runCli(t, `[`, "run", writeFile(t, "lens.json", `{"lenses": []}`))
+
+	assert.Equal(t, exitInput, result.code)
+	assert.Equal(t, "lens run: reading input item 0: unexpected end of JSON input\n", result.stderr)
+}
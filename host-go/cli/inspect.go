@@ -0,0 +1,85 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/lens-vm/lens/host-go/engine"
+)
+
+var inspectCommand = &command{
+	name:    "inspect",
+	args:    "<module>",
+	summary: "Describes the imports, exports, memory and metadata sections of a wasm module.",
+	nArgs:   1,
+	setup: func(fs *flag.FlagSet) runFunc {
+		asJson := fs.Bool("json", false, "write the description as json")
+		return func(e *env, args []string) error {
+			return inspect(e, args[0], *asJson)
+		}
+	},
+}
+
+// lensExports are the exports through which the host interacts with a lens module.
+var lensExports = []string{"memory", "alloc", "transform", "inverse", "set_param"}
+
+func inspect(e *env, path string, asJson bool) error {
+	content, err := engine.ReadModule(path, e.moduleOptions()...)
+	if err != nil {
+		return err
+	}
+
+	info, err := parseWasm(content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if asJson {
+		encoder := json.NewEncoder(e.stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(info)
+	}
+
+	w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Lens exports:")
+	for _, name := range lensExports {
+		status := "missing"
+		if _, ok := info.export(name); ok {
+			status = "present"
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", name, status)
+	}
+
+	fmt.Fprintln(w, "\nImports:")
+	for _, imp := range info.Imports {
+		fmt.Fprintf(w, "  %s.%s\t%s\t%s\n", imp.Module, imp.Name, imp.Kind, imp.Type)
+	}
+
+	fmt.Fprintln(w, "\nExports:")
+	for _, export := range info.Exports {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", export.Name, export.Kind, export.Type)
+	}
+
+	fmt.Fprintln(w, "\nMemory:")
+	for _, memory := range info.Memories {
+		fmt.Fprintf(w, "  %s\n", memory)
+	}
+
+	fmt.Fprintln(w, "\nCustom sections:")
+	for _, section := range info.CustomSections {
+		fmt.Fprintf(w, "  %s\t%d bytes\n", section.Name, section.Size)
+		if section.Content != "" {
+			fmt.Fprintf(w, "    %s\n", section.Content)
+		}
+	}
+
+	return w.Flush()
+}
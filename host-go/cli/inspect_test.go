@@ -0,0 +1,83 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go/v21"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	wasmPath := writeWasm(t, identityWat)
+
+	result := runCli(t, "", "inspect", wasmPath)
+
+	assert.Equal(t, exitOK, result.code, result.stderr)
+	assert.Equal(t, `Lens exports:
+  memory     present
+  alloc      present
+  transform  present
+  inverse    missing
+  set_param  missing
+
+Imports:
+  lens.next  func  () -> (i32)
+
+Exports:
+  memory     memory  
+  alloc      func    (i32) -> (i32)
+  transform  func    () -> (i32)
+
+Memory:
+  min 1 pages
+
+Custom sections:
+  name  34 bytes
+`, result.stdout)
+}
+
+func TestInspectAsJson(t *testing.T) {
+	wasmPath := writeWasm(t, identityWat)
+
+	result := runCli(t, "", "inspect", "-json", wasmPath)
+	assert.Equal(t, exitOK, result.code, result.stderr)
+
+	var info wasmInfo
+	require.NoError(t, json.Unmarshal([]byte(result.stdout), &info))
+	assert.Equal(t, []wasmImport{{Module: "lens", Name: "next", Kind: "func", Type: "() -> (i32)"}}, info.Imports)
+	assert.Equal(t, []wasmExport{
+		{Name: "memory", Kind: "memory"},
+		{Name: "alloc", Kind: "func", Type: "(i32) -> (i32)"},
+		{Name: "transform", Kind: "func", Type: "() -> (i32)"},
+	}, info.Exports)
+	assert.Equal(t, []wasmLimits{{Min: 1}}, info.Memories)
+}
+
+func TestInspectReturnsErrorGivenInvalidModule(t *testing.T) {
+	path := writeFile(t, "lens.wasm", "(module)")
+
+	result := runCli(t, "", "inspect", path)
+
+	assert.Equal(t, exitError, result.code)
+	assert.Equal(t, "lens inspect: "+path+": not a wasm binary\n", result.stderr)
+}
+
+// writeWasm writes the binary form of the given wat module to a new temporary file, returning
+// its path.
+func writeWasm(t *testing.T, wat string) string {
+	wasm, err := wasmtime.Wat2Wasm(wat)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "lens.wasm")
+	require.NoError(t, os.WriteFile(path, wasm, 0o600))
+	return path
+}
@@ -0,0 +1,48 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"flag"
+
+	"github.com/lens-vm/lens/host-go/config"
+)
+
+var invertCommand = &command{
+	name:    "invert",
+	args:    "<lensfile>",
+	summary: "Writes the inverse of the lens file to stdout.",
+	nArgs:   1,
+	setup: func(fs *flag.FlagSet) runFunc {
+		format := fs.String("format", "", "the `format` of the output, json, yaml or toml, defaults to that of the lens file")
+		return func(e *env, args []string) error {
+			return invert(e, args[0], config.Format(*format))
+		}
+	},
+}
+
+// invert writes the inverse of the given lens file in the given format, or that of the lens file
+// if unknown.
+func invert(e *env, lensFilePath string, format config.Format) error {
+	lens, err := config.LoadFile(lensFilePath)
+	if err != nil {
+		return err
+	}
+
+	if format == config.FormatUnknown {
+		format = config.FormatOf(lensFilePath)
+	}
+	if format == config.FormatUnknown {
+		format = config.FormatJSON
+	}
+
+	content, err := config.Marshal(config.Inverse(lens), format)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.stdout.Write(content)
+	return err
+}
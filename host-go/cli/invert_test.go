@@ -0,0 +1,94 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvert(t *testing.T) {
+	lensFile := writeFile(t, "lens.yaml", `
+lenses:
+  - path: builtin:rename
+    arguments:
+      src: a
+      dst: b
+  - path: builtin:copy
+    inverse: true
+    arguments:
+      src: b
+      dst: c
+`)
+
+	tests := map[string]struct {
+		args     []string
+		expected string
+	}{
+		"format of the lens file": {
+			expected: `lenses:
+    - arguments:
+        dst: c
+        src: b
+      path: builtin:copy
+    - arguments:
+        dst: b
+        src: a
+      inverse: true
+      path: builtin:rename
+`,
+		},
+		"json": {
+			args: []string{"-format", "json"},
+			expected: `{
+	"lenses": [
+		{
+			"arguments": {
+				"dst": "c",
+				"src": "b"
+			},
+			"path": "builtin:copy"
+		},
+		{
+			"arguments": {
+				"dst": "b",
+				"src": "a"
+			},
+			"inverse": true,
+			"path": "builtin:rename"
+		}
+	]
+}
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			args := append([]string{"invert"}, test.args...)
+			result := runCli(t, "", append(args, lensFile)...)
+
+			assert.Equal(t, exitOK, result.code, result.stderr)
+			assert.Equal(t, test.expected, result.stdout)
+		})
+	}
+}
+
+func TestInvertedLensFileRestoresItems(t *testing.T) {
+	lensFile := writeFile(t, "lens.json", `{
+		"lenses": [
+			{"path": "builtin:rename", "arguments": {"src": "a", "dst": "b"}},
+			{"path": "builtin:copy", "arguments": {"src": "b", "dst": "c"}}
+		]
+	}`)
+
+	inverted := runCli(t, "", "invert", lensFile)
+	assert.Equal(t, exitOK, inverted.code, inverted.stderr)
+
+	result := runCli(t, `[{"b": 1, "c": 1}]`, "run", writeFile(t, "inverse.json", inverted.stdout))
+	assert.Equal(t, exitOK, result.code, result.stderr)
+	assert.Equal(t, `[{"a":1}]`, result.stdout)
+}
@@ -5,57 +5,214 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 
 	"github.com/lens-vm/lens/host-go/config"
-	"github.com/sourcenetwork/immutable/enumerable"
+	"github.com/lens-vm/lens/host-go/engine"
+	"github.com/lens-vm/lens/host-go/engine/cache"
+	"github.com/lens-vm/lens/host-go/runtimes"
 )
 
+// command is a subcommand of the cli.
+type command struct {
+	name string
+	// args describes the positional arguments of the command.
+	args    string
+	summary string
+	// nArgs is the number of positional arguments required by the command.
+	nArgs int
+	// setup registers any flags specific to the command, returning the function running it.
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+// runFunc runs a command with the given positional arguments.
+type runFunc func(e *env, args []string) error
+
+var commands = []*command{
+	runCommand,
+	validateCommand,
+	inspectCommand,
+	invertCommand,
+	benchCommand,
+	testCommand,
+}
+
+// env holds the flags shared by all of the commands, and the streams they should use.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	cacheDir string
+	offline  bool
+	// errorsFormat is the format in which errors are written to stderr.
+	errorsFormat string
+}
+
 func main() {
-	lensFilePath := os.Args[1]
+	e := &env{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+	os.Exit(e.main(os.Args[1:]))
+}
 
-	dataBytes, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		panic(err)
+// main runs the command declared by the given arguments, returning the exit code.
+func (e *env) main(args []string) int {
+	if len(args) == 0 {
+		e.usage()
+		return exitUsage
 	}
 
-	var data []map[string]any
-	err = json.Unmarshal(dataBytes, &data)
-	if err != nil {
-		panic(err)
+	name := args[0]
+	if name == "-h" || name == "-help" || name == "--help" || name == "help" {
+		e.usage()
+		return exitOK
+	}
+
+	cmd := findCommand(name)
+	if cmd != nil {
+		args = args[1:]
+	} else {
+		// A lens file given without a command is run, as was the only option before there
+		// were commands.
+		cmd = runCommand
 	}
 
-	src := enumerable.New(data)
-	result, err := config.LoadFromFile[map[string]any, map[string]any](lensFilePath, src)
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: lens %s [flags] %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	e.register(fs)
+	run := cmd.setup(fs)
+
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
 	if err != nil {
-		panic(err)
+		return exitUsage
+	}
+	if fs.NArg() != cmd.nArgs {
+		fs.Usage()
+		return exitUsage
+	}
+	if e.errorsFormat != errorsText && e.errorsFormat != errorsJSON {
+		fmt.Fprintf(e.stderr, "invalid value %q for flag -errors, expected text or json\n", e.errorsFormat)
+		return exitUsage
+	}
+	if e.offline && e.cacheDir == "" {
+		// Remote modules can only be served offline from the cache.
+		fmt.Fprintf(e.stderr, "flag -offline requires a cache directory, set -cache-dir or $LENS_CACHE_DIR\n")
+		return exitUsage
 	}
 
-	results := []any{}
-	for {
-		hasNext, err := result.Next()
-		if err != nil {
-			panic(err)
-		}
+	err = run(e, fs.Args())
+	if err == nil {
+		return exitOK
+	}
 
-		if !hasNext {
-			break
-		}
+	var cliErr *cliError
+	if !errors.As(err, &cliErr) {
+		cliErr = newCliError(stageError, err)
+	}
+	if !cliErr.reported {
+		e.report(cmd.name, cliErr)
+	}
+	return exitCodes[cliErr.stage]
+}
 
-		val, err := result.Value()
-		if err != nil {
-			panic(err)
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
 		}
+	}
+	return nil
+}
 
-		results = append(results, val)
+func (e *env) usage() {
+	fmt.Fprintf(e.stderr, "Usage: lens <command> [flags] [args]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(e.stderr, "  %-10s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintf(e.stderr, "\nA lens file given without a command is run. Use \"lens <command> --help\" for more information.\n")
+	fmt.Fprintf(e.stderr, "\nExit codes:\n")
+	fmt.Fprintf(e.stderr, "  %d  usage error\n", exitUsage)
+	fmt.Fprintf(e.stderr, "  %d  invalid lens file\n", exitConfig)
+	fmt.Fprintf(e.stderr, "  %d  lens module failed to load\n", exitModule)
+	fmt.Fprintf(e.stderr, "  %d  lens module failed to transform an item\n", exitTransform)
+	fmt.Fprintf(e.stderr, "  %d  invalid input\n", exitInput)
+	fmt.Fprintf(e.stderr, "  %d  any other error\n", exitError)
+}
 
-	resultJson, err := json.Marshal(results)
-	if err != nil {
-		panic(err)
+// register registers the flags shared by all of the commands.
+func (e *env) register(fs *flag.FlagSet) {
+	offline, _ := strconv.ParseBool(os.Getenv("LENS_OFFLINE"))
+
+	fs.StringVar(&e.cacheDir, "cache-dir", os.Getenv("LENS_CACHE_DIR"),
+		"the `directory` in which remote modules and compiled code are cached, defaults to $LENS_CACHE_DIR")
+	fs.BoolVar(&e.offline, "offline", offline,
+		"only serve remote modules from the cache, which requires -cache-dir, defaults to $LENS_OFFLINE")
+	fs.StringVar(&e.errorsFormat, "errors", errorsText,
+		"the `format` in which errors are written to stderr, text or json")
+}
+
+// runtimeOptions returns the runtime options, persisting compiled module code within the cache
+// directory if one is provided.
+//
+// The runtime itself may be selected using the `LENS_RUNTIME` environment variable.
+func (e *env) runtimeOptions() []runtimes.Option {
+	if e.cacheDir == "" {
+		return nil
+	}
+	return []runtimes.Option{runtimes.WithCacheDir(filepath.Join(e.cacheDir, "compiled"))}
+}
+
+// moduleCache returns the cache through which remote modules should be fetched, nil if there is
+// no cache directory.
+func (e *env) moduleCache() *cache.Cache {
+	if e.cacheDir == "" {
+		return nil
 	}
 
-	os.Stdout.WriteString(string(resultJson))
+	moduleCache := cache.New(filepath.Join(e.cacheDir, "modules"))
+	moduleCache.Offline = e.offline
+	return moduleCache
+}
+
+// options returns the config options declared by the flags.
+//
+// Errors are traced to the lens module raising them so that they can be reported as such. If a
+// cache directory is set, remote modules will be cached within it. If offline is also set, remote
+// modules will only be served from the cache.
+func (e *env) options() []config.Option {
+	opts := []config.Option{
+		config.WithErrorTrace(),
+		config.WithRuntimeOptions(e.runtimeOptions()...),
+	}
+
+	moduleCache := e.moduleCache()
+	if moduleCache == nil {
+		return opts
+	}
+	return append(opts, config.WithCache(moduleCache))
+}
+
+// moduleOptions returns the options with which modules should be read.
+func (e *env) moduleOptions() []engine.ModuleOption {
+	moduleCache := e.moduleCache()
+	if moduleCache == nil {
+		return nil
+	}
+	return []engine.ModuleOption{engine.WithCache(moduleCache)}
 }
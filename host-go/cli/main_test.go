@@ -0,0 +1,106 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMainReturnsExitCodes(t *testing.T) {
+	lensFile := writeFile(t, "lens.json", `{
+		"lenses": [
+			{"path": "builtin:rename", "arguments": {"src": "a", "dst": "b"}}
+		]
+	}`)
+
+	tests := map[string]struct {
+		args         []string
+		stdin        string
+		expectedCode int
+		expected     string
+	}{
+		"no arguments": {
+			expectedCode: exitUsage,
+			expected:     "Usage: lens <command> [flags] [args]",
+		},
+		"help": {
+			args:         []string{"help"},
+			expectedCode: exitOK,
+			expected:     "Usage: lens <command> [flags] [args]",
+		},
+		"command help": {
+			args:         []string{"run", "-help"},
+			expectedCode: exitOK,
+			expected:     "Usage: lens run [flags] <lensfile>",
+		},
+		"unknown flag": {
+			args:         []string{"run", "-nope", lensFile},
+			expectedCode: exitUsage,
+			expected:     "flag provided but not defined: -nope",
+		},
+		"missing argument": {
+			args:         []string{"validate"},
+			expectedCode: exitUsage,
+			expected:     "Usage: lens validate [flags] <lensfile>",
+		},
+		"too many arguments": {
+			args:         []string{"run", lensFile, lensFile},
+			expectedCode: exitUsage,
+			expected:     "Usage: lens run [flags] <lensfile>",
+		},
+		"invalid errors format": {
+			args:         []string{"run", "-errors", "xml", lensFile},
+			expectedCode: exitUsage,
+			expected:     `invalid value "xml" for flag -errors, expected text or json`,
+		},
+		"offline without a cache directory": {
+			args:         []string{"run", "-cache-dir", "", "-offline", lensFile},
+			expectedCode: exitUsage,
+			expected:     "flag -offline requires a cache directory, set -cache-dir or $LENS_CACHE_DIR",
+		},
+		"missing lens file": {
+			args:         []string{"run", "missing.json"},
+			stdin:        `[]`,
+			expectedCode: exitConfig,
+			expected:     "lens run: open missing.json: no such file or directory",
+		},
+		"invalid input": {
+			args:         []string{"run", lensFile},
+			stdin:        `[{"a": 1}, {`,
+			expectedCode: exitInput,
+			expected:     "lens run: reading input item 1: unexpected EOF",
+		},
+		"transform error": {
+			args:         []string{"run", lensFile},
+			stdin:        `[{"b": 1}]`,
+			expectedCode: exitTransform,
+			expected:     "lens run: lenses[0] (builtin:rename): property not found: a",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := runCli(t, test.stdin, test.args...)
+
+			assert.Equal(t, test.expectedCode, result.code)
+			assert.Contains(t, result.stderr, test.expected)
+		})
+	}
+}
+
+func TestMainRunsLensFileGivenNoCommand(t *testing.T) {
+	lensFile := writeFile(t, "lens.json", `{
+		"lenses": [
+			{"path": "builtin:rename", "arguments": {"src": "a", "dst": "b"}}
+		]
+	}`)
+
+	result := runCli(t, `[{"a": 1}]`, lensFile)
+
+	assert.Equal(t, exitOK, result.code, result.stderr)
+	assert.Equal(t, `[{"b":1}]`, result.stdout)
+}
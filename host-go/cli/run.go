@@ -0,0 +1,225 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/runtimes"
+)
+
+var runCommand = &command{
+	name:    "run",
+	args:    "<lensfile>",
+	summary: "Applies the lens file to the items read from stdin, writing the results to stdout.",
+	nArgs:   1,
+	setup: func(fs *flag.FlagSet) runFunc {
+		flags := &runFlags{}
+		fs.StringVar(&flags.inputFormat, "input-format", formatJSONArray, "the `format` of the input, json-array or ndjson")
+		fs.StringVar(&flags.outputFormat, "output-format", formatJSONArray, "the `format` of the output, json-array or ndjson")
+		fs.BoolVar(&flags.pretty, "pretty", false, "indent the json-array output")
+		fs.StringVar(&flags.runtime, "runtime", "",
+			fmt.Sprintf("the `name` of the runtime hosting the lens modules, one of %s, defaults to $%s",
+				strings.Join(runtimes.Names(), ", "), runtimes.EnvRuntime))
+		fs.BoolVar(&flags.inverse, "inverse", false, "apply the lens file backwards, inversing each of its lenses in reverse order")
+		fs.Func("set",
+			"`lens[index].arguments.name=value` overrides an argument of a lens, may be repeated.\n"+
+				"The index is that of the lens once includes are flattened, values that are not valid json are taken as strings",
+			func(s string) error {
+				opt, err := parseArgumentOverride(s)
+				if err != nil {
+					return err
+				}
+				flags.arguments = append(flags.arguments, opt)
+				return nil
+			})
+		fs.Uint64Var(&flags.fuel, "fuel", 0, "limit the number of wasm instructions the lens modules may execute, wasmtime only")
+		fs.DurationVar(&flags.timeout, "timeout", 0, "limit the `duration` of each call into the lens modules, wasmtime and wazero only")
+		return func(e *env, args []string) error {
+			return run(e, args[0], flags)
+		}
+	},
+}
+
+// runFlags holds the flags specific to the run command.
+type runFlags struct {
+	inputFormat  string
+	outputFormat string
+	pretty       bool
+
+	runtime string
+	inverse bool
+	// arguments holds the argument overrides declared by the `-set` flags.
+	arguments []config.Option
+
+	fuel    uint64
+	timeout time.Duration
+}
+
+// runtimeOptions returns the options with which the runtimes hosting the lens modules should be
+// created, failing if the runtime, or any runtime declared by the lens file, does not support the
+// limits declared by the flags.
+func (flags *runFlags) runtimeOptions(e *env, lensFilePath string) ([]runtimes.Option, error) {
+	opts := e.runtimeOptions()
+	if flags.fuel == 0 && flags.timeout == 0 {
+		return opts, nil
+	}
+
+	name := flags.runtime
+	if name == "" {
+		name = os.Getenv(runtimes.EnvRuntime)
+	}
+	if name == "" {
+		// The cli is always built with wasmtime as the default runtime.
+		name = "wasmtime"
+	}
+	err := flags.checkLimits(name)
+	if err != nil {
+		return nil, newCliError(stageUsage, err)
+	}
+
+	// Lens entries may declare their own runtime, which is created with the same options.
+	lensConfig, err := config.LoadFile(lensFilePath, e.options()...)
+	if err != nil {
+		return nil, loadError(err)
+	}
+	lensConfig, err = config.Flatten(lensConfig, filepath.Dir(lensFilePath), e.options()...)
+	if err != nil {
+		return nil, loadError(err)
+	}
+	for i, moduleCfg := range lensConfig.Lenses {
+		if moduleCfg.Runtime == "" {
+			continue
+		}
+		err := flags.checkLimits(moduleCfg.Runtime)
+		if err != nil {
+			return nil, newCliError(stageUsage, fmt.Errorf("lenses[%d]: %w", i, err))
+		}
+	}
+
+	if flags.fuel > 0 {
+		opts = append(opts, runtimes.WithFuel(flags.fuel))
+	}
+	if flags.timeout > 0 {
+		opts = append(opts, runtimes.WithTimeout(flags.timeout))
+	}
+	return opts, nil
+}
+
+// checkLimits returns an error if the named runtime does not support the limits declared by the
+// flags.
+func (flags *runFlags) checkLimits(name string) error {
+	if flags.fuel > 0 && name != "wasmtime" {
+		return fmt.Errorf("the %s runtime does not support -fuel, use wasmtime", name)
+	}
+	if flags.timeout > 0 && name != "wasmtime" && name != "wazero" {
+		return fmt.Errorf("the %s runtime does not support -timeout, use wasmtime or wazero", name)
+	}
+	return nil
+}
+
+// options returns the config options declared by the flags.
+func (flags *runFlags) options(e *env, runtimeOpts []runtimes.Option) []config.Option {
+	opts := append(e.options(), config.WithRuntimeOptions(runtimeOpts...))
+	opts = append(opts, flags.arguments...)
+	if flags.inverse {
+		opts = append(opts, config.WithInverse())
+	}
+	return opts
+}
+
+var argumentOverridePattern = regexp.MustCompile(`^lens(?:es)?\[(\d+)\]\.arguments\.([^=]+)=(.*)$`)
+
+// parseArgumentOverride parses a `lens[index].arguments.name=value` argument override.
+func parseArgumentOverride(s string) (config.Option, error) {
+	match := argumentOverridePattern.FindStringSubmatch(s)
+	if match == nil {
+		return nil, fmt.Errorf("expected lens[index].arguments.name=value, got %q", s)
+	}
+
+	index, err := strconv.Atoi(match[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	err = json.Unmarshal([]byte(match[3]), &value)
+	if err != nil {
+		value = match[3]
+	}
+
+	return config.WithArgument(index, match[2], value), nil
+}
+
+// run applies the given lens file to the items read from stdin, streaming the results to stdout
+// as they are yielded.
+func run(e *env, lensFilePath string, flags *runFlags) error {
+	src, err := newSource(e.stdin, flags.inputFormat)
+	if err != nil {
+		return newCliError(stageUsage, err)
+	}
+	out, err := newItemWriter(e.stdout, flags.outputFormat, flags.pretty)
+	if err != nil {
+		return newCliError(stageUsage, err)
+	}
+
+	runtimeOpts, err := flags.runtimeOptions(e, lensFilePath)
+	if err != nil {
+		return err
+	}
+
+	runtime, err := runtimes.New(flags.runtime, runtimeOpts...)
+	if err != nil {
+		return newCliError(stageConfig, err)
+	}
+
+	result, err := config.LoadIntoFromFile[map[string]any, map[string]any](
+		runtime,
+		map[string]module.Module{},
+		lensFilePath,
+		src,
+		flags.options(e, runtimeOpts)...,
+	)
+	if err != nil {
+		return loadError(err)
+	}
+
+	// The items yielded before any failure are written out before the error is reported, Close
+	// flushes the output otherwise.
+	defer out.Flush()
+
+	for {
+		hasNext, err := result.Next()
+		if err != nil {
+			return transformError(src, err)
+		}
+
+		if !hasNext {
+			break
+		}
+
+		val, err := result.Value()
+		if err != nil {
+			return transformError(src, err)
+		}
+
+		err = out.Write(val)
+		if err != nil {
+			return err
+		}
+	}
+
+	return out.Close()
+}
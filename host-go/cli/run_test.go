@@ -0,0 +1,234 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWritesItems(t *testing.T) {
+	lensFile := writeFile(t, "lens.yaml", `
+lenses:
+  - path: builtin:rename
+    arguments:
+      src: a
+      dst: b
+  - path: builtin:copy
+    arguments:
+      src: b
+      dst: c
+`)
+
+	tests := map[string]struct {
+		args     []string
+		input    string
+		expected string
+	}{
+		"json array": {
+			input:    `[{"a": 1}, {"a": 2}]`,
+			expected: `[{"b":1,"c":1},{"b":2,"c":2}]`,
+		},
+		"empty json array": {
+			input:    `[]`,
+			expected: `[]`,
+		},
+		"ndjson": {
+			args:     []string{"-input-format", "ndjson", "-output-format", "ndjson"},
+			input:    "{\"a\": 1}\n{\"a\": 2}\n",
+			expected: "{\"b\":1,\"c\":1}\n{\"b\":2,\"c\":2}\n",
+		},
+		"ndjson to json array": {
+			args:     []string{"-input-format", "ndjson"},
+			input:    "{\"a\": 1}\n{\"a\": 2}",
+			expected: `[{"b":1,"c":1},{"b":2,"c":2}]`,
+		},
+		"pretty": {
+			args:     []string{"-pretty"},
+			input:    `[{"a": 1}]`,
+			expected: "[\n\t{\n\t\t\"b\": 1,\n\t\t\"c\": 1\n\t}\n]\n",
+		},
+		"inverse": {
+			args:     []string{"-inverse"},
+			input:    `[{"b": 1, "c": 1}]`,
+			expected: `[{"a":1}]`,
+		},
+		"argument overrides": {
+			args:     []string{"-set", "lens[0].arguments.dst=x", "-set", "lenses[1].arguments.src=\"x\""},
+			input:    `[{"a": 1}]`,
+			expected: `[{"c":1,"x":1}]`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			args := append([]string{"run"}, test.args...)
+			result := runCli(t, test.input, append(args, lensFile)...)
+
+			assert.Equal(t, exitOK, result.code, result.stderr)
+			assert.Equal(t, test.expected, result.stdout)
+		})
+	}
+}
+
+func TestRunReturnsUsageErrorGivenInvalidFlags(t *testing.T) {
+	lensFile := writeFile(t, "lens.json", `{"lenses": []}`)
+
+	tests := map[string]struct {
+		args     []string
+		expected string
+	}{
+		"input format": {
+			args:     []string{"-input-format", "csv"},
+			expected: `unknown input format "csv"`,
+		},
+		"output format": {
+			args:     []string{"-output-format", "csv"},
+			expected: `unknown output format "csv"`,
+		},
+		"pretty ndjson": {
+			args:     []string{"-pretty", "-output-format", "ndjson"},
+			expected: "ndjson output cannot be pretty printed",
+		},
+		"argument override": {
+			args:     []string{"-set", "lens[0].args.dst=x"},
+			expected: `expected lens[index].arguments.name=value, got "lens[0].args.dst=x"`,
+		},
+		"timeout": {
+			args:     []string{"-timeout", "1s", "-runtime", "wasmer"},
+			expected: "the wasmer runtime does not support -timeout, use wasmtime or wazero",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			args := append([]string{"run"}, test.args...)
+			result := runCli(t, `[]`, append(args, lensFile)...)
+
+			assert.Equal(t, exitUsage, result.code)
+			assert.Contains(t, result.stderr, test.expected)
+		})
+	}
+}
+
+func TestParseArgumentOverride(t *testing.T) {
+	tests := map[string]any{
+		`lens[0].arguments.value=text`:       "text",
+		`lens[0].arguments.value="text"`:     "text",
+		`lens[0].arguments.value=`:           "",
+		`lens[0].arguments.value=a=b`:        "a=b",
+		`lens[0].arguments.value=12.5`:       12.5,
+		`lens[0].arguments.value=true`:       true,
+		`lens[0].arguments.value=null`:       nil,
+		`lens[0].arguments.value=[1, "a"]`:   []any{float64(1), "a"},
+		`lens[0].arguments.value={"a": {}}`:  map[string]any{"a": map[string]any{}},
+		`lenses[0].arguments.value={invalid`: "{invalid",
+	}
+
+	for s, expected := range tests {
+		t.Run(s, func(t *testing.T) {
+			opt, err := parseArgumentOverride(s)
+			require.NoError(t, err)
+
+			// The overridden value is set on the items missing the field.
+			lens := model.Lens{
+				Lenses: []model.LensModule{
+					{Path: "builtin:set-default", Arguments: map[string]any{"field": "name", "value": "default"}},
+				},
+			}
+			results, err := config.Load[map[string]any, map[string]any](
+				lens,
+				enumerable.New([]map[string]any{{}}),
+				opt,
+			)
+			require.NoError(t, err)
+
+			items := []map[string]any{}
+			err = enumerable.ForEach(results, func(item map[string]any) {
+				items = append(items, item)
+			})
+			require.NoError(t, err)
+			assert.Equal(t, []map[string]any{{"name": expected}}, items)
+		})
+	}
+}
+
+func TestParseArgumentOverrideReturnsErrorGivenInvalidOverride(t *testing.T) {
+	tests := []string{
+		`lens[0].arguments.name`,
+		`lens[].arguments.name=value`,
+		`lens[-1].arguments.name=value`,
+		`lens[0].arguments.=value`,
+		`lens[0].name=value`,
+		`lens.0.arguments.name=value`,
+		`lens[99999999999999999999].arguments.name=value`,
+	}
+
+	for _, s := range tests {
+		t.Run(s, func(t *testing.T) {
+			_, err := parseArgumentOverride(s)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestRunChecksLimitsAgainstEachRuntime(t *testing.T) {
+	tests := map[string]struct {
+		args         []string
+		runtimes     []string
+		expectedCode int
+		expected     string
+	}{
+		"fuel on the default runtime": {
+			args:     []string{"-fuel", "1000000"},
+			runtimes: []string{""},
+		},
+		"fuel on wazero": {
+			args:         []string{"-fuel", "1000000", "-runtime", "wazero"},
+			runtimes:     []string{""},
+			expectedCode: exitUsage,
+			expected:     "the wazero runtime does not support -fuel, use wasmtime",
+		},
+		"fuel on a lens declaring wazero": {
+			args:         []string{"-fuel", "1000000"},
+			runtimes:     []string{"", "wazero"},
+			expectedCode: exitUsage,
+			expected:     "lenses[1]: the wazero runtime does not support -fuel, use wasmtime",
+		},
+		"timeout on a lens declaring wazero": {
+			args:     []string{"-timeout", "10s"},
+			runtimes: []string{"", "wazero"},
+		},
+		"timeout on a lens declaring wasmer": {
+			args:         []string{"-timeout", "10s", "-runtime", "wazero"},
+			runtimes:     []string{"wasmtime", "wasmer"},
+			expectedCode: exitUsage,
+			expected:     "lenses[1]: the wasmer runtime does not support -timeout, use wasmtime or wazero",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("LENS_RUNTIME", "")
+			args := append([]string{"run"}, test.args...)
+			args = append(args, writeWatLensFile(t, test.runtimes...))
+
+			result := runCli(t, `[{"a": 1}]`, args...)
+
+			assert.Equal(t, test.expectedCode, result.code, result.stderr)
+			if test.expectedCode == exitOK {
+				assert.Equal(t, `[{"a":1}]`, result.stdout)
+			} else {
+				assert.Contains(t, result.stderr, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sourcenetwork/immutable/enumerable"
+)
+
+// The formats in which items may be read and written.
+const (
+	formatJSONArray = "json-array"
+	formatNDJSON    = "ndjson"
+)
+
+// source lazily decodes the items read from stdin.
+//
+// It cannot be reset, as the items are not retained once they have been yielded.
+type source struct {
+	decode  func() (map[string]any, bool, error)
+	current map[string]any
+
+	// count is the number of items that have been read.
+	count int
+	// err is the error with which reading failed, if any.
+	err error
+}
+
+var _ enumerable.Enumerable[map[string]any] = (*source)(nil)
+
+// newSource returns a source decoding the items read from the given reader in the given format.
+func newSource(r io.Reader, format string) (*source, error) {
+	switch format {
+	case formatJSONArray:
+		decoder := &jsonArrayDecoder{decoder: json.NewDecoder(r)}
+		return &source{decode: decoder.decode}, nil
+	case formatNDJSON:
+		decoder := &ndjsonDecoder{reader: bufio.NewReader(r)}
+		return &source{decode: decoder.decode}, nil
+	default:
+		return nil, fmt.Errorf("unknown input format %q, expected %s or %s", format, formatJSONArray, formatNDJSON)
+	}
+}
+
+func (s *source) Next() (bool, error) {
+	item, hasNext, err := s.decode()
+	if err != nil {
+		s.err = fmt.Errorf("reading input item %d: %w", s.count, err)
+		return false, s.err
+	}
+	if !hasNext {
+		return false, nil
+	}
+
+	s.current = item
+	s.count++
+	return true, nil
+}
+
+func (s *source) Value() (map[string]any, error) {
+	return s.current, nil
+}
+
+func (s *source) Reset() {}
+
+// jsonArrayDecoder decodes the items of a json array one at a time.
+type jsonArrayDecoder struct {
+	decoder *json.Decoder
+	started bool
+	done    bool
+}
+
+func (d *jsonArrayDecoder) decode() (map[string]any, bool, error) {
+	if d.done {
+		return nil, false, nil
+	}
+
+	if !d.started {
+		token, err := d.decoder.Token()
+		if err != nil {
+			return nil, false, err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return nil, false, errors.New("expected a json array")
+		}
+		d.started = true
+	}
+
+	if !d.decoder.More() {
+		// Consume the closing bracket.
+		_, err := d.decoder.Token()
+		if err != nil {
+			return nil, false, err
+		}
+		d.done = true
+
+		// Nothing but whitespace may follow the array.
+		_, err = d.decoder.Token()
+		switch {
+		case err == nil:
+			return nil, false, errors.New("unexpected content after the json array")
+		case !errors.Is(err, io.EOF):
+			return nil, false, err
+		}
+		return nil, false, nil
+	}
+
+	var item map[string]any
+	err := d.decoder.Decode(&item)
+	if err != nil {
+		return nil, false, err
+	}
+	return item, true, nil
+}
+
+// ndjsonDecoder decodes newline delimited json, one line at a time.
+type ndjsonDecoder struct {
+	reader *bufio.Reader
+	done   bool
+}
+
+func (d *ndjsonDecoder) decode() (map[string]any, bool, error) {
+	for !d.done {
+		line, err := d.reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			d.done = true
+		} else if err != nil {
+			return nil, false, err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var item map[string]any
+		err = json.Unmarshal(line, &item)
+		if err != nil {
+			return nil, false, err
+		}
+		return item, true, nil
+	}
+	return nil, false, nil
+}
+
+// itemWriter writes items as they are yielded.
+type itemWriter struct {
+	w      *bufio.Writer
+	format string
+	// pretty is true if json-array items should be indented, each on their own lines.
+	pretty bool
+	count  int
+}
+
+func newItemWriter(w io.Writer, format string, pretty bool) (*itemWriter, error) {
+	if format != formatJSONArray && format != formatNDJSON {
+		return nil, fmt.Errorf("unknown output format %q, expected %s or %s", format, formatJSONArray, formatNDJSON)
+	}
+	if pretty && format == formatNDJSON {
+		return nil, fmt.Errorf("%s output cannot be pretty printed, each item must be on a single line", formatNDJSON)
+	}
+	return &itemWriter{w: bufio.NewWriter(w), format: format, pretty: pretty}, nil
+}
+
+func (w *itemWriter) Write(item any) error {
+	var data []byte
+	var err error
+	if w.pretty {
+		data, err = json.MarshalIndent(item, "\t", "\t")
+	} else {
+		data, err = json.Marshal(item)
+	}
+	if err != nil {
+		return err
+	}
+
+	var separator string
+	switch {
+	case w.format == formatNDJSON:
+		data = append(data, '\n')
+	case w.count == 0:
+		separator = "["
+	default:
+		separator = ","
+	}
+	if w.pretty {
+		separator += "\n\t"
+	}
+
+	_, err = w.w.WriteString(separator)
+	if err != nil {
+		return err
+	}
+	w.count++
+
+	_, err = w.w.Write(data)
+	if err != nil {
+		return err
+	}
+
+	// Ndjson items are complete on their own, so are flushed as soon as they are written.
+	if w.format == formatNDJSON {
+		return w.w.Flush()
+	}
+	return nil
+}
+
+// Flush writes any buffered output, without completing it.
+func (w *itemWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// Close completes the output, flushing anything that has not yet been written.
+func (w *itemWriter) Close() error {
+	if w.format == formatJSONArray {
+		end := "]"
+		switch {
+		case w.count == 0:
+			end = "[]"
+		case w.pretty:
+			end = "\n]"
+		}
+		if w.pretty {
+			end += "\n"
+		}
+
+		_, err := w.w.WriteString(end)
+		if err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
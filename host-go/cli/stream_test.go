@@ -0,0 +1,176 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceReadsItems(t *testing.T) {
+	tests := map[string]struct {
+		format string
+		input  string
+	}{
+		"json array":                  {formatJSONArray, `[{"a": 1}, {"a": 2}]`},
+		"json array with whitespace":  {formatJSONArray, " [\n{\"a\": 1},\n{\"a\": 2}\n]\n\n"},
+		"ndjson":                      {formatNDJSON, "{\"a\": 1}\n{\"a\": 2}"},
+		"ndjson with blank lines":     {formatNDJSON, "\n{\"a\": 1}\n\n{\"a\": 2}\n\n"},
+		"ndjson with carriage return": {formatNDJSON, "{\"a\": 1}\r\n{\"a\": 2}\r\n"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			src, err := newSource(strings.NewReader(test.input), test.format)
+			require.NoError(t, err)
+
+			items := []map[string]any{}
+			for {
+				hasNext, err := src.Next()
+				require.NoError(t, err)
+				if !hasNext {
+					break
+				}
+				item, err := src.Value()
+				require.NoError(t, err)
+				items = append(items, item)
+			}
+
+			assert.Equal(t, []map[string]any{{"a": float64(1)}, {"a": float64(2)}}, items)
+			assert.Equal(t, 2, src.count)
+		})
+	}
+}
+
+func TestSourceReturnsErrorGivenInvalidInput(t *testing.T) {
+	tests := map[string]struct {
+		format   string
+		input    string
+		expected string
+	}{
+		"not an array": {
+			formatJSONArray, `{"a": 1}`,
+			"reading input item 0: expected a json array",
+		},
+		"item after the array": {
+			formatJSONArray, `[{"a": 1}] {"a": 2}`,
+			"reading input item 1: unexpected content after the json array",
+		},
+		"second array": {
+			formatJSONArray, `[{"a": 1}][]`,
+			"reading input item 1: unexpected content after the json array",
+		},
+		"invalid content after the array": {
+			formatJSONArray, `[{"a": 1}] ]`,
+			"reading input item 1: invalid character ']'",
+		},
+		"unterminated array": {
+			formatJSONArray, `[{"a": 1}`,
+			"reading input item 1: unexpected end of JSON input",
+		},
+		"invalid ndjson line": {
+			formatNDJSON, "{\"a\": 1}\n{\"a\": \n",
+			"reading input item 1: unexpected end of JSON input",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			src, err := newSource(strings.NewReader(test.input), test.format)
+			require.NoError(t, err)
+
+			for {
+				hasNext, err := src.Next()
+				if err != nil {
+					require.ErrorContains(t, err, test.expected)
+					assert.Equal(t, err, src.err)
+					return
+				}
+				require.True(t, hasNext, "expected an error")
+			}
+		})
+	}
+}
+
+func TestItemWriterWritesItems(t *testing.T) {
+	tests := map[string]struct {
+		format   string
+		pretty   bool
+		items    []any
+		expected string
+	}{
+		"json array":        {formatJSONArray, false, []any{1, "a"}, `[1,"a"]`},
+		"empty json array":  {formatJSONArray, false, nil, `[]`},
+		"pretty json array": {formatJSONArray, true, []any{map[string]any{"a": 1}}, "[\n\t{\n\t\t\"a\": 1\n\t}\n]\n"},
+		"ndjson":            {formatNDJSON, false, []any{1, "a"}, "1\n\"a\"\n"},
+		"empty ndjson":      {formatNDJSON, false, nil, ``},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			w, err := newItemWriter(&out, test.format, test.pretty)
+			require.NoError(t, err)
+
+			for _, item := range test.items {
+				require.NoError(t, w.Write(item))
+			}
+			require.NoError(t, w.Close())
+
+			assert.Equal(t, test.expected, out.String())
+		})
+	}
+}
+
+func TestItemWriterFlushesEachNDJSONItem(t *testing.T) {
+	var out bytes.Buffer
+	w, err := newItemWriter(&out, formatNDJSON, false)
+	require.NoError(t, err)
+
+	require.NoError(t, w.Write(map[string]any{"a": 1}))
+	assert.Equal(t, "{\"a\":1}\n", out.String())
+
+	require.NoError(t, w.Write(map[string]any{"a": 2}))
+	assert.Equal(t, "{\"a\":1}\n{\"a\":2}\n", out.String())
+}
+
+func TestItemWriterReturnsErrorGivenInvalidFormat(t *testing.T) {
+	_, err := newItemWriter(&bytes.Buffer{}, "csv", false)
+	require.ErrorContains(t, err, `unknown output format "csv"`)
+
+	_, err = newItemWriter(&bytes.Buffer{}, formatNDJSON, true)
+	require.ErrorContains(t, err, "ndjson output cannot be pretty printed")
+}
+
+func TestRunWritesItemsYieldedBeforeFailure(t *testing.T) {
+	lensFile := writeFile(t, "lens.json", `{
+		"lenses": [
+			{"path": "builtin:expr", "arguments": {"expression": "{a: number(.a)}"}}
+		]
+	}`)
+
+	tests := map[string]struct {
+		outputFormat string
+		expected     string
+	}{
+		"ndjson":     {formatNDJSON, "{\"a\":1}\n"},
+		"json array": {formatJSONArray, `[{"a":1}`},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := runCli(t, "[{\"a\": \"1\"}, {\"a\": \"x\"}]",
+				"run", "-output-format", test.outputFormat, lensFile)
+
+			assert.Equal(t, exitTransform, result.code)
+			assert.Equal(t, test.expected, result.stdout)
+			assert.Contains(t, result.stderr, `cannot convert "x" to number`)
+		})
+	}
+}
@@ -0,0 +1,281 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/runtimes"
+	"github.com/sourcenetwork/immutable/enumerable"
+)
+
+var testCommand = &command{
+	name: "test",
+	args: "<dir>",
+	summary: "Runs the test cases found within the directory on each runtime, reporting any differences " +
+		"from their expected output.",
+	nArgs: 1,
+	setup: func(fs *flag.FlagSet) runFunc {
+		runtimeNames := fs.String("runtime", "",
+			fmt.Sprintf("a comma separated list of the `names` of the runtimes to test on, defaults to all of %s",
+				strings.Join(runtimes.Names(), ", ")))
+		return func(e *env, args []string) error {
+			names := runtimes.Names()
+			if *runtimeNames != "" {
+				names = strings.Split(*runtimeNames, ",")
+			}
+			return test(e, args[0], names)
+		}
+	},
+}
+
+// The files making up a test case, test case directories must contain a lens file, an input file
+// and either an expected output or expected error file.
+const (
+	testInputFile         = "input.json"
+	testExpectedFile      = "expected.json"
+	testExpectedErrorFile = "expected-error.txt"
+)
+
+// testLensFiles are the names a test case lens file may have.
+var testLensFiles = []string{"lens.json", "lens.yaml", "lens.yml", "lens.toml"}
+
+// testCase is a test case found within a test directory.
+type testCase struct {
+	// name is the path of the test case directory, relative to the test directory.
+	name     string
+	lensFile string
+	input    []map[string]any
+	// expected holds the expected output, if no error is expected.
+	expected []any
+	// expectedError is the text that the error must contain, if an error is expected.
+	expectedError string
+}
+
+func test(e *env, dir string, runtimeNames []string) error {
+	cases, err := findTestCases(dir)
+	if err != nil {
+		return newCliError(stageConfig, err)
+	}
+	if len(cases) == 0 {
+		return newCliError(stageConfig, fmt.Errorf("no test cases found in %s", dir))
+	}
+
+	runs := 0
+	failures := 0
+	for _, tc := range cases {
+		for _, runtimeName := range runtimeNames {
+			runs++
+			problems := runTestCase(e, tc, runtimeName)
+			if len(problems) == 0 {
+				fmt.Fprintf(e.stdout, "ok    %s (%s)\n", tc.name, runtimeName)
+				continue
+			}
+
+			failures++
+			fmt.Fprintf(e.stdout, "FAIL  %s (%s)\n", tc.name, runtimeName)
+			for _, problem := range problems {
+				fmt.Fprintf(e.stdout, "      %s\n", strings.ReplaceAll(problem, "\n", "\n      "))
+			}
+		}
+	}
+
+	fmt.Fprintf(e.stdout, "\n%d passed, %d failed\n", runs-failures, failures)
+	if failures > 0 {
+		return newCliError(stageError, fmt.Errorf("%d of %d test runs failed", failures, runs))
+	}
+	return nil
+}
+
+// findTestCases returns the test cases found within the given directory, including the directory
+// itself, in lexical order.
+func findTestCases(dir string) ([]testCase, error) {
+	cases := []testCase{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+
+		lensFile := findTestLensFile(path)
+		if lensFile == "" {
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		tc, err := loadTestCase(filepath.ToSlash(name), path, lensFile)
+		if err != nil {
+			return fmt.Errorf("test case %s: %w", name, err)
+		}
+		cases = append(cases, tc)
+		return nil
+	})
+	return cases, err
+}
+
+// findTestLensFile returns the path of the lens file within the given directory, or an empty
+// string if there is none.
+func findTestLensFile(dir string) string {
+	for _, name := range testLensFiles {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func loadTestCase(name string, dir string, lensFile string) (testCase, error) {
+	tc := testCase{
+		name:     name,
+		lensFile: lensFile,
+	}
+
+	err := readJSONFile(filepath.Join(dir, testInputFile), &tc.input)
+	if err != nil {
+		return testCase{}, err
+	}
+
+	expectedError, err := os.ReadFile(filepath.Join(dir, testExpectedErrorFile))
+	switch {
+	case err == nil:
+		tc.expectedError = strings.TrimSpace(string(expectedError))
+		if tc.expectedError == "" {
+			return testCase{}, fmt.Errorf("%s is empty", testExpectedErrorFile)
+		}
+		return tc, nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return testCase{}, err
+	}
+
+	err = readJSONFile(filepath.Join(dir, testExpectedFile), &tc.expected)
+	if errors.Is(err, fs.ErrNotExist) {
+		return testCase{}, fmt.Errorf("expected either %s or %s", testExpectedFile, testExpectedErrorFile)
+	}
+	return tc, err
+}
+
+func readJSONFile(path string, value any) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(content, value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// runTestCase runs the given test case on the named runtime, returning a description of each of
+// the differences from its expectations.
+func runTestCase(e *env, tc testCase, runtimeName string) []string {
+	output, err := runTestLens(e, tc, runtimeName)
+
+	if tc.expectedError != "" {
+		switch {
+		case err == nil:
+			return []string{fmt.Sprintf("expected an error containing %q, got %d items", tc.expectedError, len(output))}
+		case !strings.Contains(err.Error(), tc.expectedError):
+			return []string{fmt.Sprintf("expected an error containing %q, got: %v", tc.expectedError, err)}
+		default:
+			return nil
+		}
+	}
+
+	if err != nil {
+		return []string{fmt.Sprintf("unexpected error: %v", err)}
+	}
+	return diffItems(tc.expected, output)
+}
+
+// runTestLens applies the lens file of the given test case to its input, returning the output as
+// it would be decoded from json.
+func runTestLens(e *env, tc testCase, runtimeName string) ([]any, error) {
+	runtime, err := runtimes.New(runtimeName, e.runtimeOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := config.LoadIntoFromFile[map[string]any, map[string]any](
+		runtime,
+		map[string]module.Module{},
+		tc.lensFile,
+		enumerable.New(tc.input),
+		e.options()...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	output := []map[string]any{}
+	err = enumerable.ForEach(results, func(item map[string]any) {
+		output = append(output, item)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The output is normalized so that it may be compared with the expected output.
+	content, err := json.Marshal(output)
+	if err != nil {
+		return nil, err
+	}
+	var normalized []any
+	err = json.Unmarshal(content, &normalized)
+	return normalized, err
+}
+
+// diffItems describes the differences between the expected and actual items.
+func diffItems(expected []any, actual []any) []string {
+	problems := []string{}
+	if len(expected) != len(actual) {
+		problems = append(problems, fmt.Sprintf("expected %d items, got %d", len(expected), len(actual)))
+	}
+
+	for i := 0; i < max(len(expected), len(actual)); i++ {
+		switch {
+		case i >= len(actual):
+			problems = append(problems, fmt.Sprintf("item %d: missing, expected %s", i, formatItem(expected[i])))
+		case i >= len(expected):
+			problems = append(problems, fmt.Sprintf("item %d: unexpected %s", i, formatItem(actual[i])))
+		case !reflect.DeepEqual(expected[i], actual[i]):
+			problems = append(problems, fmt.Sprintf(
+				"item %d:\n  expected: %s\n  got:      %s",
+				i,
+				formatItem(expected[i]),
+				formatItem(actual[i]),
+			))
+		}
+	}
+	return problems
+}
+
+func formatItem(item any) string {
+	var sb strings.Builder
+	encoder := json.NewEncoder(&sb)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(item)
+	if err != nil {
+		return fmt.Sprint(item)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,135 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const renameLensFile = `{"lenses": [{"path": "builtin:rename", "arguments": {"src": "a", "dst": "b"}}]}`
+
+func TestTest(t *testing.T) {
+	dir := writeTestCases(t, map[string]string{
+		"pass/lens.json":     renameLensFile,
+		"pass/input.json":    `[{"a": 1}]`,
+		"pass/expected.json": `[{"b": 1}]`,
+
+		"nested/error/lens.yaml":          "lenses:\n  - path: builtin:rename\n    arguments: {src: a, dst: b}\n",
+		"nested/error/input.json":         `[{"x": 1}]`,
+		"nested/error/expected-error.txt": "property not found: a\n",
+
+		"fail/lens.json":     renameLensFile,
+		"fail/input.json":    `[{"a": 1}]`,
+		"fail/expected.json": `[{"c": 1}, {"d": 2}]`,
+
+		"unexpected-success/lens.json":          renameLensFile,
+		"unexpected-success/input.json":         `[{"a": 1}]`,
+		"unexpected-success/expected-error.txt": "property not found",
+	})
+
+	result := runCli(t, "", "test", "-runtime", "wasmtime,wazero", dir)
+
+	assert.Equal(t, exitError, result.code)
+	assert.Equal(t, `FAIL  fail (wasmtime)
+      expected 2 items, got 1
+      item 0:
+        expected: {"c":1}
+        got:      {"b":1}
+      item 1: missing, expected {"d":2}
+FAIL  fail (wazero)
+      expected 2 items, got 1
+      item 0:
+        expected: {"c":1}
+        got:      {"b":1}
+      item 1: missing, expected {"d":2}
+ok    nested/error (wasmtime)
+ok    nested/error (wazero)
+ok    pass (wasmtime)
+ok    pass (wazero)
+FAIL  unexpected-success (wasmtime)
+      expected an error containing "property not found", got 1 items
+FAIL  unexpected-success (wazero)
+      expected an error containing "property not found", got 1 items
+
+4 passed, 4 failed
+`, result.stdout)
+	assert.Equal(t, "lens test: 4 of 8 test runs failed\n", result.stderr)
+}
+
+func TestTestReturnsErrorGivenInvalidTestCases(t *testing.T) {
+	tests := map[string]struct {
+		files    map[string]string
+		expected string
+	}{
+		"no test cases": {
+			files:    map[string]string{"readme.txt": ""},
+			expected: "no test cases found in ",
+		},
+		"missing input": {
+			files: map[string]string{
+				"case/lens.json":     renameLensFile,
+				"case/expected.json": `[]`,
+			},
+			expected: "test case case: open ",
+		},
+		"missing expectation": {
+			files: map[string]string{
+				"case/lens.json":  renameLensFile,
+				"case/input.json": `[]`,
+			},
+			expected: "test case case: expected either expected.json or expected-error.txt",
+		},
+		"empty expected error": {
+			files: map[string]string{
+				"case/lens.json":          renameLensFile,
+				"case/input.json":         `[]`,
+				"case/expected-error.txt": "\n",
+			},
+			expected: "test case case: expected-error.txt is empty",
+		},
+		"invalid input": {
+			files: map[string]string{
+				"case/lens.json":     renameLensFile,
+				"case/input.json":    `{`,
+				"case/expected.json": `[]`,
+			},
+			expected: "test case case: input.json: unexpected end of JSON input",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := runCli(t, "", "test", writeTestCases(t, test.files))
+
+			assert.Equal(t, exitConfig, result.code)
+			assert.Contains(t, result.stderr, test.expected)
+		})
+	}
+}
+
+func TestDiffItems(t *testing.T) {
+	assert.Empty(t, diffItems([]any{map[string]any{"a": 1.0}}, []any{map[string]any{"a": 1.0}}))
+	assert.Equal(t, []string{
+		"expected 1 items, got 2",
+		"item 1: unexpected \"<b>\"",
+	}, diffItems([]any{"a"}, []any{"a", "<b>"}))
+}
+
+// writeTestCases writes the given files, keyed by their slash separated paths, to a new
+// temporary directory, returning its path.
+func writeTestCases(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	return dir
+}
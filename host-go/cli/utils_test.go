@@ -0,0 +1,80 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// cliResult holds the outcome of running the cli.
+type cliResult struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+// runCli runs the cli with the given arguments, feeding it the given stdin.
+func runCli(t *testing.T, stdin string, args ...string) cliResult {
+	var stdout, stderr bytes.Buffer
+	e := &env{
+		stdin:  strings.NewReader(stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+	}
+
+	code := e.main(args)
+	return cliResult{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+// writeFile writes the given content to a file of the given name within a new temporary
+// directory, returning its path.
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0o600)
+	require.NoError(t, err)
+	return path
+}
+
+// identityWat is a lens that yields every item it receives unchanged.
+const identityWat = `
+(module
+  (import "lens" "next" (func $next (result i32)))
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  ;; A bump allocator, memory is never freed.
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $heap))
+    (global.set $heap (i32.add (local.get $ptr) (local.get $size)))
+    (local.get $ptr))
+
+  (func (export "transform") (result i32)
+    (call $next)))
+`
+
+// writeWatLensFile writes a lens file applying the identity lens on each of the given runtimes,
+// an empty name leaving the runtime undeclared.
+func writeWatLensFile(t *testing.T, runtimeNames ...string) string {
+	lenses := []map[string]any{}
+	for _, name := range runtimeNames {
+		lens := map[string]any{"wat": identityWat}
+		if name != "" {
+			lens["runtime"] = name
+		}
+		lenses = append(lenses, lens)
+	}
+
+	content, err := json.Marshal(map[string]any{"lenses": lenses})
+	require.NoError(t, err)
+	return writeFile(t, "lens.json", string(content))
+}
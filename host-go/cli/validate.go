@@ -0,0 +1,51 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/lens-vm/lens/host-go/config"
+)
+
+var validateCommand = &command{
+	name:    "validate",
+	args:    "<lensfile>",
+	summary: "Checks that the lens file, and every module it declares, could be loaded.",
+	nArgs:   1,
+	setup: func(fs *flag.FlagSet) runFunc {
+		return validate
+	},
+}
+
+func validate(e *env, args []string) error {
+	problems := config.ValidateFile(args[0], e.options()...)
+	if len(problems) == 0 {
+		fmt.Fprintf(e.stdout, "%s is valid\n", args[0])
+		return nil
+	}
+
+	// The lens file is only reported as invalid if there are no module problems.
+	stage := stageConfig
+	for _, problem := range problems {
+		problemErr := newCliError(stageConfig, problem.Err)
+		if problem.Index >= 0 {
+			stage = stageModule
+			problemErr = newCliError(stageModule, &config.StageError{
+				Index:  problem.Index,
+				Module: problem.Module,
+				Err:    problem.Err,
+			})
+		}
+		e.report("validate", problemErr)
+	}
+
+	return &cliError{
+		stage:    stage,
+		err:      fmt.Errorf("%d problem(s) found", len(problems)),
+		reported: true,
+	}
+}
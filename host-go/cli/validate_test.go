@@ -0,0 +1,52 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		lensFile     string
+		expectedCode int
+		// expected holds the errors written to stderr, <lensfile> standing for the lens file path.
+		expected string
+	}{
+		"valid": {
+			lensFile: `{"lenses": [{"path": "builtin:rename", "arguments": {"src": "a", "dst": "b"}}]}`,
+		},
+		"invalid lens file": {
+			lensFile:     `{"lenses": [`,
+			expectedCode: exitConfig,
+			expected:     `{"stage":"config","message":"<lensfile>:1:13: unexpected end of JSON input"}`,
+		},
+		"invalid modules": {
+			lensFile:     `{"lenses": [{"path": "builtin:nope"}, {"path": "builtin:drop"}]}`,
+			expectedCode: exitModule,
+			expected: `{"stage":"module","lens":0,"module":"builtin:nope","message":"unknown builtin module \"nope\""}` + "\n" +
+				`{"stage":"module","lens":1,"module":"builtin:drop","message":"builtin:drop: missing argument: field"}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			lensFile := writeFile(t, "lens.json", test.lensFile)
+
+			result := runCli(t, "", "validate", "-errors", "json", lensFile)
+
+			assert.Equal(t, test.expectedCode, result.code)
+			if test.expectedCode == exitOK {
+				assert.Equal(t, lensFile+" is valid\n", result.stdout)
+				assert.Empty(t, result.stderr)
+			} else {
+				assert.Equal(t, strings.ReplaceAll(test.expected, "<lensfile>", lensFile)+"\n", result.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// wasmInfo describes the interface of a wasm module.
+type wasmInfo struct {
+	Imports        []wasmImport        `json:"imports"`
+	Exports        []wasmExport        `json:"exports"`
+	Memories       []wasmLimits        `json:"memories"`
+	CustomSections []wasmCustomSection `json:"customSections"`
+}
+
+type wasmImport struct {
+	Module string `json:"module"`
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	// Type is the signature of imported functions, or the limits of imported memories.
+	Type string `json:"type,omitempty"`
+}
+
+type wasmExport struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Type is the signature of exported functions.
+	Type string `json:"type,omitempty"`
+}
+
+type wasmLimits struct {
+	Min uint32  `json:"min"`
+	Max *uint32 `json:"max,omitempty"`
+}
+
+func (l wasmLimits) String() string {
+	if l.Max == nil {
+		return fmt.Sprintf("min %d pages", l.Min)
+	}
+	return fmt.Sprintf("min %d pages, max %d pages", l.Min, *l.Max)
+}
+
+type wasmCustomSection struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+	// Content is the content of the section if it is printable text, such as json metadata.
+	Content string `json:"content,omitempty"`
+}
+
+var wasmMagic = []byte("\x00asm")
+
+var externalKinds = []string{"func", "table", "memory", "global", "tag"}
+
+var valueTypes = map[byte]string{
+	0x7f: "i32",
+	0x7e: "i64",
+	0x7d: "f32",
+	0x7c: "f64",
+	0x7b: "v128",
+	0x70: "funcref",
+	0x6f: "externref",
+}
+
+// parseWasm decodes the sections of the given wasm binary describing its interface.
+func parseWasm(content []byte) (*wasmInfo, error) {
+	if !bytes.HasPrefix(content, wasmMagic) || len(content) < 8 {
+		return nil, errors.New("not a wasm binary")
+	}
+
+	r := &wasmReader{data: content, offset: 8}
+	info := &wasmInfo{}
+	signatures := []string{}
+	// functionTypes holds the type index of every function, imported functions first.
+	functionTypes := []uint32{}
+	exports := []struct {
+		wasmExport
+		index uint32
+	}{}
+
+	for r.offset < len(r.data) {
+		id := r.byte()
+		size := r.u32()
+		if r.err != nil {
+			return nil, r.err
+		}
+		end := r.offset + int(size)
+		if end > len(r.data) {
+			return nil, fmt.Errorf("section %d exceeds the module", id)
+		}
+		section := &wasmReader{data: r.data[:end], offset: r.offset}
+
+		switch id {
+		case 0:
+			name := section.name()
+			customSection := wasmCustomSection{Name: name, Size: end - section.offset}
+			if content := section.data[section.offset:end]; isText(content) {
+				customSection.Content = string(content)
+			}
+			info.CustomSections = append(info.CustomSections, customSection)
+
+		case 1:
+			for i, n := 0, section.u32(); i < int(n) && section.err == nil; i++ {
+				if section.byte() != 0x60 {
+					return nil, errors.New("invalid function type")
+				}
+				params := section.valueTypes()
+				results := section.valueTypes()
+				signatures = append(signatures, fmt.Sprintf("(%s) -> (%s)", strings.Join(params, ", "), strings.Join(results, ", ")))
+			}
+
+		case 2:
+			for i, n := 0, section.u32(); i < int(n) && section.err == nil; i++ {
+				imp := wasmImport{Module: section.name(), Name: section.name(), Kind: section.kind()}
+				switch imp.Kind {
+				case "func":
+					typeIndex := section.u32()
+					functionTypes = append(functionTypes, typeIndex)
+					imp.Type = signature(signatures, typeIndex)
+				case "table":
+					section.byte()
+					section.limits()
+				case "memory":
+					imp.Type = section.limits().String()
+				case "global":
+					section.byte()
+					section.byte()
+				case "tag":
+					section.byte()
+					section.u32()
+				}
+				info.Imports = append(info.Imports, imp)
+			}
+
+		case 3:
+			for i, n := 0, section.u32(); i < int(n) && section.err == nil; i++ {
+				functionTypes = append(functionTypes, section.u32())
+			}
+
+		case 5:
+			for i, n := 0, section.u32(); i < int(n) && section.err == nil; i++ {
+				info.Memories = append(info.Memories, section.limits())
+			}
+
+		case 7:
+			for i, n := 0, section.u32(); i < int(n) && section.err == nil; i++ {
+				export := wasmExport{Name: section.name(), Kind: section.kind()}
+				exports = append(exports, struct {
+					wasmExport
+					index uint32
+				}{export, section.u32()})
+			}
+		}
+
+		if section.err != nil {
+			return nil, section.err
+		}
+		r.offset = end
+	}
+
+	// The function section follows the export section, so the signatures of exported functions
+	// can only be determined once all sections have been read.
+	for _, export := range exports {
+		if export.Kind == "func" && int(export.index) < len(functionTypes) {
+			export.Type = signature(signatures, functionTypes[export.index])
+		}
+		info.Exports = append(info.Exports, export.wasmExport)
+	}
+
+	return info, nil
+}
+
+// export returns the export with the given name, and true if it exists.
+func (info *wasmInfo) export(name string) (wasmExport, bool) {
+	for _, export := range info.Exports {
+		if export.Name == name {
+			return export, true
+		}
+	}
+	return wasmExport{}, false
+}
+
+func signature(signatures []string, index uint32) string {
+	if int(index) >= len(signatures) {
+		return ""
+	}
+	return signatures[index]
+}
+
+func isText(content []byte) bool {
+	if len(content) == 0 {
+		return false
+	}
+	for _, r := range string(content) {
+		if r == '�' || (r < 0x20 && r != '\n' && r != '\r' && r != '\t') {
+			return false
+		}
+	}
+	return true
+}
+
+// wasmReader reads the values of a wasm binary, any error is recorded and subsequent reads return
+// zero values.
+type wasmReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func (r *wasmReader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.offset >= len(r.data) {
+		r.err = errors.New("unexpected end of module")
+		return 0
+	}
+	b := r.data[r.offset]
+	r.offset++
+	return b
+}
+
+// u32 reads an unsigned LEB128 encoded integer.
+func (r *wasmReader) u32() uint32 {
+	var result uint32
+	for shift := 0; shift < 35; shift += 7 {
+		b := r.byte()
+		result |= uint32(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result
+		}
+	}
+	if r.err == nil {
+		r.err = errors.New("invalid integer")
+	}
+	return 0
+}
+
+func (r *wasmReader) name() string {
+	size := int(r.u32())
+	if r.err != nil {
+		return ""
+	}
+	if r.offset+size > len(r.data) {
+		r.err = errors.New("unexpected end of module")
+		return ""
+	}
+	name := string(r.data[r.offset : r.offset+size])
+	r.offset += size
+	return name
+}
+
+func (r *wasmReader) kind() string {
+	kind := r.byte()
+	if int(kind) >= len(externalKinds) {
+		r.err = fmt.Errorf("unknown external kind %d", kind)
+		return ""
+	}
+	return externalKinds[kind]
+}
+
+func (r *wasmReader) limits() wasmLimits {
+	flags := r.byte()
+	limits := wasmLimits{Min: r.u32()}
+	if flags&1 != 0 {
+		max := r.u32()
+		limits.Max = &max
+	}
+	return limits
+}
+
+func (r *wasmReader) valueTypes() []string {
+	types := []string{}
+	for i, n := 0, r.u32(); i < int(n) && r.err == nil; i++ {
+		b := r.byte()
+		name, ok := valueTypes[b]
+		if !ok {
+			name = fmt.Sprintf("0x%x", b)
+		}
+		types = append(types, name)
+	}
+	return types
+}
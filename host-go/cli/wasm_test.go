@@ -0,0 +1,99 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go/v21"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// interfaceWat is a module importing and exporting each kind of external, symbolic names are
+// avoided so that no name section is produced.
+const interfaceWat = `
+(module
+  (type (func (param i32 i64) (result f32)))
+  (import "env" "f" (func (type 0)))
+  (import "env" "mem" (memory 1 16))
+  (import "env" "g" (global i32))
+  (import "env" "table" (table 2 funcref))
+  (memory 2)
+  (global (mut f64) (f64.const 0))
+  (func (param i32) (result i32) (local.get 0))
+  (export "imported" (func 0))
+  (export "alloc" (func 1))
+  (export "memory" (memory 0))
+  (export "global" (global 1)))
+`
+
+func TestParseWasm(t *testing.T) {
+	wasm, err := wasmtime.Wat2Wasm(interfaceWat)
+	require.NoError(t, err)
+	wasm = appendCustomSection(wasm, "lens", []byte(`{"version": 1}`))
+	wasm = appendCustomSection(wasm, "binary", []byte{0, 1, 2})
+
+	info, err := parseWasm(wasm)
+	require.NoError(t, err)
+
+	max := uint32(16)
+	assert.Equal(t, &wasmInfo{
+		Imports: []wasmImport{
+			{Module: "env", Name: "f", Kind: "func", Type: "(i32, i64) -> (f32)"},
+			{Module: "env", Name: "mem", Kind: "memory", Type: "min 1 pages, max 16 pages"},
+			{Module: "env", Name: "g", Kind: "global"},
+			{Module: "env", Name: "table", Kind: "table"},
+		},
+		Exports: []wasmExport{
+			{Name: "imported", Kind: "func", Type: "(i32, i64) -> (f32)"},
+			{Name: "alloc", Kind: "func", Type: "(i32) -> (i32)"},
+			{Name: "memory", Kind: "memory"},
+			{Name: "global", Kind: "global"},
+		},
+		Memories: []wasmLimits{{Min: 2}},
+		CustomSections: []wasmCustomSection{
+			{Name: "lens", Size: 14, Content: `{"version": 1}`},
+			{Name: "binary", Size: 3},
+		},
+	}, info)
+	assert.Equal(t, "min 1 pages, max 16 pages", wasmLimits{Min: 1, Max: &max}.String())
+}
+
+func TestParseWasmReturnsErrorGivenInvalidBinary(t *testing.T) {
+	header := "\x00asm\x01\x00\x00\x00"
+
+	tests := map[string]struct {
+		wasm     string
+		expected string
+	}{
+		"text":                 {"(module)", "not a wasm binary"},
+		"truncated header":     {"\x00asm", "not a wasm binary"},
+		"truncated section":    {header + "\x01\x05\x01", "section 1 exceeds the module"},
+		"truncated size":       {header + "\x01\x80", "unexpected end of module"},
+		"invalid integer":      {header + "\x01\xff\xff\xff\xff\xff\x01", "invalid integer"},
+		"invalid function":     {header + "\x01\x02\x01\x50", "invalid function type"},
+		"unknown kind":         {header + "\x07\x05\x01\x01e\x09\x00", "unknown external kind 9"},
+		"truncated name":       {header + "\x00\x02\x05a", "unexpected end of module"},
+		"truncated value type": {header + "\x01\x04\x01\x60\x05\x7f", "unexpected end of module"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseWasm([]byte(test.wasm))
+			require.ErrorContains(t, err, test.expected)
+		})
+	}
+}
+
+// appendCustomSection appends a custom section with the given name and content to the given
+// wasm binary.
+func appendCustomSection(wasm []byte, name string, content []byte) []byte {
+	section := append([]byte{byte(len(name))}, name...)
+	section = append(section, content...)
+	wasm = append(wasm, 0, byte(len(section)))
+	return append(wasm, section...)
+}
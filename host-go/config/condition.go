@@ -0,0 +1,158 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/lens-vm/lens/host-go/engine"
+)
+
+// NewPredicate returns an engine.Predicate that evaluates the given condition against items.
+func NewPredicate(condition model.Condition) (engine.Predicate, error) {
+	match, err := compileCondition(condition)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(item any) (bool, error) {
+		return match(item), nil
+	}, nil
+}
+
+// compileCondition converts the given condition into a function, normalizing the values it
+// compares against so that they may be compared with items decoded from json.
+func compileCondition(condition model.Condition) (func(item any) bool, error) {
+	matchers := []func(item any) bool{}
+
+	if condition.Field != "" {
+		path := strings.Split(condition.Field, ".")
+
+		// A field without a value to compare against must simply exist.
+		checkExists := condition.Exists != nil || condition.Equals == nil
+		expected := condition.Exists == nil || *condition.Exists
+		if checkExists {
+			matchers = append(matchers, func(item any) bool {
+				_, ok := lookupField(item, path)
+				return ok == expected
+			})
+		}
+
+		if condition.Equals != nil {
+			expected, err := normalizeJSON(condition.Equals)
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, func(item any) bool {
+				value, ok := lookupField(item, path)
+				return ok && reflect.DeepEqual(value, expected)
+			})
+		}
+	}
+
+	if len(condition.All) > 0 {
+		all, err := compileConditions(condition.All)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, func(item any) bool {
+			for _, match := range all {
+				if !match(item) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	if len(condition.Any) > 0 {
+		anyOf, err := compileConditions(condition.Any)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, func(item any) bool {
+			for _, match := range anyOf {
+				if match(item) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+
+	if condition.Not != nil {
+		not, err := compileCondition(*condition.Not)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, func(item any) bool {
+			return !not(item)
+		})
+	}
+
+	return func(item any) bool {
+		for _, match := range matchers {
+			if !match(item) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func compileConditions(conditions []model.Condition) ([]func(item any) bool, error) {
+	matchers := make([]func(item any) bool, len(conditions))
+	for i, condition := range conditions {
+		match, err := compileCondition(condition)
+		if err != nil {
+			return nil, err
+		}
+		matchers[i] = match
+	}
+	return matchers, nil
+}
+
+// lookupField returns the value at the given path within the given item.
+//
+// Path segments index into objects by key, and into arrays by position.
+func lookupField(item any, path []string) (any, bool) {
+	value := item
+	for _, segment := range path {
+		switch v := value.(type) {
+		case map[string]any:
+			var ok bool
+			value, ok = v[segment]
+			if !ok {
+				return nil, false
+			}
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// normalizeJSON returns the given value as it would be decoded from json, e.g. with all numbers
+// as float64.
+func normalizeJSON(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized any
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
@@ -5,7 +5,6 @@
 package config
 
 import (
-	"github.com/lens-vm/lens/host-go/config/internal/json"
 	"github.com/lens-vm/lens/host-go/config/model"
 	"github.com/lens-vm/lens/host-go/engine"
 	"github.com/lens-vm/lens/host-go/engine/module"
@@ -15,89 +14,120 @@ import (
 
 // LoadFromFile loads a lens file at the given path and applies it to the provided src.
 //
+// Json, yaml and toml lens files are supported, see LoadFile for how the format is determined.
+// Included lens files, relative module paths and `file:` URLs without a leading slash are
+// resolved relative to the lens file declaring them.
+//
 // It does not enumerate the src.
-func LoadFromFile[TSource any, TResult any](path string, src enumerable.Enumerable[TSource]) (enumerable.Enumerable[TResult], error) {
-	// We only support json lens files at the moment, so we just trust that it is json.
-	// In the future we'll need to determine which format the file is in.
-	lensConfig, err := json.Load(path)
+func LoadFromFile[TSource any, TResult any](
+	path string,
+	src enumerable.Enumerable[TSource],
+	opts ...Option,
+) (enumerable.Enumerable[TResult], error) {
+	lensConfig, err := loadFlatFile(path, newOptions(opts))
 	if err != nil {
 		return nil, err
 	}
 
-	return Load[TSource, TResult](lensConfig, src)
+	return Load[TSource, TResult](lensConfig, src, opts...)
 }
 
 // Load constructs a lens from the given config and applies it to the provided src.
 //
+// The runtime hosting the lens modules may be selected using WithRuntime, or the LENS_RUNTIME
+// environment variable, otherwise runtimes.Default will be used. Any includes in the config
+// will be flattened, see Flatten, and any variables referenced by it interpolated, see
+// WithVariables.
+//
 // It does not enumerate the src.
-func Load[TSource any, TResult any](lensConfig model.Lens, src enumerable.Enumerable[TSource]) (enumerable.Enumerable[TResult], error) {
-	runtime := runtimes.Default()
+func Load[TSource any, TResult any](
+	lensConfig model.Lens,
+	src enumerable.Enumerable[TSource],
+	opts ...Option,
+) (enumerable.Enumerable[TResult], error) {
+	options := newOptions(opts)
+	runtime, err := runtimes.New(options.runtime, options.runtimeOptions...)
+	if err != nil {
+		return nil, err
+	}
 	modulesByPath := map[string]module.Module{}
 
-	return LoadInto[TSource, TResult](runtime, modulesByPath, lensConfig, src)
+	return LoadInto[TSource, TResult](runtime, modulesByPath, lensConfig, src, opts...)
 }
 
 // LoadIntoFromFile loads a lens file at the given path and applies it to the provided src
 // extending the provided runtime and module cache.
 //
+// Relative paths are resolved relative to the lens file declaring them, see LoadFromFile.
+//
 // It does not enumerate the src. Any new modules will be added to the given module map.
 func LoadIntoFromFile[TSource any, TResult any](
 	runtime module.Runtime,
 	modulesByPath map[string]module.Module,
 	path string,
 	src enumerable.Enumerable[TSource],
+	opts ...Option,
 ) (enumerable.Enumerable[TResult], error) {
-	// We only support json lens files at the moment, so we just trust that it is json.
-	// In the future we'll need to determine which format the file is in.
-	lensConfig, err := json.Load(path)
+	lensConfig, err := loadFlatFile(path, newOptions(opts))
 	if err != nil {
 		return nil, err
 	}
 
-	return LoadInto[TSource, TResult](runtime, modulesByPath, lensConfig, src)
+	return LoadInto[TSource, TResult](runtime, modulesByPath, lensConfig, src, opts...)
 }
 
 // LoadInto constructs a lens from the given config and applies it to the provided src
 // extending the provided runtime and module cache.
 //
+// Errors raised whilst loading a specific lens module will be returned as a *StageError, as will
+// those raised by the module whilst transforming items if WithErrorTrace is provided.
+//
 // It does not enumerate the src. Any new modules will be added to the given module map.
 func LoadInto[TSource any, TResult any](
 	runtime module.Runtime,
 	modulesByPath map[string]module.Module,
 	lensConfig model.Lens,
 	src enumerable.Enumerable[TSource],
+	opts ...Option,
 ) (enumerable.Enumerable[TResult], error) {
-	for _, moduleCfg := range lensConfig.Lenses {
-		// Modules are fairly expensive objects, and they can be reused, so we de-duplicate
-		// the WAT code paths here and make sure we only create unique module objects.
-		if _, ok := modulesByPath[moduleCfg.Path]; ok {
-			continue
-		}
+	l := newLoader(runtime, modulesByPath, newOptions(opts))
+
+	lensConfig, err := l.prepare(lensConfig)
+	if err != nil {
+		return nil, err
+	}
 
-		lensModule, err := engine.NewModule(runtime, moduleCfg.Path)
+	for i, moduleCfg := range lensConfig.Lenses {
+		_, err := l.module(moduleCfg)
 		if err != nil {
-			return nil, err
+			return nil, newStageError(i, moduleCfg, err)
 		}
-		modulesByPath[moduleCfg.Path] = lensModule
 	}
 
+	trace := &errorTrace{}
 	instances := []module.Instance{}
-	for _, moduleCfg := range lensConfig.Lenses {
-		lensModule := modulesByPath[moduleCfg.Path]
-
-		var instance module.Instance
-		var err error
-		if moduleCfg.Inverse {
-			instance, err = engine.NewInverse(lensModule, moduleCfg.Arguments)
-		} else {
-			instance, err = engine.NewInstance(lensModule, moduleCfg.Arguments)
+	for i, moduleCfg := range lensConfig.Lenses {
+		lensModule, err := l.module(moduleCfg)
+		if err != nil {
+			return nil, newStageError(i, moduleCfg, err)
 		}
 
+		instance, err := l.instance(moduleCfg, lensModule)
 		if err != nil {
-			return nil, err
+			return nil, newStageError(i, moduleCfg, err)
+		}
+		if l.options.instanceHook != nil {
+			instance = l.options.instanceHook(i, moduleName(moduleCfg), instance)
+		}
+		if l.options.traceErrors {
+			instance = traceInstance(trace, i, moduleCfg, instance)
 		}
 		instances = append(instances, instance)
 	}
 
-	return engine.Append[TSource, TResult](src, instances...), nil
+	results := engine.Append[TSource, TResult](src, instances...)
+	if l.options.traceErrors {
+		return newTracedEnumerable(trace, results), nil
+	}
+	return results, nil
 }
@@ -0,0 +1,209 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/lens-vm/lens/host-go/config/internal/json"
+	"github.com/lens-vm/lens/host-go/config/internal/lensfile"
+	"github.com/lens-vm/lens/host-go/config/internal/toml"
+	"github.com/lens-vm/lens/host-go/config/internal/yaml"
+	"github.com/lens-vm/lens/host-go/config/model"
+)
+
+// LensFileVersion is the latest lens file format version supported.
+//
+// Lens files may declare the version they were written for using the top-level `version` field,
+// files that do not are assumed to be compatible with the latest version.
+const LensFileVersion = lensfile.Version
+
+// Format is the serialization format of a lens file.
+type Format string
+
+const (
+	// FormatUnknown signals that the format should be detected from the lens file content.
+	FormatUnknown Format = ""
+	FormatJSON    Format = "json"
+	FormatYAML    Format = "yaml"
+	FormatTOML    Format = "toml"
+)
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// tomlTable matches the table and key-value lines that a toml lens file will start with.
+var tomlTable = regexp.MustCompile(`^(\[\[?\s*[A-Za-z0-9_."-]+\s*\]\]?|[A-Za-z0-9_"-]+\s*=)`)
+
+// FormatOf returns the format of a lens file with the given path.
+//
+// The format is determined by the file extension (.json, .yaml, .yml or .toml), if the extension
+// is not recognised FormatUnknown will be returned.
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatUnknown
+	}
+}
+
+// DetectFormat sniffs the format of the given lens file content.
+//
+// Json objects are detected by their leading brace, toml by a leading table header or key-value
+// pair, anything else is assumed to be yaml.
+func DetectFormat(content []byte) Format {
+	content = bytes.TrimPrefix(content, utf8BOM)
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "---") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "{"):
+			return FormatJSON
+		case tomlTable.MatchString(line):
+			return FormatTOML
+		default:
+			return FormatYAML
+		}
+	}
+	return FormatYAML
+}
+
+// Parse parses the given lens file content in the given format.
+//
+// If format is FormatUnknown it will be detected from the content. Invalid content will result
+// in a *ParseError.
+func Parse(content []byte, format Format, opts ...Option) (model.Lens, error) {
+	options := newOptions(opts)
+
+	lens, err := parse(content, format, options.strict)
+	if err != nil {
+		return model.Lens{}, newParseError("", err)
+	}
+	return lens, nil
+}
+
+func parse(content []byte, format Format, strict bool) (model.Lens, error) {
+	content = bytes.TrimPrefix(content, utf8BOM)
+	if format == FormatUnknown {
+		format = DetectFormat(content)
+	}
+
+	switch format {
+	case FormatJSON:
+		return json.Parse(content, strict)
+	case FormatYAML:
+		return yaml.Parse(content, strict)
+	case FormatTOML:
+		return toml.Parse(content, strict)
+	default:
+		return model.Lens{}, fmt.Errorf("unsupported lens file format: %s", format)
+	}
+}
+
+// Marshal returns the lens file content declaring the given lens in the given format.
+func Marshal(lens model.Lens, format Format) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return json.Marshal(lens)
+	case FormatYAML:
+		return yaml.Marshal(lens)
+	case FormatTOML:
+		return toml.Marshal(lens)
+	default:
+		return nil, fmt.Errorf("unsupported lens file format: %s", format)
+	}
+}
+
+// LoadFile reads and parses the lens file at the given path.
+//
+// The format is determined by the file extension, falling back to the content if the extension
+// is not recognised. Invalid content will result in a *ParseError.
+func LoadFile(path string, opts ...Option) (model.Lens, error) {
+	return loadFile(path, newOptions(opts))
+}
+
+func loadFile(path string, options *options) (model.Lens, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return model.Lens{}, err
+	}
+
+	lens, err := parse(content, FormatOf(path), options.strict)
+	if err != nil {
+		return model.Lens{}, newParseError(path, err)
+	}
+	return lens, nil
+}
+
+// ParseError is an error found in the content of a lens file.
+type ParseError struct {
+	// The path of the lens file, empty if the content was not loaded from a file.
+	Path string
+
+	// The 1-based line and column of the invalid entry, zero if unknown.
+	Line   int
+	Column int
+
+	// The JSON pointer (RFC 6901) of the invalid entry, empty if unknown or if the error
+	// concerns the document as a whole.
+	Pointer string
+
+	Err error
+}
+
+var _ error = (*ParseError)(nil)
+
+func newParseError(path string, err error) *ParseError {
+	parseErr := &ParseError{
+		Path: path,
+		Err:  err,
+	}
+
+	var lensFileErr *lensfile.Error
+	if errors.As(err, &lensFileErr) {
+		parseErr.Line = lensFileErr.Line
+		parseErr.Column = lensFileErr.Column
+		parseErr.Pointer = lensFileErr.Pointer
+		parseErr.Err = lensFileErr.Err
+	}
+
+	return parseErr
+}
+
+func (e *ParseError) Error() string {
+	var sb strings.Builder
+	if e.Path != "" {
+		sb.WriteString(e.Path)
+		if e.Line > 0 {
+			fmt.Fprintf(&sb, ":%d:%d", e.Line, e.Column)
+		}
+		sb.WriteString(": ")
+	} else if e.Line > 0 {
+		fmt.Fprintf(&sb, "%d:%d: ", e.Line, e.Column)
+	}
+	if e.Pointer != "" {
+		sb.WriteString(e.Pointer)
+		sb.WriteString(": ")
+	}
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
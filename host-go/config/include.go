@@ -0,0 +1,151 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lens-vm/lens/host-go/config/model"
+)
+
+// Flatten returns a copy of the given lens with every include entry replaced by the lenses
+// of the included lens file.
+//
+// The named modules referenced by the lens entries are resolved, each lens file's module
+// declarations only being visible to its own entries. Includes are resolved recursively,
+// relative include paths are resolved against baseDir at the top level and against the directory
+// of the including lens file thereafter. An error will be returned if a lens file includes
+// itself, directly or indirectly.
+func Flatten(lensConfig model.Lens, baseDir string, opts ...Option) (model.Lens, error) {
+	return flatten(lensConfig, baseDir, nil, newOptions(opts))
+}
+
+// loadFlatFile reads the lens file at the given path and flattens its includes.
+//
+// Relative module paths are resolved against the directory of the lens file declaring them, so
+// that lens files may be moved around along with their modules. This includes paths that are only
+// relative once their variables have been interpolated.
+func loadFlatFile(path string, options *options) (model.Lens, error) {
+	fileOptions := *options
+	fileOptions.resolvePaths = true
+	options = &fileOptions
+
+	lensConfig, err := loadFile(path, options)
+	if err != nil {
+		return model.Lens{}, err
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return model.Lens{}, err
+	}
+
+	return flatten(lensConfig, filepath.Dir(path), []string{path}, options)
+}
+
+// flatten flattens the given lens, visiting holds the absolute paths of the lens files that
+// are currently being included.
+func flatten(lensConfig model.Lens, baseDir string, visiting []string, options *options) (model.Lens, error) {
+	lensConfig, err := resolveModules(lensConfig)
+	if err != nil {
+		return model.Lens{}, err
+	}
+
+	lenses := make([]model.LensModule, 0, len(lensConfig.Lenses))
+	for _, moduleCfg := range lensConfig.Lenses {
+		if moduleCfg.Include == "" {
+			if options.resolvePaths {
+				path, err := options.interpolateString(moduleCfg.Path)
+				if err != nil {
+					return model.Lens{}, err
+				}
+				// The path will be interpolated again along with the rest of the lens, so it must
+				// be escaped to be left as it is.
+				moduleCfg.Path = escapeVariables(resolveModulePath(path, baseDir))
+			}
+			lenses = append(lenses, moduleCfg)
+			continue
+		}
+		if moduleCfg.Path != "" || moduleCfg.Wat != "" {
+			return model.Lens{}, fmt.Errorf("lens entry may not declare both an include (%s) and a module", moduleCfg.Include)
+		}
+		if moduleCfg.When != nil {
+			return model.Lens{}, fmt.Errorf("lens entry may not declare a when condition on an include (%s)", moduleCfg.Include)
+		}
+
+		includePath := moduleCfg.Include
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(baseDir, includePath)
+		}
+		includePath, err := filepath.Abs(includePath)
+		if err != nil {
+			return model.Lens{}, err
+		}
+
+		if slices.Contains(visiting, includePath) {
+			cycle := append(slices.Clip(visiting), includePath)
+			return model.Lens{}, fmt.Errorf("lens file include cycle: %s", strings.Join(cycle, " -> "))
+		}
+
+		included, err := loadFile(includePath, options)
+		if err != nil {
+			return model.Lens{}, err
+		}
+
+		included, err = flatten(included, filepath.Dir(includePath), append(slices.Clip(visiting), includePath), options)
+		if err != nil {
+			return model.Lens{}, err
+		}
+
+		if moduleCfg.Inverse {
+			included = Inverse(included)
+		}
+		lenses = append(lenses, included.Lenses...)
+	}
+
+	return model.Lens{
+		Lenses: lenses,
+	}, nil
+}
+
+// resolveModulePath returns the given interpolated module path resolved against the given
+// directory if it is a relative local path, or a `file:` URL without a leading slash.
+func resolveModulePath(path string, baseDir string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return path
+	}
+	switch {
+	case u.Scheme == "":
+		return filepath.Join(baseDir, filepath.FromSlash(path))
+	case strings.EqualFold(u.Scheme, "file") && u.Opaque != "":
+		return filepath.Join(baseDir, filepath.FromSlash(u.Opaque))
+	default:
+		return path
+	}
+}
+
+// Inverse returns the inverse of the given lens, the order of its entries is reversed and each
+// of them is inversed.
+//
+// The lens does not need to have been flattened, included lenses are inversed as a whole.
+func Inverse(lensConfig model.Lens) model.Lens {
+	lenses := make([]model.LensModule, len(lensConfig.Lenses))
+	for i, moduleCfg := range lensConfig.Lenses {
+		moduleCfg.Inverse = !moduleCfg.Inverse
+		lenses[len(lenses)-1-i] = moduleCfg
+	}
+
+	lensConfig.Lenses = lenses
+	return lensConfig
+}
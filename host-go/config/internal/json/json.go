@@ -5,44 +5,123 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
-	"os"
+	"errors"
+	"strconv"
 
+	"github.com/lens-vm/lens/host-go/config/internal/lensfile"
 	"github.com/lens-vm/lens/host-go/config/model"
 )
 
-type Lens struct {
-	Lenses []LensModule `json:"lenses"`
+// Parse parses the given json lens file content.
+//
+// If strict is true, unknown fields will result in an error.
+func Parse(lensFileJson []byte, strict bool) (model.Lens, error) {
+	var document any
+	err := json.Unmarshal(lensFileJson, &document)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, column := lensfile.Position(lensFileJson, syntaxErr.Offset)
+			return model.Lens{}, &lensfile.Error{
+				Line:   line,
+				Column: column,
+				Err:    err,
+			}
+		}
+		return model.Lens{}, err
+	}
+
+	return lensfile.Decode(document, strict, func(pointer string) (int, int) {
+		return locate(lensFileJson, pointer)
+	})
 }
 
-type LensModule struct {
-	Path      string         `json:"path"`
-	Inverse   bool           `json:"inverse"`
-	Arguments map[string]any `json:"arguments"`
+// frame is an object or array that is currently being walked by locate.
+type frame struct {
+	pointer string
+	object  bool
+
+	// The number of items of the array walked so far.
+	index int
+	// The pointer of the object field whose value is expected next, empty if a key is expected.
+	field string
 }
 
-func Load(path string) (model.Lens, error) {
-	lensFileJson, err := os.ReadFile(path)
-	if err != nil {
-		return model.Lens{}, err
-	}
+// locate returns the line and column of the entry at the given pointer within the given json.
+//
+// Object fields are located by their key, array items by their value.
+func locate(content []byte, pointer string) (int, int) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	stack := []*frame{}
 
-	var lensFile Lens
-	err = json.Unmarshal(lensFileJson, &lensFile)
-	if err != nil {
-		return model.Lens{}, err
+	for {
+		offset := tokenStart(content, decoder.InputOffset())
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, 0
+		}
+		delim, isDelim := token.(json.Delim)
+
+		var valuePointer string
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if isDelim && (delim == '}' || delim == ']') {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+
+			if top.object {
+				if top.field == "" {
+					key, _ := token.(string)
+					top.field = lensfile.Pointer(top.pointer, key)
+					if top.field == pointer {
+						return lensfile.Position(content, offset)
+					}
+					continue
+				}
+				valuePointer = top.field
+				top.field = ""
+			} else {
+				valuePointer = lensfile.Pointer(top.pointer, strconv.Itoa(top.index))
+				top.index++
+				if valuePointer == pointer {
+					return lensfile.Position(content, offset)
+				}
+			}
+		} else if pointer == "" {
+			return lensfile.Position(content, offset)
+		}
+
+		if isDelim {
+			stack = append(stack, &frame{
+				pointer: valuePointer,
+				object:  delim == '{',
+			})
+		}
 	}
+}
 
-	lenses := make([]model.LensModule, len(lensFile.Lenses))
-	for i, lensModule := range lensFile.Lenses {
-		lenses[i] = model.LensModule{
-			Path:      lensModule.Path,
-			Inverse:   lensModule.Inverse,
-			Arguments: lensModule.Arguments,
+// tokenStart returns the offset of the start of the token following the given offset, skipping
+// any whitespace and separators.
+func tokenStart(content []byte, offset int64) int64 {
+	for offset < int64(len(content)) {
+		switch content[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
 		}
 	}
+	return offset
+}
 
-	return model.Lens{
-		Lenses: lenses,
-	}, nil
+// Marshal returns the json lens file content declaring the given lens.
+func Marshal(lens model.Lens) ([]byte, error) {
+	content, err := json.MarshalIndent(lensfile.Encode(lens), "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
 }
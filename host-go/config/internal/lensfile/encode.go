@@ -0,0 +1,90 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package lensfile
+
+import (
+	"github.com/lens-vm/lens/host-go/config/model"
+)
+
+// Encode converts the given lens into the generic document from which it would be decoded, unset
+// fields are omitted.
+func Encode(lens model.Lens) map[string]any {
+	document := map[string]any{}
+	if lens.Version != 0 {
+		document["version"] = lens.Version
+	}
+
+	if len(lens.Modules) > 0 {
+		modules := map[string]any{}
+		for name, declaration := range lens.Modules {
+			fields := map[string]any{}
+			setString(fields, "path", declaration.Path)
+			setString(fields, "hash", declaration.Hash)
+			setString(fields, "wat", declaration.Wat)
+			setString(fields, "runtime", declaration.Runtime)
+			modules[name] = fields
+		}
+		document["modules"] = modules
+	}
+
+	lenses := make([]any, 0, len(lens.Lenses))
+	for _, lensModule := range lens.Lenses {
+		fields := map[string]any{}
+		setString(fields, "module", lensModule.Module)
+		setString(fields, "path", lensModule.Path)
+		setString(fields, "hash", lensModule.Hash)
+		setString(fields, "wat", lensModule.Wat)
+		setString(fields, "include", lensModule.Include)
+		setString(fields, "runtime", lensModule.Runtime)
+		if lensModule.Inverse {
+			fields["inverse"] = true
+		}
+		if lensModule.When != nil {
+			fields["when"] = encodeCondition(*lensModule.When)
+		}
+		if len(lensModule.Arguments) > 0 {
+			fields["arguments"] = lensModule.Arguments
+		}
+		lenses = append(lenses, fields)
+	}
+	document["lenses"] = lenses
+
+	return document
+}
+
+func encodeCondition(condition model.Condition) map[string]any {
+	fields := map[string]any{}
+	setString(fields, "field", condition.Field)
+	if condition.Equals != nil {
+		fields["equals"] = condition.Equals
+	}
+	if condition.Exists != nil {
+		fields["exists"] = *condition.Exists
+	}
+	if len(condition.All) > 0 {
+		fields["all"] = encodeConditions(condition.All)
+	}
+	if len(condition.Any) > 0 {
+		fields["any"] = encodeConditions(condition.Any)
+	}
+	if condition.Not != nil {
+		fields["not"] = encodeCondition(*condition.Not)
+	}
+	return fields
+}
+
+func encodeConditions(conditions []model.Condition) []any {
+	result := make([]any, 0, len(conditions))
+	for _, condition := range conditions {
+		result = append(result, encodeCondition(condition))
+	}
+	return result
+}
+
+func setString(fields map[string]any, key string, value string) {
+	if value != "" {
+		fields[key] = value
+	}
+}
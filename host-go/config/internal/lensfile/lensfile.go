@@ -0,0 +1,464 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+/*
+Package lensfile converts the generic documents produced by the format specific lens file parsers
+into a model.Lens, validating their structure along the way.
+*/
+package lensfile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lens-vm/lens/host-go/config/model"
+)
+
+// Version is the latest lens file format version supported.
+const Version = 1
+
+// Error is an error found at a specific entry of a lens file.
+type Error struct {
+	// The 1-based line and column of the entry within the lens file, zero if unknown.
+	Line   int
+	Column int
+
+	// The JSON pointer (RFC 6901) of the entry within the lens file.
+	Pointer string
+
+	Err error
+}
+
+var _ error = (*Error)(nil)
+
+func (e *Error) Error() string {
+	var sb strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&sb, "%d:%d: ", e.Line, e.Column)
+	}
+	if e.Pointer != "" {
+		sb.WriteString(e.Pointer)
+		sb.WriteString(": ")
+	}
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Locator returns the line and column of the entry at the given JSON pointer, or zeros if
+// it cannot be located.
+type Locator func(pointer string) (line int, column int)
+
+// Pointer appends the given reference tokens to the given JSON pointer.
+func Pointer(pointer string, tokens ...string) string {
+	for _, token := range tokens {
+		token = strings.ReplaceAll(token, "~", "~0")
+		token = strings.ReplaceAll(token, "/", "~1")
+		pointer += "/" + token
+	}
+	return pointer
+}
+
+// Decode converts the given generic document into a model.Lens.
+//
+// If strict is true, unknown fields will result in an error. locate is used to find the position
+// of invalid entries, it may be nil.
+func Decode(document any, strict bool, locate Locator) (model.Lens, error) {
+	d := decoder{
+		strict: strict,
+		locate: locate,
+	}
+	return d.decodeLens(document)
+}
+
+type decoder struct {
+	strict bool
+	locate Locator
+}
+
+func (d *decoder) errorf(pointer string, format string, args ...any) error {
+	err := &Error{
+		Pointer: pointer,
+		Err:     fmt.Errorf(format, args...),
+	}
+	if d.locate != nil {
+		err.Line, err.Column = d.locate(pointer)
+	}
+	return err
+}
+
+// The fields of each of the objects making up a lens file.
+var (
+	lensFields        = []string{"version", "modules", "lenses"}
+	lensModuleFields  = []string{"module", "path", "hash", "wat", "include", "runtime", "inverse", "when", "arguments"}
+	declarationFields = []string{"path", "hash", "wat", "runtime"}
+	conditionFields   = []string{"field", "equals", "exists", "all", "any", "not"}
+)
+
+// fieldName returns the name of the field, out of the given known fields, that the given key
+// refers to, or an empty string if the key is unknown and should be ignored.
+//
+// Keys are matched case-insensitively, as they were when lens files were decoded by
+// encoding/json, unless strict in which case the key must match exactly.
+func (d *decoder) fieldName(pointer string, key string, known []string) (string, error) {
+	for _, name := range known {
+		if key == name {
+			return name, nil
+		}
+	}
+
+	for _, name := range known {
+		if strings.EqualFold(key, name) {
+			if d.strict {
+				return "", d.errorf(pointer, "unknown field %q, did you mean %q", key, name)
+			}
+			return name, nil
+		}
+	}
+
+	if d.strict {
+		return "", d.errorf(pointer, "unknown field %q", key)
+	}
+	return "", nil
+}
+
+func (d *decoder) decodeLens(document any) (model.Lens, error) {
+	if document == nil {
+		return model.Lens{}, nil
+	}
+
+	fields, ok := document.(map[string]any)
+	if !ok {
+		return model.Lens{}, d.errorf("", "expected an object, got %s", describe(document))
+	}
+
+	lens := model.Lens{}
+	for _, key := range sortedKeys(fields) {
+		pointer := Pointer("", key)
+		value := fields[key]
+
+		name, err := d.fieldName(pointer, key, lensFields)
+		if err != nil {
+			return model.Lens{}, err
+		}
+
+		switch name {
+		case "version":
+			version, err := d.decodeInt(pointer, value)
+			if err != nil {
+				return model.Lens{}, err
+			}
+			if version < 1 || version > Version {
+				return model.Lens{}, d.errorf(
+					pointer,
+					"unsupported lens file version %d, the latest supported version is %d",
+					version,
+					Version,
+				)
+			}
+			lens.Version = version
+
+		case "modules":
+			modules, err := d.decodeModules(pointer, value)
+			if err != nil {
+				return model.Lens{}, err
+			}
+			lens.Modules = modules
+
+		case "lenses":
+			items, err := d.decodeList(pointer, value)
+			if err != nil {
+				return model.Lens{}, err
+			}
+			lens.Lenses = make([]model.LensModule, len(items))
+			for i, item := range items {
+				lens.Lenses[i], err = d.decodeLensModule(Pointer(pointer, strconv.Itoa(i)), item)
+				if err != nil {
+					return model.Lens{}, err
+				}
+			}
+		}
+	}
+
+	return lens, nil
+}
+
+func (d *decoder) decodeLensModule(pointer string, value any) (model.LensModule, error) {
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return model.LensModule{}, d.errorf(pointer, "expected an object, got %s", describe(value))
+	}
+
+	lensModule := model.LensModule{}
+	for _, key := range sortedKeys(fields) {
+		fieldPointer := Pointer(pointer, key)
+		value := fields[key]
+
+		name, err := d.fieldName(fieldPointer, key, lensModuleFields)
+		if err != nil {
+			return model.LensModule{}, err
+		}
+
+		switch name {
+		case "module":
+			lensModule.Module, err = d.decodeString(fieldPointer, value)
+		case "path":
+			lensModule.Path, err = d.decodeString(fieldPointer, value)
+		case "hash":
+			lensModule.Hash, err = d.decodeString(fieldPointer, value)
+		case "wat":
+			lensModule.Wat, err = d.decodeString(fieldPointer, value)
+		case "include":
+			lensModule.Include, err = d.decodeString(fieldPointer, value)
+		case "runtime":
+			lensModule.Runtime, err = d.decodeString(fieldPointer, value)
+		case "inverse":
+			lensModule.Inverse, err = d.decodeBool(fieldPointer, value)
+		case "when":
+			lensModule.When, err = d.decodeCondition(fieldPointer, value)
+		case "arguments":
+			lensModule.Arguments, err = d.decodeArguments(fieldPointer, value)
+		}
+		if err != nil {
+			return model.LensModule{}, err
+		}
+	}
+
+	return lensModule, nil
+}
+
+func (d *decoder) decodeModules(pointer string, value any) (map[string]model.ModuleDeclaration, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	declarations, ok := value.(map[string]any)
+	if !ok {
+		return nil, d.errorf(pointer, "expected an object, got %s", describe(value))
+	}
+
+	modules := make(map[string]model.ModuleDeclaration, len(declarations))
+	for _, name := range sortedKeys(declarations) {
+		declarationPointer := Pointer(pointer, name)
+		fields, ok := declarations[name].(map[string]any)
+		if !ok {
+			return nil, d.errorf(declarationPointer, "expected an object, got %s", describe(declarations[name]))
+		}
+
+		declaration := model.ModuleDeclaration{}
+		for _, key := range sortedKeys(fields) {
+			fieldPointer := Pointer(declarationPointer, key)
+			value := fields[key]
+
+			name, err := d.fieldName(fieldPointer, key, declarationFields)
+			if err != nil {
+				return nil, err
+			}
+
+			switch name {
+			case "path":
+				declaration.Path, err = d.decodeString(fieldPointer, value)
+			case "hash":
+				declaration.Hash, err = d.decodeString(fieldPointer, value)
+			case "wat":
+				declaration.Wat, err = d.decodeString(fieldPointer, value)
+			case "runtime":
+				declaration.Runtime, err = d.decodeString(fieldPointer, value)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		modules[name] = declaration
+	}
+
+	return modules, nil
+}
+
+func (d *decoder) decodeCondition(pointer string, value any) (*model.Condition, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return nil, d.errorf(pointer, "expected an object, got %s", describe(value))
+	}
+
+	condition := &model.Condition{}
+	for _, key := range sortedKeys(fields) {
+		fieldPointer := Pointer(pointer, key)
+		value := fields[key]
+
+		name, err := d.fieldName(fieldPointer, key, conditionFields)
+		if err != nil {
+			return nil, err
+		}
+
+		switch name {
+		case "field":
+			condition.Field, err = d.decodeString(fieldPointer, value)
+		case "equals":
+			condition.Equals = value
+		case "exists":
+			var exists bool
+			exists, err = d.decodeBool(fieldPointer, value)
+			condition.Exists = &exists
+		case "all":
+			condition.All, err = d.decodeConditions(fieldPointer, value)
+		case "any":
+			condition.Any, err = d.decodeConditions(fieldPointer, value)
+		case "not":
+			condition.Not, err = d.decodeCondition(fieldPointer, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if condition.Field == "" && (condition.Equals != nil || condition.Exists != nil) {
+		return nil, d.errorf(pointer, "condition declares equals or exists without a field")
+	}
+
+	return condition, nil
+}
+
+func (d *decoder) decodeConditions(pointer string, value any) ([]model.Condition, error) {
+	items, err := d.decodeList(pointer, value)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := make([]model.Condition, len(items))
+	for i, item := range items {
+		itemPointer := Pointer(pointer, strconv.Itoa(i))
+		if item == nil {
+			return nil, d.errorf(itemPointer, "expected an object, got null")
+		}
+		condition, err := d.decodeCondition(itemPointer, item)
+		if err != nil {
+			return nil, err
+		}
+		conditions[i] = *condition
+	}
+	return conditions, nil
+}
+
+func (d *decoder) decodeList(pointer string, value any) ([]any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		return v, nil
+	case []map[string]any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, d.errorf(pointer, "expected a list, got %s", describe(value))
+	}
+}
+
+func (d *decoder) decodeArguments(pointer string, value any) (map[string]any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		return v, nil
+	default:
+		return nil, d.errorf(pointer, "expected an object, got %s", describe(value))
+	}
+}
+
+func (d *decoder) decodeString(pointer string, value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		return "", d.errorf(pointer, "expected a string, got %s", describe(value))
+	}
+}
+
+func (d *decoder) decodeBool(pointer string, value any) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	default:
+		return false, d.errorf(pointer, "expected a boolean, got %s", describe(value))
+	}
+}
+
+func (d *decoder) decodeInt(pointer string, value any) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			return int(v), nil
+		}
+	case uint64:
+		if v <= math.MaxInt32 {
+			return int(v), nil
+		}
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32 {
+			return int(v), nil
+		}
+	}
+	return 0, d.errorf(pointer, "expected an integer, got %s", describe(value))
+}
+
+func describe(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v)
+	case []any, []map[string]any:
+		return "a list"
+	case map[string]any:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func sortedKeys(fields map[string]any) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Position returns the 1-based line and column of the given byte offset within content.
+func Position(content []byte, offset int64) (line int, column int) {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	line = 1
+	lineStart := int64(0)
+	for i := int64(0); i < offset; i++ {
+		if content[i] == '\n' {
+			line++
+			lineStart = i + 1
+		}
+	}
+	return line, int(offset-lineStart) + 1
+}
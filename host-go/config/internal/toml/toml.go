@@ -0,0 +1,136 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package toml
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/lens-vm/lens/host-go/config/internal/lensfile"
+	"github.com/lens-vm/lens/host-go/config/model"
+)
+
+// Parse parses the given toml lens file content.
+//
+// If strict is true, unknown fields will result in an error.
+func Parse(lensFileToml []byte, strict bool) (model.Lens, error) {
+	var document map[string]any
+	err := toml.Unmarshal(lensFileToml, &document)
+	if err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			line, column := lensfile.Position(lensFileToml, int64(parseErr.Position.Start))
+			if parseErr.Message != "" {
+				err = errors.New(parseErr.Message)
+			}
+			return model.Lens{}, &lensfile.Error{
+				Line:   line,
+				Column: column,
+				Err:    err,
+			}
+		}
+		return model.Lens{}, err
+	}
+
+	return lensfile.Decode(document, strict, func(pointer string) (int, int) {
+		return locate(lensFileToml, pointer)
+	})
+}
+
+// locate returns the line and column of the entry at the given pointer within the given toml.
+//
+// The toml decoder does not expose the position of keys, so lens entries are located by
+// scanning for their `[[lenses]]` table headers and fields by scanning for their keys. Entries
+// declared using inline tables cannot be located.
+func locate(content []byte, pointer string) (int, int) {
+	if pointer == "" {
+		return 1, 1
+	}
+
+	lines := strings.Split(string(content), "\n")
+	tokens := strings.Split(pointer, "/")[1:]
+
+	// The range of lines within which the key will be declared.
+	start, end := 0, len(lines)
+	for i, line := range lines {
+		if isTableHeader(line) {
+			end = i
+			break
+		}
+	}
+
+	key := tokens[0]
+	if key == "lenses" && len(tokens) > 1 {
+		index, err := strconv.Atoi(tokens[1])
+		if err != nil {
+			return 0, 0
+		}
+
+		start = -1
+		for i, line := range lines {
+			if start >= 0 && isTableHeader(line) {
+				end = i
+				break
+			}
+			if !isLensesHeader(line) {
+				continue
+			}
+			if index == 0 {
+				start, end = i+1, len(lines)
+				if len(tokens) == 2 {
+					return i + 1, indent(line) + 1
+				}
+			}
+			index--
+		}
+		if start < 0 {
+			return 0, 0
+		}
+		key = tokens[2]
+	}
+
+	for i := start; i < end; i++ {
+		line := strings.TrimSpace(lines[i])
+		for _, k := range []string{key, strconv.Quote(key)} {
+			rest, ok := strings.CutPrefix(line, k)
+			if ok && strings.HasPrefix(strings.TrimSpace(rest), "=") {
+				return i + 1, indent(lines[i]) + 1
+			}
+		}
+	}
+
+	return 0, 0
+}
+
+func isTableHeader(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "[")
+}
+
+func isLensesHeader(line string) bool {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[[") {
+		return false
+	}
+	name, _, _ := strings.Cut(strings.TrimPrefix(line, "[["), "]]")
+	name = strings.TrimSpace(name)
+	return name == "lenses" || name == `"lenses"`
+}
+
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// Marshal returns the toml lens file content declaring the given lens.
+func Marshal(lens model.Lens) ([]byte, error) {
+	var content bytes.Buffer
+	err := toml.NewEncoder(&content).Encode(lensfile.Encode(lens))
+	if err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
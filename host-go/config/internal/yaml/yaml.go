@@ -0,0 +1,82 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package yaml
+
+import (
+	"strconv"
+
+	"github.com/lens-vm/lens/host-go/config/internal/lensfile"
+	"github.com/lens-vm/lens/host-go/config/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Parse parses the given yaml lens file content.
+//
+// If strict is true, unknown fields will result in an error.
+func Parse(lensFileYaml []byte, strict bool) (model.Lens, error) {
+	var root yaml.Node
+	err := yaml.Unmarshal(lensFileYaml, &root)
+	if err != nil {
+		return model.Lens{}, err
+	}
+
+	var document any
+	err = root.Decode(&document)
+	if err != nil {
+		return model.Lens{}, err
+	}
+
+	return lensfile.Decode(document, strict, func(pointer string) (int, int) {
+		return locate(&root, "", pointer)
+	})
+}
+
+// locate returns the line and column of the entry at the given pointer within the given node.
+//
+// Mapping entries are located by their key, sequence items by their value.
+func locate(node *yaml.Node, nodePointer string, pointer string) (int, int) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return 0, 0
+		}
+		if pointer == "" {
+			return node.Content[0].Line, node.Content[0].Column
+		}
+		return locate(node.Content[0], nodePointer, pointer)
+
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			keyPointer := lensfile.Pointer(nodePointer, key.Value)
+			if keyPointer == pointer {
+				return key.Line, key.Column
+			}
+			line, column := locate(node.Content[i+1], keyPointer, pointer)
+			if line > 0 {
+				return line, column
+			}
+		}
+
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			itemPointer := lensfile.Pointer(nodePointer, strconv.Itoa(i))
+			if itemPointer == pointer {
+				return item.Line, item.Column
+			}
+			line, column := locate(item, itemPointer, pointer)
+			if line > 0 {
+				return line, column
+			}
+		}
+	}
+
+	return 0, 0
+}
+
+// Marshal returns the yaml lens file content declaring the given lens.
+func Marshal(lens model.Lens) ([]byte, error) {
+	return yaml.Marshal(lensfile.Encode(lens))
+}
@@ -0,0 +1,136 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lens-vm/lens/host-go/config/model"
+)
+
+// interpolate returns a copy of the given lens with the `${name}` variable references in its
+// module paths and argument values replaced by their values.
+func (o *options) interpolate(lensConfig model.Lens) (model.Lens, error) {
+	lenses := make([]model.LensModule, len(lensConfig.Lenses))
+	for i, moduleCfg := range lensConfig.Lenses {
+		path, err := o.interpolateString(moduleCfg.Path)
+		if err != nil {
+			return model.Lens{}, err
+		}
+		moduleCfg.Path = path
+
+		if moduleCfg.Arguments != nil {
+			arguments, err := o.interpolateValue(moduleCfg.Arguments)
+			if err != nil {
+				return model.Lens{}, err
+			}
+			moduleCfg.Arguments = arguments.(map[string]any)
+		}
+
+		lenses[i] = moduleCfg
+	}
+
+	return model.Lens{
+		Lenses: lenses,
+	}, nil
+}
+
+// interpolateValue interpolates the strings found within the given argument value, maps and
+// slices are copied rather than modified in place.
+func (o *options) interpolateValue(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return o.interpolateString(v)
+
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			interpolated, err := o.interpolateValue(item)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = interpolated
+		}
+		return result, nil
+
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			interpolated, err := o.interpolateValue(item)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = interpolated
+		}
+		return result, nil
+
+	default:
+		return value, nil
+	}
+}
+
+// escapeVariables escapes the `${` found in the given string, so that interpolating it yields the
+// string as it is.
+func escapeVariables(s string) string {
+	return strings.ReplaceAll(s, "${", "$${")
+}
+
+// interpolateString replaces the `${name}` references in the given string.
+//
+// `$${` may be used to escape a literal `${`.
+func (o *options) interpolateString(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+
+		if start > 0 && s[start-1] == '$' {
+			sb.WriteString(s[:start-1])
+			sb.WriteString("${")
+			s = s[start+2:]
+			continue
+		}
+
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable reference: %s", s[start:])
+		}
+		end += start
+
+		sb.WriteString(s[:start])
+		name := s[start+2 : end]
+		value, ok := o.lookupVariable(name)
+		switch {
+		case ok:
+			sb.WriteString(value)
+		case o.strictVariables:
+			return "", fmt.Errorf("undefined variable: %s", name)
+		default:
+			sb.WriteString(s[start : end+1])
+		}
+		s = s[end+1:]
+	}
+}
+
+// lookupVariable returns the value of the variable with the given name, falling back to the
+// environment if enabled.
+func (o *options) lookupVariable(name string) (string, bool) {
+	if value, ok := o.variables[name]; ok {
+		return value, true
+	}
+	if o.envVariables {
+		return os.LookupEnv(name)
+	}
+	return "", false
+}
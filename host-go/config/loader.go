@@ -0,0 +1,190 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/lens-vm/lens/host-go/engine"
+	"github.com/lens-vm/lens/host-go/engine/cache"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/runtimes"
+)
+
+// loader creates the modules and instances declared by a lens.
+type loader struct {
+	options *options
+
+	// runtime hosts the modules that do not declare their own runtime.
+	runtime module.Runtime
+	// runtimesByName holds the runtimes of the modules declaring their own runtime, they are
+	// created on first use and shared by all of the modules declaring them.
+	runtimesByName map[string]module.Runtime
+
+	modulesByPath map[string]module.Module
+}
+
+func newLoader(runtime module.Runtime, modulesByPath map[string]module.Module, options *options) *loader {
+	return &loader{
+		options:        options,
+		runtime:        runtime,
+		runtimesByName: map[string]module.Runtime{},
+		modulesByPath:  modulesByPath,
+	}
+}
+
+// prepare flattens the given lens, interpolates its variables, and applies any argument
+// overrides and inversion.
+func (l *loader) prepare(lensConfig model.Lens) (model.Lens, error) {
+	lensConfig, err := flatten(lensConfig, l.options.baseDir, nil, l.options)
+	if err != nil {
+		return model.Lens{}, err
+	}
+
+	lensConfig, err = l.options.interpolate(lensConfig)
+	if err != nil {
+		return model.Lens{}, err
+	}
+
+	lensConfig, err = l.options.overrideArguments(lensConfig)
+	if err != nil {
+		return model.Lens{}, err
+	}
+
+	if l.options.inverse {
+		lensConfig = Inverse(lensConfig)
+	}
+	return lensConfig, nil
+}
+
+// overrideArguments returns a copy of the given lens with the argument overrides applied.
+func (o *options) overrideArguments(lensConfig model.Lens) (model.Lens, error) {
+	if len(o.arguments) == 0 {
+		return lensConfig, nil
+	}
+
+	lenses := slices.Clone(lensConfig.Lenses)
+	for index, arguments := range o.arguments {
+		if index < 0 || index >= len(lenses) {
+			return model.Lens{}, fmt.Errorf(
+				"cannot override the arguments of lenses[%d], the lens has %d entries",
+				index,
+				len(lenses),
+			)
+		}
+
+		// The declared arguments may be shared with the caller's lens, so they are copied
+		// rather than modified.
+		merged := maps.Clone(lenses[index].Arguments)
+		if merged == nil {
+			merged = map[string]any{}
+		}
+		maps.Copy(merged, arguments)
+		lenses[index].Arguments = merged
+	}
+
+	lensConfig.Lenses = lenses
+	return lensConfig, nil
+}
+
+// runtimeFor returns the runtime that should host the given lens module.
+func (l *loader) runtimeFor(moduleCfg model.LensModule) (module.Runtime, error) {
+	if moduleCfg.Runtime == "" {
+		return l.runtime, nil
+	}
+	if runtime, ok := l.runtimesByName[moduleCfg.Runtime]; ok {
+		return runtime, nil
+	}
+
+	runtime, err := runtimes.New(moduleCfg.Runtime, l.options.runtimeOptions...)
+	if err != nil {
+		return nil, err
+	}
+	l.runtimesByName[moduleCfg.Runtime] = runtime
+	return runtime, nil
+}
+
+// module returns the module of the given lens module, creating it if it has not already been
+// created.
+func (l *loader) module(moduleCfg model.LensModule) (module.Module, error) {
+	// Modules are fairly expensive objects, and they can be reused, so we de-duplicate
+	// the WAT code paths here and make sure we only create unique module objects.
+	key, err := moduleKey(moduleCfg)
+	if err != nil {
+		return nil, err
+	}
+	if lensModule, ok := l.modulesByPath[key]; ok {
+		return lensModule, nil
+	}
+
+	runtime, err := l.runtimeFor(moduleCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var lensModule module.Module
+	if moduleCfg.Wat != "" {
+		lensModule, err = runtime.NewModule([]byte(moduleCfg.Wat))
+	} else {
+		lensModule, err = engine.NewModule(runtime, moduleCfg.Path, l.options.moduleOptions(moduleCfg.Hash)...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l.modulesByPath[key] = lensModule
+	return lensModule, nil
+}
+
+// instance returns a new instance of the given lens module, only transforming the items matching
+// its condition if it declares one.
+func (l *loader) instance(moduleCfg model.LensModule, lensModule module.Module) (module.Instance, error) {
+	instance, err := newInstance(moduleCfg, lensModule)
+	if err != nil {
+		return module.Instance{}, err
+	}
+
+	if moduleCfg.When != nil {
+		predicate, err := NewPredicate(*moduleCfg.When)
+		if err != nil {
+			return module.Instance{}, err
+		}
+		instance = engine.NewConditional(instance, predicate)
+	}
+
+	return instance, nil
+}
+
+// newInstance returns a new instance of the given lens module, running its inverse if the lens
+// module is inversed.
+func newInstance(moduleCfg model.LensModule, lensModule module.Module) (module.Instance, error) {
+	if moduleCfg.Inverse {
+		return engine.NewInverse(lensModule, moduleCfg.Arguments)
+	}
+	return engine.NewInstance(lensModule, moduleCfg.Arguments)
+}
+
+// moduleKey returns the key under which the module described by the given config is stored
+// in the module map.
+//
+// Inline WAT modules are keyed by the digest of their source, so that identical inline modules
+// are only compiled once. Modules hosted by a runtime other than the one the lens is loaded into
+// are keyed by their runtime too, as modules cannot be shared across runtimes.
+func moduleKey(moduleCfg model.LensModule) (string, error) {
+	key := moduleCfg.Path
+	if moduleCfg.Wat != "" {
+		if moduleCfg.Path != "" {
+			return "", fmt.Errorf("lens module may not declare both a path (%s) and inline wat", moduleCfg.Path)
+		}
+		key = "wat:" + cache.Digest([]byte(moduleCfg.Wat))
+	}
+	if moduleCfg.Runtime != "" {
+		key += "#runtime=" + moduleCfg.Runtime
+	}
+	return key, nil
+}
@@ -9,22 +9,106 @@ of a given lens file.
 package model
 
 type Lens struct {
+	// The version of the lens file format the lens was declared with.
+	//
+	// Zero if the lens file did not declare a version.
+	Version int
+
+	// The modules available to the lenses, keyed by the name with which they may be referenced.
+	Modules map[string]ModuleDeclaration
+
 	// The LensModules that should be applied to the source data, declared in the order
 	// in which they should be executed.
 	Lenses []LensModule
 }
 
+// ModuleDeclaration declares a named module that may be referenced by any number of lens entries.
+type ModuleDeclaration struct {
+	// The path to the wasm binary containing the lens transform.
+	Path string
+
+	// The optional sha256 digest of the wasm binary, in the form `sha256:<hex>`.
+	Hash string
+
+	// Inline WebAssembly text (WAT) source for the lens transform, instead of Path.
+	Wat string
+
+	// The name of the runtime that should host the module, see LensModule.Runtime.
+	Runtime string
+}
+
 type LensModule struct {
+	// The name of the module, declared in the Modules of the lens, that should be used.
+	//
+	// Module may not be combined with Path, Hash, Wat or Include.
+	Module string
+
 	// The path to the wasm binary containing the lens transform that you wish to be applied.
 	Path string
 
+	// The optional sha256 digest of the wasm binary, in the form `sha256:<hex>`.
+	//
+	// If provided the module content will be verified against it, and remote modules with
+	// a matching hash may be served from the module cache without revalidation.
+	Hash string
+
+	// Inline WebAssembly text (WAT) source for the lens transform.
+	//
+	// This may be provided instead of Path for small lenses that do not warrant a separate
+	// file. Path and Wat may not both be set.
+	Wat string
+
+	// The path to another lens file whose lenses should be spliced in place of this entry.
+	//
+	// Relative paths are resolved against the directory of the including lens file. Include
+	// may not be combined with Path or Wat.
+	Include string
+
 	// If true, the module will be inversed.
 	//
-	// This may result in an error if the module does not provide an inverse function.
+	// This may result in an error if the module does not provide an inverse function. If this
+	// entry is an include, the included lens will be inversed as a whole.
 	Inverse bool
 
+	// The name of the runtime that should host the module, e.g. `wazero`.
+	//
+	// If empty, the runtime with which the lens is loaded will be used.
+	Runtime string
+
+	// An optional condition restricting the items that will be transformed by the module.
+	//
+	// Items that do not match the condition bypass the module unchanged, keeping their order.
+	// When may not be declared on an include.
+	When *Condition
+
 	// Any additional parameters that you wish to be passed to the lens transform.
 	//
 	// The lens module must expose a `set_param` function if values are provided here.
 	Arguments map[string]any
 }
+
+// Condition is a predicate evaluated host-side against each item entering a lens module.
+//
+// All of the set members must match for the condition to match, an empty condition matches
+// every item.
+type Condition struct {
+	// The dot separated path of the item field to test, e.g. `address.city`.
+	//
+	// If neither Equals nor Exists is set, the field must exist.
+	Field string
+
+	// If not nil, the field must be equal to this value.
+	Equals any
+
+	// If not nil, the field must exist if true, or must not exist if false.
+	Exists *bool
+
+	// If not empty, all of these conditions must match.
+	All []Condition
+
+	// If not empty, at least one of these conditions must match.
+	Any []Condition
+
+	// If not nil, this condition must not match.
+	Not *Condition
+}
@@ -0,0 +1,47 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/lens-vm/lens/host-go/config/model"
+)
+
+// resolveModules returns a copy of the given lens with the module declarations referenced by
+// name copied into the referencing lens entries.
+//
+// Module names are scoped to the lens file declaring them, so this must be done before includes
+// are flattened.
+func resolveModules(lensConfig model.Lens) (model.Lens, error) {
+	lenses := make([]model.LensModule, len(lensConfig.Lenses))
+	for i, moduleCfg := range lensConfig.Lenses {
+		if moduleCfg.Module != "" {
+			if moduleCfg.Path != "" || moduleCfg.Hash != "" || moduleCfg.Wat != "" || moduleCfg.Include != "" {
+				return model.Lens{}, fmt.Errorf(
+					"lens entry referencing module %s may not also declare a path, hash, wat or include",
+					moduleCfg.Module,
+				)
+			}
+
+			declaration, ok := lensConfig.Modules[moduleCfg.Module]
+			if !ok {
+				return model.Lens{}, fmt.Errorf("module %s has not been declared", moduleCfg.Module)
+			}
+			moduleCfg.Path = declaration.Path
+			moduleCfg.Hash = declaration.Hash
+			moduleCfg.Wat = declaration.Wat
+			if moduleCfg.Runtime == "" {
+				moduleCfg.Runtime = declaration.Runtime
+			}
+		}
+		lenses[i] = moduleCfg
+	}
+
+	return model.Lens{
+		Version: lensConfig.Version,
+		Lenses:  lenses,
+	}, nil
+}
@@ -0,0 +1,209 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"github.com/lens-vm/lens/host-go/engine"
+	"github.com/lens-vm/lens/host-go/engine/cache"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/runtimes"
+)
+
+// Option configures how a lens is loaded.
+type Option func(*options)
+
+type options struct {
+	cache       *cache.Cache
+	resolvers   *engine.ModuleResolvers
+	fetchPolicy *engine.FetchPolicy
+	baseDir     string
+	// resolvePaths is true if relative module paths should be resolved against the directory
+	// of the lens file declaring them.
+	resolvePaths bool
+
+	variables       map[string]string
+	envVariables    bool
+	strictVariables bool
+
+	strict bool
+
+	// arguments holds the argument overrides of the lens entries, keyed by their index.
+	arguments map[int]map[string]any
+	inverse   bool
+
+	instanceHook InstanceHook
+	traceErrors  bool
+
+	runtime        string
+	runtimeOptions []runtimes.Option
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithCache sets the on-disk cache through which remote modules will be fetched.
+//
+// If the cache is in offline mode, all remote modules declared in the lens must already be
+// present in the cache.
+func WithCache(c *cache.Cache) Option {
+	return func(o *options) {
+		o.cache = c
+	}
+}
+
+// WithModuleResolvers sets the registry used to resolve the module paths declared in the lens.
+//
+// If not provided, engine.DefaultModuleResolvers will be used.
+func WithModuleResolvers(resolvers *engine.ModuleResolvers) Option {
+	return func(o *options) {
+		o.resolvers = resolvers
+	}
+}
+
+// WithFetchPolicy sets the policy with which remote modules will be fetched.
+//
+// If not provided, engine.DefaultFetchPolicy will be used.
+func WithFetchPolicy(policy engine.FetchPolicy) Option {
+	return func(o *options) {
+		o.fetchPolicy = &policy
+	}
+}
+
+// WithBaseDir sets the directory against which relative include paths in the lens are resolved.
+//
+// If not provided, the current working directory will be used. It has no effect on lenses loaded
+// from a file, the includes of which are resolved relative to the lens file.
+func WithBaseDir(dir string) Option {
+	return func(o *options) {
+		o.baseDir = dir
+	}
+}
+
+// WithVariables sets the values of the `${name}` variables referenced by the module paths and
+// argument values declared in the lens.
+//
+// `$${` may be used to declare a literal `${`.
+func WithVariables(variables map[string]string) Option {
+	return func(o *options) {
+		o.variables = variables
+	}
+}
+
+// WithEnvVariables enables the resolution of variables that have not been provided via
+// WithVariables from the environment.
+func WithEnvVariables() Option {
+	return func(o *options) {
+		o.envVariables = true
+	}
+}
+
+// WithStrictVariables makes loading the lens fail if it references an undefined variable.
+//
+// By default, references to undefined variables are left as they are.
+func WithStrictVariables() Option {
+	return func(o *options) {
+		o.strictVariables = true
+	}
+}
+
+// WithStrict makes parsing lens files fail if they contain unknown fields.
+//
+// By default, unknown fields are ignored.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithArgument overrides the value of the named argument of the lens entry at the given index,
+// adding the argument if it is not declared.
+//
+// The index refers to the entries of the flattened lens, see Flatten, prior to any inversion by
+// WithInverse. Overrides are applied after variables have been interpolated.
+func WithArgument(index int, name string, value any) Option {
+	return func(o *options) {
+		if o.arguments == nil {
+			o.arguments = map[int]map[string]any{}
+		}
+		if o.arguments[index] == nil {
+			o.arguments[index] = map[string]any{}
+		}
+		o.arguments[index][name] = value
+	}
+}
+
+// WithInverse loads the lens inversed, applying it backwards, see Inverse.
+func WithInverse() Option {
+	return func(o *options) {
+		o.inverse = true
+	}
+}
+
+// InstanceHook is called with each of the lens module instances of a lens as it is loaded,
+// returning the instance that should be used in its place.
+//
+// The index and module are those reported by a StageError raised by the instance.
+type InstanceHook func(index int, module string, instance module.Instance) module.Instance
+
+// WithInstanceHook sets a hook through which each of the lens module instances are passed as they
+// are loaded, allowing them to be wrapped, for example to observe their calls.
+func WithInstanceHook(hook InstanceHook) Option {
+	return func(o *options) {
+		o.instanceHook = hook
+	}
+}
+
+// WithErrorTrace makes the errors raised by a specific lens module whilst transforming items be
+// returned as a *StageError, identifying the module at fault.
+//
+// Tracing inspects every item passing between the lens modules, and is therefore disabled by
+// default. Errors raised whilst loading the lens are always returned as a *StageError.
+func WithErrorTrace() Option {
+	return func(o *options) {
+		o.traceErrors = true
+	}
+}
+
+// WithRuntime sets the name of the runtime, registered with the runtimes package, that Load will
+// host the lens modules in.
+//
+// If not provided, the runtime named by the LENS_RUNTIME environment variable will be used,
+// falling back to runtimes.Default. It has no effect on LoadInto, which is given its runtime.
+func WithRuntime(name string) Option {
+	return func(o *options) {
+		o.runtime = name
+	}
+}
+
+// WithRuntimeOptions sets the options with which any runtimes created while loading the lens
+// will be configured, this includes the runtimes of lens modules declaring their own runtime.
+func WithRuntimeOptions(opts ...runtimes.Option) Option {
+	return func(o *options) {
+		o.runtimeOptions = opts
+	}
+}
+
+// moduleOptions returns the engine options with which the given module should be created.
+func (o *options) moduleOptions(hash string) []engine.ModuleOption {
+	moduleOpts := []engine.ModuleOption{}
+	if o.resolvers != nil {
+		moduleOpts = append(moduleOpts, engine.WithModuleResolvers(o.resolvers))
+	}
+	if o.fetchPolicy != nil {
+		moduleOpts = append(moduleOpts, engine.WithFetchPolicy(*o.fetchPolicy))
+	}
+	if o.cache != nil {
+		moduleOpts = append(moduleOpts, engine.WithCache(o.cache))
+	}
+	if hash != "" {
+		moduleOpts = append(moduleOpts, engine.WithHash(hash))
+	}
+	return moduleOpts
+}
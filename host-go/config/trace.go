@@ -0,0 +1,134 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/engine/pipes"
+	"github.com/sourcenetwork/immutable/enumerable"
+)
+
+// StageError is an error raised by, or whilst loading, a specific module of a lens.
+type StageError struct {
+	// Index is the index of the lens module within the flattened lens.
+	Index int
+	// Module is the name or path of the lens module.
+	Module string
+	Err    error
+}
+
+var _ error = (*StageError)(nil)
+
+func newStageError(index int, moduleCfg model.LensModule, err error) *StageError {
+	return &StageError{Index: index, Module: moduleName(moduleCfg), Err: err}
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("lenses[%d] (%s): %s", e.Index, e.Module, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// errorTrace records the lens module from which the errors flowing through a lens originate.
+//
+// Errors raised by wasm modules are passed downstream as error items, losing their origin by the
+// time they are surfaced.
+type errorTrace struct {
+	origin *StageError
+}
+
+// traceInstance returns an instance recording any errors raised by the given instance in the
+// given trace.
+//
+// Errors that were passed to the instance by upstream instances are not recorded, as they did not
+// originate here.
+func traceInstance(trace *errorTrace, index int, moduleCfg model.LensModule, instance module.Instance) module.Instance {
+	return module.Instance{
+		Alloc: instance.Alloc,
+		Transform: func(next func() module.MemSize) (module.MemSize, error) {
+			inputIsErr := false
+			result, err := instance.Transform(func() module.MemSize {
+				input := next()
+				inputIsErr = isErrItem(instance.Memory(), input)
+				return input
+			})
+			if inputIsErr {
+				return result, err
+			}
+
+			// Errors returned by the instance are converted to error items by any downstream
+			// instances, so they must also be recorded.
+			if err != nil || isErrItem(instance.Memory(), result) {
+				trace.origin = newStageError(index, moduleCfg, nil)
+			}
+			return result, err
+		},
+		Memory:  instance.Memory,
+		OwnedBy: instance,
+	}
+}
+
+func isErrItem(memory module.Memory, index module.MemSize) bool {
+	id, err := pipes.ReadTypeId(io.NewSectionReader(memory, int64(index), math.MaxInt64))
+	return err == nil && id.IsError()
+}
+
+// tracedEnumerable attributes the errors surfaced by a lens to the lens module from which they
+// originate, see errorTrace.
+type tracedEnumerable[T any] struct {
+	enumerable.Enumerable[T]
+	trace *errorTrace
+}
+
+// tracedPipe is a tracedEnumerable that preserves the ability of a pipe to yield its serialized
+// items, so that further instances can be appended to it efficiently.
+type tracedPipe[T any] struct {
+	tracedEnumerable[T]
+	pipe pipes.Pipe[T]
+}
+
+var _ pipes.Pipe[int] = (*tracedPipe[int])(nil)
+
+func newTracedEnumerable[T any](trace *errorTrace, src enumerable.Enumerable[T]) enumerable.Enumerable[T] {
+	traced := tracedEnumerable[T]{Enumerable: src, trace: trace}
+	if pipe, ok := src.(pipes.Pipe[T]); ok {
+		return &tracedPipe[T]{tracedEnumerable: traced, pipe: pipe}
+	}
+	return &traced
+}
+
+func (e *tracedEnumerable[T]) Next() (bool, error) {
+	hasNext, err := e.Enumerable.Next()
+	return hasNext, e.attribute(err)
+}
+
+func (e *tracedEnumerable[T]) Value() (T, error) {
+	value, err := e.Enumerable.Value()
+	return value, e.attribute(err)
+}
+
+func (e *tracedEnumerable[T]) Reset() {
+	e.trace.origin = nil
+	e.Enumerable.Reset()
+}
+
+func (e *tracedEnumerable[T]) attribute(err error) error {
+	if err == nil || e.trace.origin == nil || errors.As(err, new(*StageError)) {
+		return err
+	}
+	return &StageError{Index: e.trace.origin.Index, Module: e.trace.origin.Module, Err: err}
+}
+
+func (p *tracedPipe[T]) Bytes() ([]byte, error) {
+	return p.pipe.Bytes()
+}
@@ -0,0 +1,117 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/runtimes"
+)
+
+// Problem is an issue found whilst validating a lens.
+type Problem struct {
+	// Index is the index of the offending lens module within the flattened lens, or -1 if the
+	// problem concerns the lens as a whole.
+	Index int
+	// Module is the name or path of the offending lens module, if any.
+	Module string
+	Err    error
+}
+
+func (p Problem) Error() string {
+	if p.Index < 0 {
+		return p.Err.Error()
+	}
+	return fmt.Sprintf("lenses[%d] (%s): %s", p.Index, p.Module, p.Err)
+}
+
+func (p Problem) Unwrap() error {
+	return p.Err
+}
+
+// ValidateFile loads the lens file at the given path and validates it, see Validate.
+func ValidateFile(path string, opts ...Option) []Problem {
+	lensConfig, err := loadFlatFile(path, newOptions(opts))
+	if err != nil {
+		return []Problem{{Index: -1, Err: err}}
+	}
+
+	return Validate(lensConfig, opts...)
+}
+
+// Validate checks that the given lens could be loaded, without applying it to anything.
+//
+// Every module declared by the lens is resolved and compiled, and a throwaway instance created
+// with its arguments, surfacing any missing exports, including `inverse` for inversed modules, and
+// any arguments rejected by the module. All of the problems found are returned, if there are none
+// the result will be empty.
+func Validate(lensConfig model.Lens, opts ...Option) []Problem {
+	options := newOptions(opts)
+	runtime, err := runtimes.New(options.runtime, options.runtimeOptions...)
+	if err != nil {
+		return []Problem{{Index: -1, Err: err}}
+	}
+
+	l := newLoader(runtime, map[string]module.Module{}, options)
+
+	lensConfig, err = l.prepare(lensConfig)
+	if err != nil {
+		return []Problem{{Index: -1, Err: err}}
+	}
+
+	problems := []Problem{}
+	// failedModules holds the keys of the modules that could not be created, so that they are only
+	// reported once.
+	failedModules := map[string]struct{}{}
+	for i, moduleCfg := range lensConfig.Lenses {
+		problem := func(err error) {
+			problems = append(problems, Problem{Index: i, Module: moduleName(moduleCfg), Err: err})
+		}
+
+		if moduleCfg.When != nil {
+			_, err := NewPredicate(*moduleCfg.When)
+			if err != nil {
+				problem(err)
+			}
+		}
+
+		key, err := moduleKey(moduleCfg)
+		if err != nil {
+			problem(err)
+			continue
+		}
+		if _, ok := failedModules[key]; ok {
+			continue
+		}
+
+		lensModule, err := l.module(moduleCfg)
+		if err != nil {
+			failedModules[key] = struct{}{}
+			problem(err)
+			continue
+		}
+
+		_, err = newInstance(moduleCfg, lensModule)
+		if err != nil {
+			problem(err)
+		}
+	}
+
+	return problems
+}
+
+// moduleName returns the name by which problems with the given lens module are reported.
+func moduleName(moduleCfg model.LensModule) string {
+	switch {
+	case moduleCfg.Module != "":
+		return moduleCfg.Module
+	case moduleCfg.Wat != "":
+		return "wat"
+	default:
+		return moduleCfg.Path
+	}
+}
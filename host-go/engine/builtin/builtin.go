@@ -0,0 +1,169 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package builtin provides simple lens modules implemented in Go, so that common transformations
+// do not need a compiled wasm module.
+//
+// Builtin modules are referenced by lens files using the "builtin:" scheme, for example
+// "builtin:rename", and take their parameters from the lens arguments. The following are
+// available:
+//   - rename: moves the `src` field to `dst`, its inverse moves it back
+//   - drop: removes `field`, it has no inverse
+//   - set-default: sets `field` to `value` if it is missing or null, it has no inverse
+//   - copy: copies the `src` field to `dst`, which must not already exist, its inverse removes
+//     `dst`
+//   - filter-eq: yields only the items whose `field` equals `value`, it has no inverse
+//   - expr: replaces each item with the object yielded by the jq-like `expression`, it has no
+//     inverse, see the internal expr package for the syntax
+//   - json-patch: applies the RFC 6902 JSON Patch `patch`, its inverse may be derived if the values
+//     it removes or replaces are recorded by preceding `test` operations, and the members it adds
+//     are declared by the `absent` argument
+//   - merge-patch: applies the RFC 7386 merge patch `patch`, its inverse may be derived if the
+//     values it removes or replaces are recorded by the `previous` argument, and the fields it
+//     adds are declared by the `absent` argument
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/engine/pipes"
+)
+
+// Scheme is the scheme of the module paths that refer to builtin modules.
+const Scheme = "builtin"
+
+// itemFunc transforms a single item, returning false if the item should be dropped.
+type itemFunc func(item map[string]any) (map[string]any, bool, error)
+
+// lens is a builtin lens, its functions return the itemFunc to apply given the lens arguments.
+type lens struct {
+	transform func(args arguments) (itemFunc, error)
+	// inverse is nil if the lens cannot be inversed.
+	inverse func(args arguments) (itemFunc, error)
+}
+
+var lenses = map[string]lens{
+	"rename":      {transform: rename, inverse: renameInverse},
+	"drop":        {transform: drop},
+	"set-default": {transform: setDefault},
+	"copy":        {transform: copyField, inverse: copyInverse},
+	"filter-eq":   {transform: filterEq},
+	"expr":        {transform: expression},
+	"json-patch":  {transform: jsonPatch, inverse: jsonPatchInverse},
+	"merge-patch": {transform: mergePatch, inverse: mergePatchInverse},
+}
+
+// Module is a builtin lens module.
+type Module struct {
+	name string
+	lens lens
+}
+
+var _ module.Module = (*Module)(nil)
+
+// Get returns the builtin module with the given name.
+func Get(name string) (*Module, error) {
+	l, ok := lenses[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown builtin module %q", name)
+	}
+	return &Module{name: name, lens: l}, nil
+}
+
+// Names returns the names of all of the builtin modules, in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(lenses))
+	for name := range lenses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *Module) NewInstance(functionName string, paramSets ...map[string]any) (module.Instance, error) {
+	var newItemFunc func(args arguments) (itemFunc, error)
+	switch functionName {
+	case "transform":
+		newItemFunc = m.lens.transform
+	case "inverse":
+		newItemFunc = m.lens.inverse
+	}
+	if newItemFunc == nil {
+		return module.Instance{}, fmt.Errorf("%s:%s has no %s", Scheme, m.name, functionName)
+	}
+
+	// Merge the param sets into a single map in case more than
+	// one map is provided.
+	args := arguments{}
+	for _, paramSet := range paramSets {
+		for key, value := range paramSet {
+			args[key] = value
+		}
+	}
+
+	fn, err := newItemFunc(args)
+	if err != nil {
+		return module.Instance{}, fmt.Errorf("%s:%s: %w", Scheme, m.name, err)
+	}
+
+	return pipes.NewNative(func(next func() (map[string]any, bool, error)) (map[string]any, bool, error) {
+		for {
+			item, hasNext, err := next()
+			if err != nil || !hasNext {
+				return nil, false, err
+			}
+			if item == nil {
+				// Nil items cannot be transformed, they are passed on as they are.
+				return nil, true, nil
+			}
+
+			result, keep, err := fn(item)
+			if err != nil {
+				return nil, false, err
+			}
+			if keep {
+				return result, true, nil
+			}
+		}
+	}), nil
+}
+
+// arguments are the arguments of a builtin lens.
+type arguments map[string]any
+
+// field returns the name of the field held by the given required argument.
+func (a arguments) field(name string) (string, error) {
+	value, ok := a[name]
+	if !ok {
+		return "", fmt.Errorf("missing argument: %s", name)
+	}
+	field, ok := value.(string)
+	if !ok || field == "" {
+		return "", fmt.Errorf("argument %s must be a field name", name)
+	}
+	return field, nil
+}
+
+// value returns the given required argument, normalized so that it may be compared with values
+// decoded from json.
+func (a arguments) value(name string) (any, error) {
+	value, ok := a[name]
+	if !ok {
+		return nil, fmt.Errorf("missing argument: %s", name)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("argument %s: %w", name, err)
+	}
+	var normalized any
+	err = json.Unmarshal(data, &normalized)
+	if err != nil {
+		return nil, fmt.Errorf("argument %s: %w", name, err)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,372 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package expr
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+type node interface {
+	eval(input any) (any, error)
+}
+
+type identity struct{}
+
+func (identity) eval(input any) (any, error) {
+	return input, nil
+}
+
+type literal struct {
+	value any
+}
+
+func (n literal) eval(input any) (any, error) {
+	return n.value, nil
+}
+
+type index struct {
+	target node
+	key    node
+}
+
+func (n index) eval(input any) (any, error) {
+	target, err := n.target.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	key, err := n.key.eval(input)
+	if err != nil {
+		return nil, err
+	}
+
+	switch target := target.(type) {
+	case nil:
+		return nil, nil
+
+	case map[string]any:
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot index object with %s", typeName(key))
+		}
+		return target[name], nil
+
+	case []any:
+		i, ok := key.(float64)
+		if !ok || i != math.Trunc(i) {
+			return nil, fmt.Errorf("cannot index array with %s", typeName(key))
+		}
+		if i < 0 {
+			i += float64(len(target))
+		}
+		if i < 0 || i >= float64(len(target)) {
+			return nil, nil
+		}
+		return target[int(i)], nil
+
+	default:
+		return nil, fmt.Errorf("cannot index %s", typeName(target))
+	}
+}
+
+type array []node
+
+func (n array) eval(input any) (any, error) {
+	result := make([]any, 0, len(n))
+	for _, item := range n {
+		value, err := item.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+type field struct {
+	name  string
+	value node
+}
+
+type object []field
+
+func (n object) eval(input any) (any, error) {
+	result := make(map[string]any, len(n))
+	for _, f := range n {
+		value, err := f.value.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		result[f.name] = value
+	}
+	return result, nil
+}
+
+type conditional struct {
+	cond      node
+	then      node
+	otherwise node
+}
+
+func (n conditional) eval(input any) (any, error) {
+	cond, err := n.cond.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(cond) {
+		return n.then.eval(input)
+	}
+	return n.otherwise.eval(input)
+}
+
+type alternative struct {
+	left  node
+	right node
+}
+
+func (n alternative) eval(input any) (any, error) {
+	left, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(left) {
+		return left, nil
+	}
+	return n.right.eval(input)
+}
+
+type and struct {
+	left  node
+	right node
+}
+
+func (n and) eval(input any) (any, error) {
+	left, err := n.left.eval(input)
+	if err != nil || !truthy(left) {
+		return false, err
+	}
+	right, err := n.right.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type or struct {
+	left  node
+	right node
+}
+
+func (n or) eval(input any) (any, error) {
+	left, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(left) {
+		return true, nil
+	}
+	right, err := n.right.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type not struct {
+	operand node
+}
+
+func (n not) eval(input any) (any, error) {
+	value, err := n.operand.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type comparison struct {
+	op    string
+	left  node
+	right node
+}
+
+func (n comparison) eval(input any) (any, error) {
+	left, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(input)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return reflect.DeepEqual(left, right), nil
+	case "!=":
+		return !reflect.DeepEqual(left, right), nil
+	}
+
+	var order int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare number with %s", typeName(right))
+		}
+		order = cmp.Compare(l, r)
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %s", typeName(right))
+		}
+		order = cmp.Compare(l, r)
+	default:
+		return nil, fmt.Errorf("cannot compare %s", typeName(left))
+	}
+
+	switch n.op {
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+
+type arithmetic struct {
+	op    string
+	left  node
+	right node
+}
+
+func (n arithmetic) eval(input any) (any, error) {
+	left, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "+" {
+		return add(left, right)
+	}
+
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot apply %s to %s and %s", n.op, typeName(left), typeName(right))
+	}
+	switch n.op {
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	default:
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(l, r), nil
+	}
+}
+
+// add adds numbers, concatenates strings and arrays and merges objects, null being the identity.
+func add(left any, right any) (any, error) {
+	if left == nil {
+		return right, nil
+	}
+	if right == nil {
+		return left, nil
+	}
+
+	switch l := left.(type) {
+	case float64:
+		if r, ok := right.(float64); ok {
+			return l + r, nil
+		}
+	case string:
+		if r, ok := right.(string); ok {
+			return l + r, nil
+		}
+	case []any:
+		if r, ok := right.([]any); ok {
+			result := make([]any, 0, len(l)+len(r))
+			result = append(result, l...)
+			return append(result, r...), nil
+		}
+	case map[string]any:
+		if r, ok := right.(map[string]any); ok {
+			result := make(map[string]any, len(l)+len(r))
+			for key, value := range l {
+				result[key] = value
+			}
+			for key, value := range r {
+				result[key] = value
+			}
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot add %s and %s", typeName(left), typeName(right))
+}
+
+type call struct {
+	name string
+	fn   func(args []any) (any, error)
+	args []node
+}
+
+func (n call) eval(input any) (any, error) {
+	args := make([]any, 0, len(n.args))
+	for _, arg := range n.args {
+		value, err := arg.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+
+	result, err := n.fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return result, nil
+}
+
+// truthy returns false if the given value is null or false.
+func truthy(value any) bool {
+	return value != nil && value != false
+}
+
+// typeName returns the json type name of the given value.
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,59 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+/*
+Package expr implements a small jq-like expression language evaluated against items decoded from
+json.
+
+An expression is evaluated against a single input value, for example:
+
+	{fullName: upper(.first) + " " + .last, age: .age + 1, tags: [.kind, "user"]}
+
+It supports:
+  - the input (`.`), its fields (`.name`, `.address.city`) and indexes (`.tags[0]`, `.["a b"]`)
+  - string, number, boolean and null literals, arrays and objects, `{name}` being shorthand for
+    `{name: .name}`
+  - arithmetic (`+ - * / %`), `+` also concatenating strings and arrays and merging objects
+  - comparisons (`== != < <= > >=`), logic (`and or not`) and `a // b`, yielding b if a is null
+    or false
+  - conditionals, `if .age > 18 then "adult" else "minor" end`
+  - functions, see the functions map
+
+Accessing a field of null yields null, only null and false are falsy.
+*/
+package expr
+
+import (
+	"fmt"
+)
+
+// Expr is a parsed expression.
+type Expr struct {
+	root node
+}
+
+// Parse parses the given expression.
+func Parse(src string) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, fmt.Errorf("expr: %w", err)
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, fmt.Errorf("expr: %w", err)
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("expr: %w", p.unexpected())
+	}
+
+	return &Expr{root: root}, nil
+}
+
+// Eval evaluates the expression against the given input, which should have been decoded from
+// json.
+func (e *Expr) Eval(input any) (any, error) {
+	return e.root.eval(input)
+}
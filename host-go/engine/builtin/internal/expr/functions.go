@@ -0,0 +1,271 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package expr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type function struct {
+	// minArgs and maxArgs are the number of arguments accepted, maxArgs is -1 if unbounded.
+	minArgs int
+	maxArgs int
+	call    func(args []any) (any, error)
+}
+
+// functions are the functions that may be called by expressions.
+var functions = map[string]function{
+	"upper":      {1, 1, stringFunc(strings.ToUpper)},
+	"lower":      {1, 1, stringFunc(strings.ToLower)},
+	"trim":       {1, 1, stringFunc(strings.TrimSpace)},
+	"length":     {1, 1, length},
+	"concat":     {1, -1, concat},
+	"substr":     {2, 3, substr},
+	"split":      {2, 2, split},
+	"join":       {2, 2, join},
+	"replace":    {3, 3, replace},
+	"contains":   {2, 2, stringPredicate(strings.Contains)},
+	"startsWith": {2, 2, stringPredicate(strings.HasPrefix)},
+	"endsWith":   {2, 2, stringPredicate(strings.HasSuffix)},
+	"string":     {1, 1, toString},
+	"number":     {1, 1, toNumber},
+	"floor":      {1, 1, numberFunc(math.Floor)},
+	"ceil":       {1, 1, numberFunc(math.Ceil)},
+	"round":      {1, 1, numberFunc(math.Round)},
+	"abs":        {1, 1, numberFunc(math.Abs)},
+	"keys":       {1, 1, keys},
+	"has":        {2, 2, has},
+}
+
+func stringFunc(fn func(string) string) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		s, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return fn(s), nil
+	}
+}
+
+func stringPredicate(fn func(string, string) bool) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		s, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		other, err := stringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return fn(s, other), nil
+	}
+}
+
+func numberFunc(fn func(float64) float64) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		n, err := numberArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return fn(n), nil
+	}
+}
+
+func length(args []any) (any, error) {
+	switch value := args[0].(type) {
+	case nil:
+		return float64(0), nil
+	case string:
+		return float64(utf8.RuneCountInString(value)), nil
+	case []any:
+		return float64(len(value)), nil
+	case map[string]any:
+		return float64(len(value)), nil
+	default:
+		return nil, fmt.Errorf("%s has no length", typeName(value))
+	}
+}
+
+func concat(args []any) (any, error) {
+	var sb strings.Builder
+	for i := range args {
+		s, err := toString(args[i : i+1])
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(s.(string))
+	}
+	return sb.String(), nil
+}
+
+// substr returns the characters of the given string from start up to, but excluding, end.
+func substr(args []any) (any, error) {
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+
+	start, err := numberArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	end := float64(len(runes))
+	if len(args) > 2 {
+		end, err = numberArg(args, 2)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if math.IsNaN(start) || math.IsInf(start, 0) || math.IsNaN(end) || math.IsInf(end, 0) {
+		return nil, fmt.Errorf("substr expects finite indexes, got %v and %v", start, end)
+	}
+
+	startIndex := int(math.Max(0, math.Min(start, float64(len(runes)))))
+	endIndex := int(math.Max(float64(startIndex), math.Min(end, float64(len(runes)))))
+	return string(runes[startIndex:endIndex]), nil
+}
+
+func split(args []any) (any, error) {
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	sep, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(s, sep)
+	result := make([]any, 0, len(parts))
+	for _, part := range parts {
+		result = append(result, part)
+	}
+	return result, nil
+}
+
+func join(args []any) (any, error) {
+	items, ok := args[0].([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected array, got %s", typeName(args[0]))
+	}
+	sep, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]string, 0, len(items))
+	for i := range items {
+		s, err := toString(items[i : i+1])
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, s.(string))
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func replace(args []any) (any, error) {
+	s, err := stringArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	old, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	replacement, err := stringArg(args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ReplaceAll(s, old, replacement), nil
+}
+
+// toString converts the given scalar to a string, null becoming an empty string.
+func toString(args []any) (any, error) {
+	switch value := args[0].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to string", typeName(value))
+	}
+}
+
+func toNumber(args []any) (any, error) {
+	switch value := args[0].(type) {
+	case float64:
+		return value, nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		// Non-finite numbers cannot be represented in json.
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("cannot convert %q to number", value)
+		}
+		return n, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to number", typeName(value))
+	}
+}
+
+// keys returns the keys of the given object in alphabetical order.
+func keys(args []any) (any, error) {
+	obj, ok := args[0].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected object, got %s", typeName(args[0]))
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]any, 0, len(names))
+	for _, name := range names {
+		result = append(result, name)
+	}
+	return result, nil
+}
+
+func has(args []any) (any, error) {
+	obj, ok := args[0].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected object, got %s", typeName(args[0]))
+	}
+	name, err := stringArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	_, ok = obj[name]
+	return ok, nil
+}
+
+func stringArg(args []any, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", fmt.Errorf("expected string, got %s", typeName(args[i]))
+	}
+	return s, nil
+}
+
+func numberArg(args []any, i int) (float64, error) {
+	n, ok := args[i].(float64)
+	if !ok {
+		return 0, fmt.Errorf("expected number, got %s", typeName(args[i]))
+	}
+	return n, nil
+}
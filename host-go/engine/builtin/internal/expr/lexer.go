@@ -0,0 +1,113 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	// text is the source text of the token, with the exception of strings, which are unquoted.
+	text string
+	// number is the value of number tokens.
+	number float64
+	// pos is the 1-based offset of the token within the expression.
+	pos int
+}
+
+// punctuation holds the punctuation tokens, longest first so that they are matched greedily.
+var punctuation = []string{
+	"//", "==", "!=", "<=", ">=",
+	".", "[", "]", "(", ")", "{", "}", ",", ":", "+", "-", "*", "/", "%", "<", ">",
+}
+
+// lex splits the given expression into tokens.
+func lex(src string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && strings.ContainsRune("0123456789.eE", rune(src[i])) {
+				if (src[i] == 'e' || src[i] == 'E') && i+1 < len(src) && strings.ContainsRune("+-", rune(src[i+1])) {
+					i++
+				}
+				i++
+			}
+			number, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number at position %d: %s", start+1, src[start:i])
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[start:i], number: number, pos: start + 1})
+
+		case c == '"':
+			start := i
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("unterminated string at position %d", start+1)
+			}
+			i++
+			var text string
+			err := json.Unmarshal([]byte(src[start:i]), &text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %w", start+1, err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: start + 1})
+
+		case isIdentStart(src[i]):
+			start := i
+			for i < len(src) && (isIdentStart(src[i]) || (src[i] >= '0' && src[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[start:i], pos: start + 1})
+
+		default:
+			matched := false
+			for _, punct := range punctuation {
+				if strings.HasPrefix(src[i:], punct) {
+					tokens = append(tokens, token{kind: tokenPunct, text: punct, pos: i + 1})
+					i += len(punct)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character at position %d: %q", i+1, c)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src) + 1}), nil
+}
+
+// isIdentStart returns true if the given byte may start an identifier, identifiers are limited
+// to ascii, other field names may be accessed using an index such as `.["名前"]`.
+func isIdentStart(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
@@ -0,0 +1,382 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package expr
+
+import (
+	"fmt"
+)
+
+// keywords may not be used as function names, although they may be used as field names.
+var keywords = map[string]struct{}{
+	"and": {}, "or": {}, "not": {}, "if": {}, "then": {}, "else": {}, "end": {},
+	"true": {}, "false": {}, "null": {},
+}
+
+type parser struct {
+	tokens []token
+	index  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.index]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.index]
+	if t.kind != tokenEOF {
+		p.index++
+	}
+	return t
+}
+
+// is returns true if the next token is the given punctuation or keyword.
+func (p *parser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == tokenPunct || t.kind == tokenIdent) && t.text == text
+}
+
+// accept consumes the next token if it is the given punctuation or keyword.
+func (p *parser) accept(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
+
+func (p *parser) parseExpr() (node, error) {
+	return p.parseAlternative()
+}
+
+// parseAlternative parses `a // b`, which yields b if a is null or false.
+func (p *parser) parseAlternative() (node, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("//") {
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		left = alternative{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.accept("not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return not{operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(op) {
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return comparison{op, left, right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("+") || p.is("-") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic{op, left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("*") || p.is("/") || p.is("%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic{op, left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("-") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return arithmetic{"-", literal{float64(0)}, operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix parses any field accesses and indexes following a primary expression.
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseAccessors(n)
+}
+
+func (p *parser) parseAccessors(n node) (node, error) {
+	for {
+		switch {
+		case p.is(".") && p.tokens[p.index+1].kind == tokenIdent:
+			p.next()
+			n = index{n, literal{p.next().text}}
+
+		case p.accept("["):
+			key, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = index{n, key}
+
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenNumber:
+		p.next()
+		return literal{t.number}, nil
+
+	case tokenString:
+		p.next()
+		return literal{t.text}, nil
+
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			p.next()
+			return literal{true}, nil
+		case "false":
+			p.next()
+			return literal{false}, nil
+		case "null":
+			p.next()
+			return literal{nil}, nil
+		case "if":
+			return p.parseIf()
+		}
+		if _, ok := keywords[t.text]; ok {
+			return nil, p.unexpected()
+		}
+		return p.parseCall()
+
+	case tokenPunct:
+		switch t.text {
+		case ".":
+			p.next()
+			// A leading dot refers to the input, and may be directly followed by a field name.
+			if p.peek().kind == tokenIdent {
+				return index{identity{}, literal{p.next().text}}, nil
+			}
+			return identity{}, nil
+
+		case "(":
+			p.next()
+			n, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+
+		case "[":
+			return p.parseArray()
+
+		case "{":
+			return p.parseObject()
+		}
+	}
+	return nil, p.unexpected()
+}
+
+// parseIf parses `if cond then a else b end`, the else branch is optional and defaults to null.
+func (p *parser) parseIf() (node, error) {
+	p.next()
+	cond, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	var otherwise node = literal{nil}
+	if p.accept("else") {
+		otherwise, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("end"); err != nil {
+		return nil, err
+	}
+	return conditional{cond, then, otherwise}, nil
+}
+
+func (p *parser) parseCall() (node, error) {
+	name := p.next()
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at position %d", name.text, name.pos)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	args := []node{}
+	for !p.accept(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments to %s at position %d", name.text, name.pos)
+	}
+	return call{name.text, fn.call, args}, nil
+}
+
+func (p *parser) parseArray() (node, error) {
+	p.next()
+	items := array{}
+	for !p.accept("]") {
+		if len(items) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		item, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// parseObject parses an object construction such as `{name: .first, age}`, a key without a
+// value is shorthand for the field of the same name of the input.
+func (p *parser) parseObject() (node, error) {
+	p.next()
+	fields := object{}
+	for !p.accept("}") {
+		if len(fields) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		key := p.peek()
+		if key.kind != tokenIdent && key.kind != tokenString {
+			return nil, p.unexpected()
+		}
+		p.next()
+
+		var value node = index{identity{}, literal{key.text}}
+		if p.accept(":") {
+			var err error
+			value, err = p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, field{key.text, value})
+	}
+	return fields, nil
+}
@@ -0,0 +1,153 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package builtin
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/lens-vm/lens/host-go/engine/builtin/internal/expr"
+)
+
+func rename(args arguments) (itemFunc, error) {
+	src, err := args.field("src")
+	if err != nil {
+		return nil, err
+	}
+	dst, err := args.field("dst")
+	if err != nil {
+		return nil, err
+	}
+	return moveField(src, dst), nil
+}
+
+func renameInverse(args arguments) (itemFunc, error) {
+	src, err := args.field("src")
+	if err != nil {
+		return nil, err
+	}
+	dst, err := args.field("dst")
+	if err != nil {
+		return nil, err
+	}
+	return moveField(dst, src), nil
+}
+
+func moveField(src string, dst string) itemFunc {
+	return func(item map[string]any) (map[string]any, bool, error) {
+		value, ok := item[src]
+		if !ok {
+			return nil, false, fmt.Errorf("property not found: %s", src)
+		}
+		delete(item, src)
+		item[dst] = value
+		return item, true, nil
+	}
+}
+
+func drop(args arguments) (itemFunc, error) {
+	field, err := args.field("field")
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]any) (map[string]any, bool, error) {
+		delete(item, field)
+		return item, true, nil
+	}, nil
+}
+
+func setDefault(args arguments) (itemFunc, error) {
+	field, err := args.field("field")
+	if err != nil {
+		return nil, err
+	}
+	value, err := args.value("value")
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]any) (map[string]any, bool, error) {
+		if item[field] == nil {
+			item[field] = value
+		}
+		return item, true, nil
+	}, nil
+}
+
+func copyField(args arguments) (itemFunc, error) {
+	src, err := args.field("src")
+	if err != nil {
+		return nil, err
+	}
+	dst, err := args.field("dst")
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]any) (map[string]any, bool, error) {
+		value, ok := item[src]
+		if !ok {
+			return nil, false, fmt.Errorf("property not found: %s", src)
+		}
+		// Replacing dst would lose its value, which the inverse could not restore.
+		if _, ok := item[dst]; ok {
+			return nil, false, fmt.Errorf("property already exists: %s", dst)
+		}
+		item[dst] = value
+		return item, true, nil
+	}, nil
+}
+
+// copyInverse removes the dst field, which copyField ensures was absent prior to the copy.
+func copyInverse(args arguments) (itemFunc, error) {
+	dst, err := args.field("dst")
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]any) (map[string]any, bool, error) {
+		delete(item, dst)
+		return item, true, nil
+	}, nil
+}
+
+func filterEq(args arguments) (itemFunc, error) {
+	field, err := args.field("field")
+	if err != nil {
+		return nil, err
+	}
+	value, err := args.value("value")
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]any) (map[string]any, bool, error) {
+		actual, ok := item[field]
+		return item, ok && reflect.DeepEqual(actual, value), nil
+	}, nil
+}
+
+func expression(args arguments) (itemFunc, error) {
+	value, ok := args["expression"]
+	if !ok {
+		return nil, fmt.Errorf("missing argument: expression")
+	}
+	src, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("argument expression must be a string")
+	}
+	e, err := expr.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(item map[string]any) (map[string]any, bool, error) {
+		value, err := e.Eval(item)
+		if err != nil {
+			return nil, false, err
+		}
+		result, ok := value.(map[string]any)
+		if !ok {
+			return nil, false, fmt.Errorf("expression must yield an object, got %T", value)
+		}
+		return result, true, nil
+	}, nil
+}
@@ -0,0 +1,159 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package builtin
+
+import (
+	"fmt"
+)
+
+func mergePatch(args arguments) (itemFunc, error) {
+	patch, err := args.value("patch")
+	if err != nil {
+		return nil, err
+	}
+	return mergePatchFunc(patch), nil
+}
+
+// mergePatchInverse derives the inverse of the merge patch, which requires the values removed or
+// replaced by the patch to be known. These may be recorded by the optional `previous` argument,
+// holding the values of the fields touched by the patch prior to its application.
+//
+// Fields that were absent prior to the patch must be declared by the optional `absent` argument,
+// holding their JSON pointers. Fields neither present in `previous` nor declared absent are
+// unknown, and prevent the patch from being inverted.
+func mergePatchInverse(args arguments) (itemFunc, error) {
+	patch, err := args.value("patch")
+	if err != nil {
+		return nil, err
+	}
+
+	var previous any
+	if _, ok := args["previous"]; ok {
+		previous, err = args.value("previous")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	absent, err := absentArgument(args)
+	if err != nil {
+		return nil, err
+	}
+
+	inverse, err := invertMergePatch(patch, previous, absent, "")
+	if err != nil {
+		return nil, err
+	}
+	return mergePatchFunc(inverse), nil
+}
+
+func mergePatchFunc(patch any) itemFunc {
+	return func(item map[string]any) (map[string]any, bool, error) {
+		doc := applyMergePatch(item, patch)
+
+		result, ok := doc.(map[string]any)
+		if !ok {
+			return nil, false, fmt.Errorf("merge patch must yield an object, got %T", doc)
+		}
+		return result, true, nil
+	}
+}
+
+// applyMergePatch applies the given RFC 7386 merge patch to the target.
+func applyMergePatch(target any, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return deepCopy(patch)
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = applyMergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+// invertMergePatch returns the merge patch restoring the given previous values of the fields
+// touched by the given patch, or removing those declared absent, path is the location of the patch.
+func invertMergePatch(patch any, previous any, absent map[string]bool, path string) (any, error) {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		if previous == nil {
+			return nil, fmt.Errorf("merge patch is not invertible: the value at %s is unknown, declare it in previous", path)
+		}
+		return restoringPatch(previous, path)
+	}
+
+	previousObject, _ := previous.(map[string]any)
+	inverse := map[string]any{}
+	for key, value := range patchObject {
+		fieldPath := path + "/" + escapeToken(key)
+		previousValue, hadValue := previousObject[key]
+		if hadValue && previousValue == nil {
+			return nil, fmt.Errorf("merge patch is not invertible: the null value at %s cannot be restored", fieldPath)
+		}
+
+		switch {
+		case !hadValue && absent[fieldPath]:
+			inverse[key] = nil
+
+		case !hadValue:
+			return nil, fmt.Errorf(
+				"merge patch is not invertible: the value at %s is unknown, declare it in previous or absent",
+				fieldPath,
+			)
+
+		default:
+			_, valueIsObject := value.(map[string]any)
+			_, previousIsObject := previousValue.(map[string]any)
+			if !valueIsObject || !previousIsObject {
+				restoring, err := restoringPatch(previousValue, fieldPath)
+				if err != nil {
+					return nil, err
+				}
+				inverse[key] = restoring
+				continue
+			}
+
+			inverseValue, err := invertMergePatch(value, previousValue, absent, fieldPath)
+			if err != nil {
+				return nil, err
+			}
+			inverse[key] = inverseValue
+		}
+	}
+	return inverse, nil
+}
+
+// restoringPatch returns the merge patch replacing a value that is not an object, or is absent,
+// with the given value at the given path.
+//
+// This is the value itself, unless it is an object holding null members, which would be removed
+// rather than restored by the patch.
+func restoringPatch(value any, path string) (any, error) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return value, nil
+	}
+
+	for key, member := range object {
+		memberPath := path + "/" + escapeToken(key)
+		if member == nil {
+			return nil, fmt.Errorf("merge patch is not invertible: the null value at %s cannot be restored", memberPath)
+		}
+		_, err := restoringPatch(member, memberPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,519 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package builtin
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// patchOperation is an RFC 6902 JSON Patch operation.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+
+	// value is the decoded Value.
+	value any
+}
+
+func jsonPatch(args arguments) (itemFunc, error) {
+	operations, err := patchArgument(args)
+	if err != nil {
+		return nil, err
+	}
+	return patchFunc(operations), nil
+}
+
+// jsonPatchInverse derives the inverse of the patch, which requires the values removed or replaced
+// by the patch to be known. These may be recorded by preceding the operations removing them with
+// `test` operations, as the value tested must be the value removed.
+//
+// Adding, moving or copying a value to an object member replaces any existing value, so the member
+// must either have been tested or be known to be absent, either because it was removed by a prior
+// operation or because it is declared by the optional `absent` argument. This holds the JSON
+// pointers of the members absent from every item prior to the patch.
+func jsonPatchInverse(args arguments) (itemFunc, error) {
+	operations, err := patchArgument(args)
+	if err != nil {
+		return nil, err
+	}
+	absent, err := absentArgument(args)
+	if err != nil {
+		return nil, err
+	}
+
+	known := knownValues{}
+	for pointer := range absent {
+		known.set(pointer, absentValue{}, true)
+	}
+	inverse := []patchOperation{}
+	for _, op := range operations {
+		// inverseOps holds the operations undoing op, in the order they are to be applied.
+		var inverseOps []patchOperation
+		switch op.Op {
+		case "add":
+			if strings.HasSuffix(op.Path, "/-") {
+				return nil, fmt.Errorf("patch is not invertible: cannot invert add to the end of array %s", op.Path)
+			}
+			inverseOps = []patchOperation{{Op: "remove", Path: op.Path}}
+			previous, replaces, err := known.replaced(op)
+			if err != nil {
+				return nil, err
+			}
+			if replaces {
+				// Adding to an existing object member replaces it.
+				inverseOps = []patchOperation{{Op: "replace", Path: op.Path, value: previous}}
+			}
+			known.shift(op.Path)
+			known.set(op.Path, op.value, true)
+
+		case "remove", "replace":
+			previous, ok := known.get(op.Path)
+			if !ok {
+				return nil, fmt.Errorf(
+					"patch is not invertible: the value at %s is unknown, precede the %s with a test",
+					op.Path,
+					op.Op,
+				)
+			}
+			if op.Op == "remove" {
+				inverseOps = []patchOperation{{Op: "add", Path: op.Path, value: previous}}
+			} else {
+				inverseOps = []patchOperation{{Op: "replace", Path: op.Path, value: previous}}
+			}
+			if op.Op == "remove" {
+				known.shift(op.Path)
+				known.remove(op.Path)
+			} else {
+				known.set(op.Path, op.value, true)
+			}
+
+		case "move", "copy":
+			if strings.HasSuffix(op.Path, "/-") {
+				return nil, fmt.Errorf("patch is not invertible: cannot invert %s to the end of array %s", op.Op, op.Path)
+			}
+			if op.Op == "move" {
+				inverseOps = []patchOperation{{Op: "move", From: op.Path, Path: op.From}}
+			} else {
+				inverseOps = []patchOperation{{Op: "remove", Path: op.Path}}
+			}
+			previous, replaces, err := known.replaced(op)
+			if err != nil {
+				return nil, err
+			}
+			if replaces {
+				// Moving or copying to an existing object member replaces it, so it must be
+				// restored once the moved value has been moved back, or the copy removed.
+				inverseOps = append(inverseOps, patchOperation{Op: "add", Path: op.Path, value: previous})
+			}
+
+			value, ok := known.get(op.From)
+			if op.Op == "move" {
+				known.shift(op.From)
+				known.remove(op.From)
+			}
+			known.shift(op.Path)
+			known.set(op.Path, value, ok)
+
+		case "test":
+			known.set(op.Path, op.value, true)
+			continue
+		}
+		inverse = append(inverseOps, inverse...)
+	}
+
+	return patchFunc(inverse), nil
+}
+
+// absentArgument returns the JSON pointers held by the optional `absent` argument.
+func absentArgument(args arguments) (map[string]bool, error) {
+	if _, ok := args["absent"]; !ok {
+		return nil, nil
+	}
+	value, err := args.value("absent")
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("argument absent must be a list of json pointers")
+	}
+	absent := make(map[string]bool, len(items))
+	for _, item := range items {
+		pointer, ok := item.(string)
+		if !ok || pointer == "" {
+			return nil, fmt.Errorf("argument absent must be a list of json pointers")
+		}
+		_, err = parsePointer(pointer)
+		if err != nil {
+			return nil, fmt.Errorf("argument absent: %w", err)
+		}
+		absent[pointer] = true
+	}
+	return absent, nil
+}
+
+// patchArgument returns the validated operations of the `patch` argument.
+func patchArgument(args arguments) ([]patchOperation, error) {
+	value, err := args.value("patch")
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []patchOperation
+	err = json.Unmarshal(data, &operations)
+	if err != nil {
+		return nil, fmt.Errorf("argument patch must be a list of patch operations: %w", err)
+	}
+
+	for i, op := range operations {
+		switch op.Op {
+		case "add", "replace", "test":
+			if len(op.Value) == 0 {
+				return nil, fmt.Errorf("patch operation %d: %s requires a value", i, op.Op)
+			}
+			err = json.Unmarshal(op.Value, &operations[i].value)
+			if err != nil {
+				return nil, err
+			}
+		case "move", "copy":
+			_, err = parsePointer(op.From)
+			if err != nil {
+				return nil, fmt.Errorf("patch operation %d: %w", i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("patch operation %d: unknown op %q", i, op.Op)
+		}
+
+		_, err = parsePointer(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("patch operation %d: %w", i, err)
+		}
+	}
+	return operations, nil
+}
+
+func patchFunc(operations []patchOperation) itemFunc {
+	return func(item map[string]any) (map[string]any, bool, error) {
+		var doc any = item
+		for _, op := range operations {
+			var err error
+			doc, err = applyOperation(doc, op)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+
+		result, ok := doc.(map[string]any)
+		if !ok {
+			return nil, false, fmt.Errorf("patch must yield an object, got %T", doc)
+		}
+		return result, true, nil
+	}
+}
+
+func applyOperation(doc any, op patchOperation) (any, error) {
+	// The pointers have already been validated.
+	path, _ := parsePointer(op.Path)
+
+	switch op.Op {
+	case "add":
+		return addValue(doc, path, deepCopy(op.value))
+
+	case "remove":
+		doc, _, err := removeValue(doc, path)
+		return doc, err
+
+	case "replace":
+		doc, _, err := removeValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, deepCopy(op.value))
+
+	case "move":
+		from, _ := parsePointer(op.From)
+		if op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("cannot move %s into itself", op.From)
+		}
+		doc, value, err := removeValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+
+	case "copy":
+		from, _ := parsePointer(op.From)
+		value, err := getValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, deepCopy(value))
+
+	default:
+		value, err := getValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(value, op.value) {
+			return nil, fmt.Errorf("test failed: %s", op.Path)
+		}
+		return doc, nil
+	}
+}
+
+// parsePointer splits the given RFC 6901 JSON pointer into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// escapeToken escapes the given reference token so that it may be appended to a JSON pointer.
+func escapeToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func getValue(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch container := doc.(type) {
+		case map[string]any:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("path not found: %s", token)
+			}
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = container[i]
+		default:
+			return nil, fmt.Errorf("path not found: %s", token)
+		}
+	}
+	return doc, nil
+}
+
+func addValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, path, func(parent any, token string) (any, error) {
+		switch container := parent.(type) {
+		case map[string]any:
+			container[token] = value
+			return container, nil
+		case []any:
+			i := len(container)
+			if token != "-" {
+				var err error
+				i, err = arrayIndex(token, len(container))
+				if err != nil {
+					return nil, err
+				}
+			}
+			container = append(container, nil)
+			copy(container[i+1:], container[i:])
+			container[i] = value
+			return container, nil
+		default:
+			return nil, fmt.Errorf("cannot add %s to %T", token, parent)
+		}
+	})
+}
+
+func removeValue(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole item")
+	}
+
+	var removed any
+	doc, err := updateParent(doc, path, func(parent any, token string) (any, error) {
+		switch container := parent.(type) {
+		case map[string]any:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("path not found: %s", token)
+			}
+			removed = value
+			delete(container, token)
+			return container, nil
+		case []any:
+			i, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = container[i]
+			return append(container[:i], container[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("path not found: %s", token)
+		}
+	})
+	return doc, removed, err
+}
+
+// updateParent replaces the container holding the last token of the given path with the result of
+// the given function, returning the updated document.
+func updateParent(doc any, path []string, fn func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	child, err := getValue(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	child, err = updateParent(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch container := doc.(type) {
+	case map[string]any:
+		container[path[0]] = child
+	case []any:
+		// The index has already been validated by getValue.
+		i, _ := strconv.Atoi(path[0])
+		container[i] = child
+	}
+	return doc, nil
+}
+
+// arrayIndex parses the given array index, which must not be greater than max.
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index: %s", token)
+	}
+	return i, nil
+}
+
+func deepCopy(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(value))
+		for key, v := range value {
+			result[key] = deepCopy(v)
+		}
+		return result
+	case []any:
+		result := make([]any, len(value))
+		for i, v := range value {
+			result[i] = deepCopy(v)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// knownValues holds the values known to be at each path whilst walking through a patch, paths known
+// to be absent holding an absentValue.
+type knownValues map[string]any
+
+// absentValue marks a path known to be absent.
+type absentValue struct{}
+
+// get returns the value known to be at the given path, which may be found within the value known
+// to be at one of its ancestors.
+func (k knownValues) get(pointer string) (any, bool) {
+	for known, value := range k {
+		if known != pointer && !strings.HasPrefix(pointer, known+"/") {
+			continue
+		}
+		if _, ok := value.(absentValue); ok {
+			continue
+		}
+
+		path, _ := parsePointer(strings.TrimPrefix(pointer, known))
+		value, err := getValue(value, path)
+		if err == nil {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// replaced returns the value replaced by the given add, move or copy operation, returning false if
+// it does not replace a value. An error is returned if it is unknown whether a value is replaced.
+//
+// Adding to an array inserts the value rather than replacing the element at its index.
+func (k knownValues) replaced(op patchOperation) (any, bool, error) {
+	if isArrayIndex(op.Path) {
+		return nil, false, nil
+	}
+	if _, ok := k[op.Path].(absentValue); ok {
+		return nil, false, nil
+	}
+
+	previous, ok := k.get(op.Path)
+	if !ok {
+		return nil, false, fmt.Errorf(
+			"patch is not invertible: the value at %s is unknown, precede the %s with a test, or declare it absent",
+			op.Path,
+			op.Op,
+		)
+	}
+	return previous, true, nil
+}
+
+// remove records that the value at the given path has been removed.
+func (k knownValues) remove(pointer string) {
+	k.set(pointer, absentValue{}, !isArrayIndex(pointer))
+}
+
+// set records that the value at the given path has changed, forgetting any known values of its
+// ancestors and descendants, and remembering the new value if it is known.
+func (k knownValues) set(pointer string, value any, isKnown bool) {
+	for known := range k {
+		if known == pointer || strings.HasPrefix(pointer, known+"/") || strings.HasPrefix(known, pointer+"/") {
+			delete(k, known)
+		}
+	}
+	if isKnown {
+		k[pointer] = value
+	}
+}
+
+// shift forgets the known values of the siblings of the given path if it is an array index, as
+// adding or removing an array element shifts the elements following it.
+func (k knownValues) shift(pointer string) {
+	if !isArrayIndex(pointer) {
+		return
+	}
+	k.set(pointer[:strings.LastIndex(pointer, "/")], nil, false)
+}
+
+// isArrayIndex returns true if the last token of the given path may be an array index.
+func isArrayIndex(pointer string) bool {
+	token := pointer[strings.LastIndex(pointer, "/")+1:]
+	if token == "-" {
+		return true
+	}
+	_, err := strconv.Atoi(token)
+	return err == nil
+}
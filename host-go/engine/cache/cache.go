@@ -0,0 +1,271 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+/*
+The cache package contains a content-addressed, on-disk store for remotely fetched lens modules.
+
+Module content is stored once per sha256 digest, and an index entry per URL records which digest the
+URL last resolved to, along with any HTTP validators that may be used to cheaply revalidate it.
+*/
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultTTL is the length of time a cached module is considered fresh if a Cache is created
+// using New.
+const DefaultTTL = 24 * time.Hour
+
+// ErrNotCached is returned when a module is requested in offline mode and it is not present
+// in the cache.
+var ErrNotCached = errors.New("module is not cached and offline mode is enabled")
+
+// Cache is an on-disk store of remotely fetched modules.
+//
+// It is safe to share a cache directory between processes.
+type Cache struct {
+	// Dir is the directory in which the cached modules are stored.
+	Dir string
+
+	// TTL is the length of time a cached module is considered fresh.
+	//
+	// Once a module is stale it will be revalidated against its origin before it is used. A
+	// zero value means modules are revalidated every time they are requested.
+	TTL time.Duration
+
+	// If true, modules will only ever be served from the cache and the network will never be
+	// used, regardless of how stale the cached module is.
+	Offline bool
+}
+
+// New returns a new Cache that stores its content in the given directory.
+func New(dir string) *Cache {
+	return &Cache{
+		Dir: dir,
+		TTL: DefaultTTL,
+	}
+}
+
+// Validators contain the values that may be used to conditionally fetch a module from its origin.
+//
+// They will be empty if there is no cached copy of the module.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// Response is the result of fetching a module from its origin.
+type Response struct {
+	// The module content, this will be ignored if NotModified is true.
+	Content []byte
+
+	ETag         string
+	LastModified string
+
+	// If true, the origin has declared that the cached content is still current.
+	NotModified bool
+}
+
+// FetchFunc fetches a module from its origin, conditionally upon the given validators.
+type FetchFunc func(Validators) (Response, error)
+
+// entry is the index record stored for each URL.
+type entry struct {
+	URL          string    `json:"url"`
+	Digest       string    `json:"digest"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// Get returns the content of the module at the given url.
+//
+// If a hash is provided the content is addressed by it directly, and any cached copy is returned
+// without revalidation. Otherwise the cached copy will be returned if it is fresh, else fetch will be
+// called to revalidate or replace it.
+func (c *Cache) Get(url string, hash string, fetch FetchFunc) ([]byte, error) {
+	if hash != "" {
+		digest, err := normalize(hash)
+		if err != nil {
+			return nil, err
+		}
+		content, err := c.readBlob(digest)
+		if err == nil {
+			return content, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	cached, hasEntry, err := c.readEntry(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	if hasEntry {
+		content, err = c.readBlob(cached.Digest)
+		if errors.Is(err, os.ErrNotExist) {
+			// The blob has been removed from underneath the index, so we treat the
+			// module as if it had never been cached.
+			hasEntry = false
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if hasEntry && (hash == "" || Verify(content, hash) == nil) {
+		if c.Offline || time.Since(cached.FetchedAt) < c.TTL {
+			return content, nil
+		}
+	}
+
+	if c.Offline {
+		return nil, fmt.Errorf("%w: %s", ErrNotCached, url)
+	}
+
+	var validators Validators
+	if hasEntry {
+		validators = Validators{
+			ETag:         cached.ETag,
+			LastModified: cached.LastModified,
+		}
+	}
+
+	res, err := fetch(validators)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.NotModified && hasEntry {
+		cached.FetchedAt = time.Now()
+		err = c.writeEntry(cached)
+		if err != nil {
+			return nil, err
+		}
+		return content, nil
+	}
+
+	if hash != "" {
+		err = Verify(res.Content, hash)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", url, err)
+		}
+	}
+
+	digest := Digest(res.Content)
+	err = c.writeBlob(digest, res.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.writeEntry(entry{
+		URL:          url,
+		Digest:       digest,
+		ETag:         res.ETag,
+		LastModified: res.LastModified,
+		FetchedAt:    time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Content, nil
+}
+
+func (c *Cache) blobPath(digest string) string {
+	// The digest has already been validated by this point, so it is safe to use it as a file name.
+	return filepath.Join(c.Dir, "blobs", "sha256", digest[len(sha256Prefix):])
+}
+
+func (c *Cache) entryPath(url string) string {
+	// The url is hashed as it may contain characters that are not valid within a file name.
+	return filepath.Join(c.Dir, "index", Digest([]byte(url))[len(sha256Prefix):]+".json")
+}
+
+func (c *Cache) readBlob(digest string) ([]byte, error) {
+	content, err := os.ReadFile(c.blobPath(digest))
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the content in case the file has been corrupted or tampered with.
+	err = Verify(content, digest)
+	if err != nil {
+		return nil, fmt.Errorf("cached module corrupt: %w", err)
+	}
+	return content, nil
+}
+
+func (c *Cache) writeBlob(digest string, content []byte) error {
+	return writeFileAtomic(c.blobPath(digest), content)
+}
+
+func (c *Cache) readEntry(url string) (entry, bool, error) {
+	data, err := os.ReadFile(c.entryPath(url))
+	if errors.Is(err, os.ErrNotExist) {
+		return entry{}, false, nil
+	}
+	if err != nil {
+		return entry{}, false, err
+	}
+
+	var e entry
+	err = json.Unmarshal(data, &e)
+	if err != nil || e.URL != url {
+		// A corrupt entry (or an astronomically unlikely hash collision) is treated as a cache miss,
+		// it will be overwritten upon the next successful fetch.
+		return entry{}, false, nil
+	}
+	if _, err := normalize(e.Digest); err != nil {
+		return entry{}, false, nil
+	}
+	return e, true, nil
+}
+
+func (c *Cache) writeEntry(e entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.entryPath(e.URL), data)
+}
+
+// writeFileAtomic writes the given data to a temporary file before moving it to the given path,
+// ensuring that concurrent readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
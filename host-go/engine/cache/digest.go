@@ -0,0 +1,49 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const sha256Prefix = "sha256:"
+
+// Digest returns the sha256 digest of the given content, in the form `sha256:<hex>`.
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return sha256Prefix + hex.EncodeToString(sum[:])
+}
+
+// Verify returns an error if the given content does not match the given hash.
+//
+// The hash must be a sha256 digest in the form `sha256:<hex>`, or just `<hex>`.
+func Verify(content []byte, hash string) error {
+	expected, err := normalize(hash)
+	if err != nil {
+		return err
+	}
+
+	actual := Digest(content)
+	if actual != expected {
+		return fmt.Errorf("module hash mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+// normalize returns the given hash in the form `sha256:<hex>`, or an error if it is not a
+// valid sha256 digest.
+func normalize(hash string) (string, error) {
+	hexDigest := strings.ToLower(strings.TrimPrefix(hash, sha256Prefix))
+	if len(hexDigest) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid module hash: %s", hash)
+	}
+	if _, err := hex.DecodeString(hexDigest); err != nil {
+		return "", fmt.Errorf("invalid module hash: %s", hash)
+	}
+	return sha256Prefix + hexDigest, nil
+}
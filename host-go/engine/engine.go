@@ -6,12 +6,12 @@ package engine
 
 import (
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
-	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/lens-vm/lens/host-go/engine/builtin"
+	"github.com/lens-vm/lens/host-go/engine/cache"
 	"github.com/lens-vm/lens/host-go/engine/module"
 	"github.com/lens-vm/lens/host-go/engine/pipes"
 	"github.com/sourcenetwork/immutable/enumerable"
@@ -48,44 +48,83 @@ func append[TSource any, TResult any](src enumerable.Enumerable[TSource], instan
 	}
 }
 
-// NewModule instantiates a new module from the WAT code at the given path.
+// NewModule instantiates a new module from the wasm module at the given path, which may be either
+// a wasm binary or in the WebAssembly text format (WAT).
 //
-// The path must have one of the following prefixes:
+// The path will be resolved using the ModuleResolver registered against its scheme, by default
+// these are:
 // - "file:" local filesystem file
 // - "http:" remote file served over http
 // - "https:" remote file served over https
+// - "data:" inline module content, typically base64 encoded
+// - plain paths, which will be read from the local filesystem
+//
+// Additional schemes may be supported using RegisterModuleResolver or WithModuleResolvers.
+//
+// Paths with the "native:" scheme refer to Go modules registered using RegisterNativeModule,
+// these are returned as they are without involving the runtime, as are paths with the "builtin:"
+// scheme, which refer to the modules of the builtin package.
 //
 // This is a fairly expensive operation.
-func NewModule(runtime module.Runtime, path string) (module.Module, error) {
-	parsed, err := url.Parse(path)
-	if err != nil {
-		return nil, err
+func NewModule(runtime module.Runtime, path string, opts ...ModuleOption) (module.Module, error) {
+	if name, ok := strings.CutPrefix(path, NativeScheme+":"); ok {
+		return getNativeModule(name)
 	}
-
-	switch strings.ToLower(parsed.Scheme) {
-	case "http", "https":
-		res, err := http.Get(path)
+	if name, ok := strings.CutPrefix(path, builtin.Scheme+":"); ok {
+		builtinModule, err := builtin.Get(name)
 		if err != nil {
 			return nil, err
 		}
-		defer res.Body.Close()
+		return builtinModule, nil
+	}
+
+	content, err := ReadModule(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return runtime.NewModule(content)
+}
+
+// ReadModule returns the content of the module at the given path, without loading it into a runtime.
+//
+// The path is resolved in exactly the same way as for NewModule.
+func ReadModule(path string, opts ...ModuleOption) ([]byte, error) {
+	options := newModuleOptions(opts)
 
-		content, err := io.ReadAll(res.Body)
+	var parsed *url.URL
+	if filepath.IsAbs(path) {
+		// Absolute paths need special handling, as Windows drive letters would otherwise
+		// be mistaken for URL schemes.
+		parsed = &url.URL{Path: path}
+	} else {
+		var err error
+		parsed, err = url.Parse(path)
 		if err != nil {
 			return nil, err
 		}
-		return runtime.NewModule(content)
+	}
 
-	case "file":
-		content, err := os.ReadFile(parsed.Path)
+	content, err := options.resolvers.Resolve(ModuleRequest{
+		Path:        path,
+		URL:         parsed,
+		Hash:        options.hash,
+		BaseDir:     options.baseDir,
+		Cache:       options.cache,
+		FetchPolicy: options.policy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if options.hash != "" {
+		err = cache.Verify(content, options.hash)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%s: %w", path, err)
 		}
-		return runtime.NewModule(content)
-
-	default:
-		return nil, fmt.Errorf("invalid module path: %s", path)
 	}
+
+	return content, nil
 }
 
 func NewInstance(module module.Module, paramSets ...map[string]any) (module.Instance, error) {
@@ -95,3 +134,15 @@ func NewInstance(module module.Module, paramSets ...map[string]any) (module.Inst
 func NewInverse(module module.Module, paramSets ...map[string]any) (module.Instance, error) {
 	return module.NewInstance("inverse", paramSets...)
 }
+
+// Predicate reports whether the given item, decoded from json, should be transformed.
+type Predicate = pipes.Predicate
+
+// NewConditional returns an instance that only passes the items matching the given predicate
+// through the given instance.
+//
+// Items that do not match bypass the instance unchanged, keeping their position in the stream
+// relative to the items yielded by the instance.
+func NewConditional(instance module.Instance, predicate Predicate) module.Instance {
+	return pipes.NewConditional(instance, predicate)
+}
@@ -0,0 +1,261 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lens-vm/lens/host-go/engine/cache"
+)
+
+// FetchPolicy controls how modules are fetched over http(s).
+type FetchPolicy struct {
+	// Client is the http client used to fetch modules.
+	//
+	// If nil, http.DefaultClient will be used.
+	Client *http.Client
+
+	// Timeout is the maximum duration of a single fetch attempt, including reading the body.
+	//
+	// A zero value means there is no timeout.
+	Timeout time.Duration
+
+	// MaxBytes is the maximum size of a module body in bytes.
+	//
+	// A zero value means there is no limit.
+	MaxBytes int64
+
+	// AllowedSchemes is the set of schemes that modules may be fetched over.
+	//
+	// If empty, both http and https are allowed.
+	AllowedSchemes []string
+
+	// AllowedHosts is the set of hosts that modules may be fetched from, including via redirect.
+	//
+	// Entries may be a host name, a host:port pair, or a wildcard such as "*.example.com" which
+	// matches any sub-domain of example.com. If empty, any host is allowed.
+	AllowedHosts []string
+
+	// Retries is the number of times a failed fetch will be retried.
+	//
+	// Only network errors, 429 and 5xx responses are retried.
+	Retries int
+
+	// Backoff is the delay before the first retry, it doubles with each subsequent retry.
+	Backoff time.Duration
+}
+
+// DefaultFetchPolicy is the policy used if no other is provided.
+var DefaultFetchPolicy = FetchPolicy{
+	Timeout:  30 * time.Second,
+	MaxBytes: 64 << 20,
+	Retries:  2,
+	Backoff:  500 * time.Millisecond,
+}
+
+// HTTPStatusError is returned when a module is requested over http(s) and the server
+// responds with a non-2xx status.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("fetching module %s: unexpected http status %s", e.URL, e.Status)
+}
+
+// fetchHTTP returns the content of the module at the given http(s) url, going via the
+// given cache if there is one.
+func fetchHTTP(path string, hash string, moduleCache *cache.Cache, policy FetchPolicy) ([]byte, error) {
+	if moduleCache == nil {
+		res, err := get(path, cache.Validators{}, policy)
+		if err != nil {
+			return nil, err
+		}
+		return res.Content, nil
+	}
+
+	return moduleCache.Get(path, hash, func(validators cache.Validators) (cache.Response, error) {
+		return get(path, validators, policy)
+	})
+}
+
+// get fetches the module at the given url, retrying as permitted by the given policy.
+func get(path string, validators cache.Validators, policy FetchPolicy) (cache.Response, error) {
+	parsed, err := url.Parse(path)
+	if err != nil {
+		return cache.Response{}, err
+	}
+	err = policy.checkAllowed(parsed)
+	if err != nil {
+		return cache.Response{}, err
+	}
+
+	backoff := policy.Backoff
+	for attempt := 0; ; attempt++ {
+		res, retryable, err := getOnce(path, validators, policy)
+		if err == nil || !retryable || attempt >= policy.Retries {
+			return res, err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// getOnce makes a single attempt at fetching the module at the given url.
+//
+// It returns true if the returned error is transient and the fetch may be retried.
+func getOnce(path string, validators cache.Validators, policy FetchPolicy) (cache.Response, bool, error) {
+	ctx := context.Background()
+	if policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return cache.Response{}, false, err
+	}
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+
+	res, err := policy.client().Do(req)
+	if err != nil {
+		var policyErr *fetchPolicyError
+		if errors.As(err, &policyErr) {
+			return cache.Response{}, false, policyErr
+		}
+		return cache.Response{}, true, fmt.Errorf("fetching module %s: %w", path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		return cache.Response{NotModified: true}, false, nil
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		retryable := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+		return cache.Response{}, retryable, &HTTPStatusError{
+			URL:        path,
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+		}
+	}
+
+	var body io.Reader = res.Body
+	if policy.MaxBytes > 0 {
+		// Read one byte more than the limit so that we can tell if the body exceeds it.
+		body = io.LimitReader(res.Body, policy.MaxBytes+1)
+	}
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return cache.Response{}, true, fmt.Errorf("fetching module %s: %w", path, err)
+	}
+	if policy.MaxBytes > 0 && int64(len(content)) > policy.MaxBytes {
+		return cache.Response{}, false, fmt.Errorf(
+			"fetching module %s: body exceeds the maximum size of %d bytes",
+			path,
+			policy.MaxBytes,
+		)
+	}
+
+	return cache.Response{
+		Content:      content,
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+	}, false, nil
+}
+
+// fetchPolicyError is returned when a url is not permitted by the fetch policy.
+type fetchPolicyError struct {
+	url    string
+	reason string
+}
+
+func (e *fetchPolicyError) Error() string {
+	return fmt.Sprintf("fetching module %s: %s", e.url, e.reason)
+}
+
+// client returns the http client configured to enforce this policy upon redirects.
+func (p FetchPolicy) client() *http.Client {
+	base := p.Client
+	if base == nil {
+		base = http.DefaultClient
+	}
+
+	// Shallow copy the client so that we do not mutate the one we were given.
+	client := *base
+	checkRedirect := base.CheckRedirect
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		err := p.checkAllowed(req.URL)
+		if err != nil {
+			return err
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		// Mirror the default behaviour of the http package.
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &client
+}
+
+// checkAllowed returns an error if the given url may not be fetched under this policy.
+func (p FetchPolicy) checkAllowed(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if len(p.AllowedSchemes) > 0 {
+		if !containsFold(p.AllowedSchemes, scheme) {
+			return &fetchPolicyError{url: u.String(), reason: fmt.Sprintf("scheme %q is not allowed", scheme)}
+		}
+	} else if scheme != "http" && scheme != "https" {
+		return &fetchPolicyError{url: u.String(), reason: fmt.Sprintf("scheme %q is not allowed", scheme)}
+	}
+
+	if len(p.AllowedHosts) == 0 {
+		return nil
+	}
+
+	hostname := strings.ToLower(u.Hostname())
+	for _, allowed := range p.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if wildcard, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(hostname, "."+wildcard) {
+				return nil
+			}
+			continue
+		}
+		if allowed == hostname || allowed == strings.ToLower(u.Host) {
+			return nil
+		}
+	}
+	return &fetchPolicyError{url: u.String(), reason: fmt.Sprintf("host %q is not allowed", u.Host)}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
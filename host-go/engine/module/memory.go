@@ -4,7 +4,11 @@
 
 package module
 
-import "io"
+import (
+	"fmt"
+	"io"
+	"math"
+)
 
 // Memory is an interface for reading and writing to a
 // module's shared linear memory.
@@ -13,7 +17,16 @@ type Memory interface {
 	io.WriterAt
 }
 
-var _ (Memory) = (*BytesMemory)(nil)
+// SizedMemory is a Memory that is able to report its current size.
+//
+// The memories of all of the runtimes provided by this repository implement it.
+type SizedMemory interface {
+	Memory
+	// Size returns the current size of the memory in bytes.
+	Size() int64
+}
+
+var _ (SizedMemory) = (*BytesMemory)(nil)
 
 // BytesMemory converts a byte slice into a module Memory.
 type BytesMemory struct {
@@ -36,3 +49,71 @@ func (s *BytesMemory) WriteAt(src []byte, offset int64) (int, error) {
 	n := copy(s.data[offset:], src)
 	return n, nil
 }
+
+// Size implements the SizedMemory interface.
+func (s *BytesMemory) Size() int64 {
+	return int64(len(s.data))
+}
+
+var _ (SizedMemory) = (*HostMemory)(nil)
+
+// HostMemory is a growable module Memory hosted by Go.
+//
+// It may be used by instances that are not backed by a wasm module, allowing them to exchange
+// items with their neighbouring instances using the usual protocol.
+type HostMemory struct {
+	data []byte
+}
+
+// NewHostMemory returns a new, empty, HostMemory.
+func NewHostMemory() *HostMemory {
+	return &HostMemory{}
+}
+
+// Alloc allocates the given number of bytes at the end of the memory and returns the start
+// index to the allocated block.
+func (m *HostMemory) Alloc(size MemSize) (MemSize, error) {
+	if size < 0 {
+		return 0, fmt.Errorf("invalid allocation size: %d", size)
+	}
+	index := len(m.data)
+	if int64(index)+int64(size) > math.MaxInt32 {
+		return 0, fmt.Errorf("host memory exhausted allocating %d bytes", size)
+	}
+	m.data = append(m.data, make([]byte, size)...)
+	return MemSize(index), nil
+}
+
+// Reset releases all allocated blocks, previously returned indexes must no longer be used.
+func (m *HostMemory) Reset() {
+	m.data = m.data[:0]
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (m *HostMemory) ReadAt(dst []byte, offset int64) (int, error) {
+	if offset >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(dst, m.data[offset:])
+	if n < len(dst) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt implements the io.WriterAt interface.
+func (m *HostMemory) WriteAt(src []byte, offset int64) (int, error) {
+	if offset > int64(len(m.data)) {
+		return 0, io.ErrShortWrite
+	}
+	n := copy(m.data[offset:], src)
+	if n < len(src) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// Size implements the SizedMemory interface, it is the number of bytes allocated.
+func (m *HostMemory) Size() int64 {
+	return int64(len(m.data))
+}
@@ -6,7 +6,7 @@ package module
 
 // Runtime represents the runtime hosting lens instances.
 type Runtime interface {
-	// NewModule instantiates a new module from the given WAT code.
+	// NewModule instantiates a new module from the given wasm binary or WAT code.
 	//
 	// This is a fairly expensive operation.
 	NewModule([]byte) (Module, error)
@@ -0,0 +1,99 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/engine/pipes"
+)
+
+// NativeScheme is the scheme of the module paths that refer to modules registered using
+// RegisterNativeModule, for example "native:rename".
+const NativeScheme = "native"
+
+// nativeModule is a module hosted by Go, rather than a wasm runtime.
+type nativeModule[TIn any, TOut any] struct {
+	transform func(next func() (TIn, bool, error)) (TOut, bool, error)
+}
+
+var _ module.Module = (*nativeModule[any, any])(nil)
+
+// NativeModule returns a module whose instances transform items using the given Go function,
+// see pipes.NewNative.
+//
+// The function is shared by every instance of the module, so it should not hold any state. Native
+// modules have no inverse and accept no arguments, attempting to create such instances will return
+// an error.
+func NativeModule[TIn any, TOut any](transform func(next func() (TIn, bool, error)) (TOut, bool, error)) module.Module {
+	return &nativeModule[TIn, TOut]{
+		transform: transform,
+	}
+}
+
+// MapModule returns a native module that transforms each item using the given function,
+// see NativeModule.
+func MapModule[TIn any, TOut any](fn func(in TIn) (TOut, error)) module.Module {
+	return NativeModule(func(next func() (TIn, bool, error)) (TOut, bool, error) {
+		var result TOut
+
+		item, hasNext, err := next()
+		if err != nil || !hasNext {
+			return result, false, err
+		}
+
+		result, err = fn(item)
+		if err != nil {
+			return result, false, err
+		}
+		return result, true, nil
+	})
+}
+
+func (m *nativeModule[TIn, TOut]) NewInstance(functionName string, paramSets ...map[string]any) (module.Instance, error) {
+	if functionName == "inverse" {
+		return module.Instance{}, errors.New("native modules cannot be inverted")
+	}
+	if functionName != "transform" {
+		return module.Instance{}, fmt.Errorf("native modules have no %s function", functionName)
+	}
+	for _, paramSet := range paramSets {
+		if len(paramSet) == 0 {
+			continue
+		}
+		return module.Instance{}, errors.New("native modules do not accept arguments")
+	}
+
+	return pipes.NewNative(m.transform), nil
+}
+
+var (
+	nativeModulesMu sync.RWMutex
+	nativeModules   = map[string]module.Module{}
+)
+
+// RegisterNativeModule registers the given module against the given name, allowing it to be
+// referenced by lens files using the path "native:<name>".
+//
+// Any existing module registered against the name will be replaced.
+func RegisterNativeModule(name string, m module.Module) {
+	nativeModulesMu.Lock()
+	defer nativeModulesMu.Unlock()
+	nativeModules[name] = m
+}
+
+// getNativeModule returns the native module registered against the given name.
+func getNativeModule(name string) (module.Module, error) {
+	nativeModulesMu.RLock()
+	defer nativeModulesMu.RUnlock()
+	m, ok := nativeModules[name]
+	if !ok {
+		return nil, fmt.Errorf("native module %s has not been registered", name)
+	}
+	return m, nil
+}
@@ -0,0 +1,75 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package engine
+
+import "github.com/lens-vm/lens/host-go/engine/cache"
+
+// ModuleOption configures how NewModule sources a module.
+type ModuleOption func(*moduleOptions)
+
+type moduleOptions struct {
+	resolvers *ModuleResolvers
+	cache     *cache.Cache
+	hash      string
+	baseDir   string
+	policy    FetchPolicy
+}
+
+func newModuleOptions(opts []ModuleOption) *moduleOptions {
+	options := &moduleOptions{
+		resolvers: DefaultModuleResolvers,
+		policy:    DefaultFetchPolicy,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithCache sets the cache through which remote modules will be fetched.
+//
+// If not provided, remote modules will be fetched from their origin every time.
+func WithCache(c *cache.Cache) ModuleOption {
+	return func(o *moduleOptions) {
+		o.cache = c
+	}
+}
+
+// WithHash sets the expected sha256 digest of the module, in the form `sha256:<hex>`.
+//
+// The module content will be verified against it, and cached remote modules with a matching
+// hash will be used without revalidation.
+func WithHash(hash string) ModuleOption {
+	return func(o *moduleOptions) {
+		o.hash = hash
+	}
+}
+
+// WithModuleResolvers sets the registry used to resolve module paths.
+//
+// If not provided, DefaultModuleResolvers will be used.
+func WithModuleResolvers(resolvers *ModuleResolvers) ModuleOption {
+	return func(o *moduleOptions) {
+		o.resolvers = resolvers
+	}
+}
+
+// WithBaseDir sets the directory against which relative module paths will be resolved.
+//
+// If not provided, relative paths will be resolved against the current working directory.
+func WithBaseDir(dir string) ModuleOption {
+	return func(o *moduleOptions) {
+		o.baseDir = dir
+	}
+}
+
+// WithFetchPolicy sets the policy with which remote modules will be fetched.
+//
+// If not provided, DefaultFetchPolicy will be used.
+func WithFetchPolicy(policy FetchPolicy) ModuleOption {
+	return func(o *moduleOptions) {
+		o.policy = policy
+	}
+}
@@ -0,0 +1,185 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package pipes
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/lens-vm/lens/host-go/engine/module"
+)
+
+// Predicate reports whether the given item, decoded from json, should be transformed.
+type Predicate func(item any) (bool, error)
+
+// conditional is a host-side instance that only passes the items matching its predicate
+// through the wrapped instance.
+type conditional struct {
+	instance  module.Instance
+	predicate Predicate
+
+	// memory hosts the items written by the upstream source and read by the downstream consumer.
+	memory *module.HostMemory
+
+	// queue holds the serialized items that have bypassed the wrapped instance, or have been
+	// yielded by it, that have not yet been yielded downstream.
+	queue [][]byte
+}
+
+// NewConditional returns an instance that only passes the items matching the given predicate
+// through the given instance.
+//
+// Items that do not match bypass the instance unchanged, keeping their position in the stream
+// relative to the items yielded by the instance. Items that are not json, such as errors, are
+// always passed through the instance.
+func NewConditional(instance module.Instance, predicate Predicate) module.Instance {
+	c := &conditional{
+		instance:  instance,
+		predicate: predicate,
+		memory:    module.NewHostMemory(),
+	}
+
+	return module.Instance{
+		Alloc:     c.memory.Alloc,
+		Transform: c.transform,
+		Memory: func() module.Memory {
+			return c.memory
+		},
+		OwnedBy: instance,
+	}
+}
+
+func (c *conditional) transform(next func() module.MemSize) (module.MemSize, error) {
+	// The downstream consumer has finished with the previously yielded item, and items written
+	// by the upstream source are copied out as soon as they are received.
+	c.memory.Reset()
+
+	if len(c.queue) == 0 {
+		index, err := c.instance.Transform(func() module.MemSize {
+			return c.mustGetNext(next)
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		item, err := readItem(c.instance.Memory(), index)
+		if err != nil {
+			return 0, err
+		}
+		c.queue = append(c.queue, item)
+	}
+
+	item := c.queue[0]
+	c.queue = c.queue[1:]
+
+	index, err := c.memory.Alloc(module.MemSize(len(item)))
+	if err != nil {
+		return 0, err
+	}
+	_, err = c.memory.WriteAt(item, int64(index))
+	if err != nil {
+		return 0, err
+	}
+	return index, nil
+}
+
+// mustGetNext pulls items from upstream until one should be transformed by the wrapped instance,
+// queueing any that bypass it, and copies the item into the wrapped instance's memory.
+//
+// If an error is generated it will be passed to the wrapped instance in place of an item, if the
+// writing of the error fails it will panic.
+func (c *conditional) mustGetNext(next func() module.MemSize) module.MemSize {
+	item, err := c.getNext(next)
+	if err != nil {
+		item, err = serializeItem(module.ErrTypeID, []byte(err.Error()))
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	index, err := c.instance.Alloc(module.MemSize(len(item)))
+	if err != nil {
+		panic(err)
+	}
+	_, err = c.instance.Memory().WriteAt(item, int64(index))
+	if err != nil {
+		panic(err)
+	}
+	return index
+}
+
+func (c *conditional) getNext(next func() module.MemSize) ([]byte, error) {
+	for {
+		item, err := readItem(c.memory, next())
+		if err != nil {
+			return nil, err
+		}
+
+		matches, err := c.matches(item)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			return item, nil
+		}
+		c.queue = append(c.queue, item)
+	}
+}
+
+func (c *conditional) matches(item []byte) (bool, error) {
+	id, err := ReadTypeId(bytes.NewReader(item))
+	if err != nil {
+		return false, err
+	}
+	if id != module.JSONTypeID {
+		return true, nil
+	}
+
+	_, data, err := ReadItem(bytes.NewReader(item))
+	if err != nil {
+		return false, err
+	}
+
+	var value any
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		return false, err
+	}
+	return c.predicate(value)
+}
+
+// readItem returns a copy of the serialized item at the given index.
+func readItem(memory module.Memory, index module.MemSize) ([]byte, error) {
+	r := io.NewSectionReader(memory, int64(index), math.MaxInt64)
+
+	id, err := ReadTypeId(r)
+	if err != nil {
+		return nil, err
+	}
+	if id.IsEOS() {
+		return serializeItem(id, nil)
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	id, data, err := ReadItem(r)
+	if err != nil {
+		return nil, err
+	}
+	return serializeItem(id, data)
+}
+
+func serializeItem(id module.TypeIdType, data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	err := WriteItem(&out, id, data)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
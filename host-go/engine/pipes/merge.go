@@ -0,0 +1,102 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package pipes
+
+import (
+	"github.com/sourcenetwork/immutable/enumerable"
+)
+
+// combined yields the items of several sources, the order in which it reads them is determined
+// by whether it is interleaving them or not.
+type combined[T any] struct {
+	sources []enumerable.Enumerable[T]
+	// interleave is true if the sources should be read from in turn, rather than one after another.
+	interleave bool
+
+	// exhausted is true for each source that has no more items.
+	exhausted []bool
+	// index is the index of the source to read from next.
+	index int
+	// current is the source from which the current item was read.
+	current enumerable.Enumerable[T]
+}
+
+var _ Pipe[int] = (*combined[int])(nil)
+
+// Concat returns a Pipe yielding all of the items of each of the given sources, one source
+// after another.
+func Concat[T any](sources ...enumerable.Enumerable[T]) Pipe[T] {
+	return &combined[T]{
+		sources:   sources,
+		exhausted: make([]bool, len(sources)),
+	}
+}
+
+// Merge returns a Pipe yielding the items of the given sources interleaved, taking one item from
+// each source in turn until they have all been exhausted.
+func Merge[T any](sources ...enumerable.Enumerable[T]) Pipe[T] {
+	return &combined[T]{
+		sources:    sources,
+		interleave: true,
+		exhausted:  make([]bool, len(sources)),
+	}
+}
+
+func (c *combined[T]) Next() (bool, error) {
+	for tried := 0; tried < len(c.sources); {
+		index := c.index
+		if c.exhausted[index] {
+			c.index = (c.index + 1) % len(c.sources)
+			tried++
+			continue
+		}
+
+		source := c.sources[index]
+		hasNext, err := source.Next()
+		if err != nil {
+			return false, err
+		}
+		if !hasNext {
+			c.exhausted[index] = true
+			continue
+		}
+
+		if c.interleave {
+			c.index = (c.index + 1) % len(c.sources)
+		}
+		c.current = source
+		return true, nil
+	}
+
+	c.current = nil
+	return false, nil
+}
+
+func (c *combined[T]) Value() (T, error) {
+	if c.current == nil {
+		var zero T
+		return zero, nil
+	}
+	return c.current.Value()
+}
+
+func (c *combined[T]) Bytes() ([]byte, error) {
+	if c.current == nil {
+		return nil, nil
+	}
+	if pipe, ok := c.current.(Pipe[T]); ok {
+		return pipe.Bytes()
+	}
+	return marshalItem(c.current.Value())
+}
+
+func (c *combined[T]) Reset() {
+	for i, source := range c.sources {
+		source.Reset()
+		c.exhausted[i] = false
+	}
+	c.index = 0
+	c.current = nil
+}
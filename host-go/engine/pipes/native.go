@@ -0,0 +1,115 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package pipes
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/lens-vm/lens/host-go/engine/module"
+)
+
+// native is a host-side instance that transforms items using a Go function.
+type native[TIn any, TOut any] struct {
+	transform func(next func() (TIn, bool, error)) (TOut, bool, error)
+
+	// memory hosts the items written by the upstream source and read by the downstream consumer.
+	memory *module.HostMemory
+}
+
+// NewNative returns an instance that transforms items using the given Go function, allowing Go
+// code to be appended to a lens alongside wasm instances without any wasm overhead.
+//
+// The function is called each time the downstream consumer wants an item, it may pull as many
+// items as it needs from next, which will return false once there are no more. The function should
+// return false once it has no more items to yield.
+func NewNative[TIn any, TOut any](transform func(next func() (TIn, bool, error)) (TOut, bool, error)) module.Instance {
+	n := &native[TIn, TOut]{
+		transform: transform,
+		memory:    module.NewHostMemory(),
+	}
+
+	return module.Instance{
+		Alloc:     n.memory.Alloc,
+		Transform: n.transformItem,
+		Memory: func() module.Memory {
+			return n.memory
+		},
+	}
+}
+
+func (n *native[TIn, TOut]) transformItem(next func() module.MemSize) (module.MemSize, error) {
+	// The downstream consumer has finished with the previously yielded item.
+	n.memory.Reset()
+
+	result, hasNext, err := n.transform(func() (TIn, bool, error) {
+		return n.getNext(next)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !hasNext {
+		return n.write(module.EOSTypeID, nil)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return 0, err
+	}
+	return n.write(module.JSONTypeID, data)
+}
+
+func (n *native[TIn, TOut]) getNext(next func() module.MemSize) (TIn, bool, error) {
+	var item TIn
+
+	r := io.NewSectionReader(n.memory, int64(next()), math.MaxInt64)
+	id, err := ReadTypeId(r)
+	if err != nil {
+		return item, false, err
+	}
+	if id.IsEOS() {
+		return item, false, nil
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return item, false, err
+	}
+	id, data, err := ReadItem(r)
+	if err != nil {
+		return item, false, err
+	}
+
+	switch id {
+	case module.ErrTypeID:
+		return item, false, errors.New(string(data))
+	case module.JSONTypeID:
+		err = json.Unmarshal(data, &item)
+		if err != nil {
+			return item, false, err
+		}
+	}
+	return item, true, nil
+}
+
+// write writes the given item to the end of the instance's memory, returning its index.
+func (n *native[TIn, TOut]) write(id module.TypeIdType, data []byte) (module.MemSize, error) {
+	item, err := serializeItem(id, data)
+	if err != nil {
+		return 0, err
+	}
+
+	index, err := n.memory.Alloc(module.MemSize(len(item)))
+	if err != nil {
+		return 0, err
+	}
+	_, err = n.memory.WriteAt(item, int64(index))
+	if err != nil {
+		return 0, err
+	}
+	return index, nil
+}
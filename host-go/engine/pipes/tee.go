@@ -0,0 +1,168 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package pipes
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/sourcenetwork/immutable/enumerable"
+)
+
+// teeItem is an item read from the source of a tee, buffered until all branches have read it.
+type teeItem[T any] struct {
+	value    T
+	valueErr error
+	bytes    []byte
+	bytesErr error
+}
+
+// tee is the state shared by the branches of a tee.
+type tee[T any] struct {
+	mu     sync.Mutex
+	source enumerable.Enumerable[T]
+
+	// buffer holds the items read from source that have not yet been read by every branch.
+	buffer []teeItem[T]
+	// offset is the position within the source of the first item in buffer.
+	offset int
+	// done is true once source has been exhausted.
+	done bool
+
+	branches []*teeBranch[T]
+}
+
+type teeBranch[T any] struct {
+	tee *tee[T]
+
+	// position is the position within the source of the next item to be read by this branch.
+	position int
+	current  teeItem[T]
+}
+
+var _ Pipe[int] = (*teeBranch[int])(nil)
+
+// Tee splits the given source into n branches, each of which will yield every item of the source.
+//
+// Items are read from the source once, and buffered until every branch has read them - branches
+// that are read at very different rates may result in a large buffer. Resetting any branch will
+// reset the source and every branch.
+//
+// The branches may be read from different goroutines, but each branch must only be read by one
+// goroutine at a time.
+func Tee[T any](source enumerable.Enumerable[T], n int) []Pipe[T] {
+	t := &tee[T]{
+		source: source,
+	}
+
+	branches := make([]Pipe[T], n)
+	for i := range branches {
+		branch := &teeBranch[T]{
+			tee: t,
+		}
+		t.branches = append(t.branches, branch)
+		branches[i] = branch
+	}
+	return branches
+}
+
+func (b *teeBranch[T]) Next() (bool, error) {
+	t := b.tee
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if b.position == t.offset+len(t.buffer) {
+		if t.done {
+			return false, nil
+		}
+
+		hasNext, err := t.source.Next()
+		if err != nil {
+			return false, err
+		}
+		if !hasNext {
+			t.done = true
+			return false, nil
+		}
+
+		t.buffer = append(t.buffer, t.read())
+	}
+
+	b.current = t.buffer[b.position-t.offset]
+	b.position++
+	t.trim()
+	return true, nil
+}
+
+func (b *teeBranch[T]) Value() (T, error) {
+	return b.current.value, b.current.valueErr
+}
+
+func (b *teeBranch[T]) Bytes() ([]byte, error) {
+	return b.current.bytes, b.current.bytesErr
+}
+
+func (b *teeBranch[T]) Reset() {
+	t := b.tee
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.source.Reset()
+	t.buffer = nil
+	t.offset = 0
+	t.done = false
+	for _, branch := range t.branches {
+		branch.position = 0
+		branch.current = teeItem[T]{}
+	}
+}
+
+// read reads the current item from the source.
+func (t *tee[T]) read() teeItem[T] {
+	var item teeItem[T]
+	item.value, item.valueErr = t.source.Value()
+	if pipe, ok := t.source.(Pipe[T]); ok {
+		item.bytes, item.bytesErr = pipe.Bytes()
+	} else {
+		item.bytes, item.bytesErr = marshalItem(item.value, item.valueErr)
+	}
+	return item
+}
+
+// trim drops the buffered items that have been read by every branch.
+func (t *tee[T]) trim() {
+	minPosition := t.offset + len(t.buffer)
+	for _, branch := range t.branches {
+		minPosition = min(minPosition, branch.position)
+	}
+
+	consumed := minPosition - t.offset
+	if consumed == 0 {
+		return
+	}
+
+	// Clear the dropped items so that they may be garbage collected.
+	clear(t.buffer[:consumed])
+	t.buffer = t.buffer[consumed:]
+	t.offset = minPosition
+}
+
+// marshalItem serializes the given value into a json item, or an error item if valueErr is not nil.
+func marshalItem[T any](value T, valueErr error) ([]byte, error) {
+	var out bytes.Buffer
+	if valueErr != nil {
+		err := WriteItem(&out, module.ErrTypeID, []byte(valueErr.Error()))
+		return out.Bytes(), err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	err = WriteItem(&out, module.JSONTypeID, data)
+	return out.Bytes(), err
+}
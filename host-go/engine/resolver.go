@@ -0,0 +1,187 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package engine
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/lens-vm/lens/host-go/engine/cache"
+)
+
+// ModuleRequest describes a module that a ModuleResolver has been asked to resolve.
+type ModuleRequest struct {
+	// Path is the module path, exactly as it was provided to NewModule.
+	Path string
+
+	// URL is the parsed form of Path.
+	URL *url.URL
+
+	// Hash is the expected sha256 digest of the module, it may be empty.
+	//
+	// Resolvers do not need to verify the content against it, NewModule will do so.
+	Hash string
+
+	// BaseDir is the directory against which relative paths should be resolved.
+	//
+	// If empty, relative paths will be resolved against the current working directory.
+	BaseDir string
+
+	// Cache is the cache through which remote modules should be fetched, it may be nil.
+	Cache *cache.Cache
+
+	// FetchPolicy is the policy with which remote modules should be fetched.
+	FetchPolicy FetchPolicy
+}
+
+// ModuleResolver resolves module paths of a given scheme to their wasm content.
+type ModuleResolver interface {
+	// Resolve returns the content of the requested module.
+	Resolve(ModuleRequest) ([]byte, error)
+}
+
+// ModuleResolverFunc allows a plain function to be used as a ModuleResolver.
+type ModuleResolverFunc func(ModuleRequest) ([]byte, error)
+
+var _ ModuleResolver = (ModuleResolverFunc)(nil)
+
+func (f ModuleResolverFunc) Resolve(req ModuleRequest) ([]byte, error) {
+	return f(req)
+}
+
+// ModuleResolvers is a registry of ModuleResolvers keyed by URI scheme.
+//
+// It is safe for concurrent use.
+type ModuleResolvers struct {
+	mu        sync.RWMutex
+	resolvers map[string]ModuleResolver
+}
+
+// NewModuleResolvers returns a new registry containing the built-in resolvers:
+// - "file:" local filesystem file
+// - "http:" remote file served over http
+// - "https:" remote file served over https
+// - "data:" inline module content, typically base64 encoded
+// - plain paths, which will be read from the local filesystem relative to the base directory
+func NewModuleResolvers() *ModuleResolvers {
+	return &ModuleResolvers{
+		resolvers: map[string]ModuleResolver{
+			"":      ModuleResolverFunc(resolveLocalPath),
+			"file":  ModuleResolverFunc(resolveFile),
+			"http":  ModuleResolverFunc(resolveHTTP),
+			"https": ModuleResolverFunc(resolveHTTP),
+			"data":  ModuleResolverFunc(resolveData),
+		},
+	}
+}
+
+// DefaultModuleResolvers is the registry used by NewModule if no other is provided.
+var DefaultModuleResolvers = NewModuleResolvers()
+
+// RegisterModuleResolver registers the given resolver against the given scheme in
+// DefaultModuleResolvers.
+//
+// Any existing resolver for the scheme will be replaced.
+func RegisterModuleResolver(scheme string, resolver ModuleResolver) {
+	DefaultModuleResolvers.Register(scheme, resolver)
+}
+
+// Register registers the given resolver against the given scheme.
+//
+// Schemes are case-insensitive. Any existing resolver for the scheme will be replaced.
+func (r *ModuleResolvers) Register(scheme string, resolver ModuleResolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resolvers[strings.ToLower(scheme)] = resolver
+}
+
+// Get returns the resolver registered against the given scheme, and true if one was found.
+func (r *ModuleResolvers) Get(scheme string) (ModuleResolver, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	resolver, ok := r.resolvers[strings.ToLower(scheme)]
+	return resolver, ok
+}
+
+// Resolve returns the content of the module at the given path, using the resolver
+// registered against its scheme.
+func (r *ModuleResolvers) Resolve(req ModuleRequest) ([]byte, error) {
+	scheme := req.URL.Scheme
+	resolver, ok := r.Get(scheme)
+	if !ok {
+		return nil, fmt.Errorf("invalid module path: %s", req.Path)
+	}
+	return resolver.Resolve(req)
+}
+
+// NewFSResolver returns a resolver that reads modules from the given filesystem, such as
+// an `embed.FS`.
+//
+// It may be registered against any scheme, for example:
+//
+//	//go:embed lenses
+//	var lenses embed.FS
+//	engine.RegisterModuleResolver("embed", engine.NewFSResolver(lenses))
+//
+// after which modules can be referenced as "embed:lenses/rename.wasm".
+func NewFSResolver(fsys fs.FS) ModuleResolver {
+	return ModuleResolverFunc(func(req ModuleRequest) ([]byte, error) {
+		name := req.URL.Opaque
+		if name == "" {
+			name = path.Join(req.URL.Host, req.URL.Path)
+		}
+		name = strings.TrimPrefix(name, "/")
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("invalid module path: %s", req.Path)
+		}
+		return fs.ReadFile(fsys, name)
+	})
+}
+
+func resolveLocalPath(req ModuleRequest) ([]byte, error) {
+	localPath := filepath.FromSlash(req.Path)
+	if !filepath.IsAbs(localPath) && req.BaseDir != "" {
+		localPath = filepath.Join(req.BaseDir, localPath)
+	}
+	return os.ReadFile(localPath)
+}
+
+func resolveFile(req ModuleRequest) ([]byte, error) {
+	if req.URL.Opaque != "" {
+		// A file URL without a leading slash, such as "file:lenses/rename.wasm", is relative.
+		req.Path = req.URL.Opaque
+		return resolveLocalPath(req)
+	}
+	return os.ReadFile(req.URL.Path)
+}
+
+func resolveHTTP(req ModuleRequest) ([]byte, error) {
+	return fetchHTTP(req.Path, req.Hash, req.Cache, req.FetchPolicy)
+}
+
+// resolveData resolves RFC 2397 data URLs, for example "data:application/wasm;base64,AGFzbQEAAAA=".
+func resolveData(req ModuleRequest) ([]byte, error) {
+	// The raw path is used as URL parsing will not preserve the content exactly.
+	_, data, found := strings.Cut(req.Path, ":")
+	if found {
+		var mediaType string
+		mediaType, data, found = strings.Cut(data, ",")
+		if found {
+			if strings.HasSuffix(strings.ToLower(mediaType), ";base64") {
+				return base64.StdEncoding.DecodeString(data)
+			}
+			content, err := url.PathUnescape(data)
+			return []byte(content), err
+		}
+	}
+	return nil, fmt.Errorf("invalid data url: %s", req.Path)
+}
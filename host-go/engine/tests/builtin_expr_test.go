@@ -0,0 +1,169 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !js
+
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinExprFromLensFile(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "lens.yaml", `
+lenses:
+  - path: builtin:expr
+    arguments:
+      expression: |
+        {
+          fullName: .first + " " + upper(.last),
+          age: .age + 1,
+          kind: if .age >= 18 then "adult" else "minor" end,
+          city: .address.city // "unknown",
+          tags
+        }
+`)
+
+	source := enumerable.New([]map[string]any{
+		{"first": "John", "last": "Smith", "age": 32, "address": map[string]any{"city": "Oslo"}, "tags": []any{"a"}},
+		{"first": "Fred", "last": "Jones", "age": 15},
+	})
+
+	results, err := config.LoadFromFile[map[string]any, map[string]any](filepath.Join(dir, "lens.yaml"), source)
+	require.NoError(t, err)
+
+	assertResults(t, results, []map[string]any{
+		{"fullName": "John SMITH", "age": float64(33), "kind": "adult", "city": "Oslo", "tags": []any{"a"}},
+		{"fullName": "Fred JONES", "age": float64(16), "kind": "minor", "city": "unknown", "tags": nil},
+	})
+}
+
+func TestBuiltinExprExpressions(t *testing.T) {
+	item := map[string]any{
+		"name":  "John Smith",
+		"age":   32,
+		"tags":  []any{"a", "b", "c"},
+		"score": 7.5,
+	}
+
+	tests := map[string]any{
+		`.score`:                              7.5,
+		`.tags[0]`:                            "a",
+		`.tags[-1]`:                           "c",
+		`.tags[5]`:                            nil,
+		`.missing.field`:                      nil,
+		`.["name"]`:                           "John Smith",
+		`(.age + 3) * 2 - 10 / 5`:             float64(68),
+		`.age % 5`:                            float64(2),
+		`-.age`:                               float64(-32),
+		`lower(.name)`:                        "john smith",
+		`substr(.name, 0, 4)`:                 "John",
+		`split(.name, " ")`:                   []any{"John", "Smith"},
+		`join(.tags, "-")`:                    "a-b-c",
+		`length(.tags) + length(.name)`:       float64(13),
+		`concat(.name, ": ", .age)`:           "John Smith: 32",
+		`replace(.name, "John", "J.")`:        "J. Smith",
+		`startsWith(.name, "John")`:           true,
+		`number("4.5") + round(.score)`:       float64(12.5),
+		`.age > 30 and not (.name == "Fred")`: true,
+		`.age < 30 or has(., "score")`:        true,
+		`.tags + ["d"]`:                       []any{"a", "b", "c", "d"},
+		`{a: 1} + {b: 2}`:                     map[string]any{"a": float64(1), "b": float64(2)},
+		`if .age < 18 then "minor" end`:       nil,
+		`keys({b: 1, a: 2})`:                  []any{"a", "b"},
+		`"A" + string(true)`:                  "Atrue",
+		`.not`:                                nil,
+	}
+
+	for expression, expected := range tests {
+		t.Run(expression, func(t *testing.T) {
+			results, err := config.Load[map[string]any, map[string]any](
+				model.Lens{
+					Lenses: []model.LensModule{
+						{
+							Path:      "builtin:expr",
+							Arguments: map[string]any{"expression": "{result: " + expression + "}"},
+						},
+					},
+				},
+				enumerable.New([]map[string]any{item}),
+			)
+			require.NoError(t, err)
+
+			hasNext, err := results.Next()
+			require.NoError(t, err)
+			require.True(t, hasNext)
+
+			value, err := results.Value()
+			require.NoError(t, err)
+
+			assert.Equal(t, map[string]any{"result": expected}, value)
+		})
+	}
+}
+
+func TestBuiltinExprReturnsErrors(t *testing.T) {
+	problems := config.Validate(model.Lens{
+		Lenses: []model.LensModule{
+			{Path: "builtin:expr", Arguments: map[string]any{"expression": "{a: .b +}"}},
+			{Path: "builtin:expr", Arguments: map[string]any{"expression": "{a: unknown(.b)}"}},
+			{Path: "builtin:expr"},
+		},
+	})
+	require.Len(t, problems, 3)
+	assert.ErrorContains(t, problems[0], `unexpected "}" at position 9`)
+	assert.ErrorContains(t, problems[1], `unknown function "unknown"`)
+	assert.ErrorContains(t, problems[2], "missing argument: expression")
+
+	results, err := config.Load[map[string]any, map[string]any](
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "builtin:expr", Arguments: map[string]any{"expression": "{a: .name - 1}"}},
+			},
+		},
+		enumerable.New([]map[string]any{{"name": "John"}}),
+	)
+	require.NoError(t, err)
+
+	_, err = results.Next()
+	require.ErrorContains(t, err, "cannot apply - to string and number")
+}
+
+func TestBuiltinExprReturnsErrorGivenNonFiniteNumbers(t *testing.T) {
+	tests := map[string]string{
+		`substr(.name, number("NaN"))`:  `cannot convert "NaN" to number`,
+		`number("-Inf")`:                `cannot convert "-Inf" to number`,
+		`substr(.name, 0, 1e308 * 10)`:  "substr expects finite indexes",
+		`substr(.name, -1e308 * 10, 1)`: "substr expects finite indexes",
+	}
+
+	for expression, expected := range tests {
+		t.Run(expression, func(t *testing.T) {
+			results, err := config.Load[map[string]any, map[string]any](
+				model.Lens{
+					Lenses: []model.LensModule{
+						{
+							Path:      "builtin:expr",
+							Arguments: map[string]any{"expression": "{a: " + expression + "}"},
+						},
+					},
+				},
+				enumerable.New([]map[string]any{{"name": "John"}}),
+			)
+			require.NoError(t, err)
+
+			_, err = results.Next()
+			require.ErrorContains(t, err, expected)
+		})
+	}
+}
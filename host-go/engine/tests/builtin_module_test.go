@@ -0,0 +1,277 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !js
+
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinModulesFromLensFile(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "lens.yaml", `
+lenses:
+  - path: builtin:filter-eq
+    arguments:
+      field: type
+      value: user
+  - path: builtin:rename
+    arguments:
+      src: name
+      dst: fullName
+  - path: builtin:copy
+    arguments:
+      src: fullName
+      dst: displayName
+  - path: builtin:set-default
+    arguments:
+      field: age
+      value: 18
+  - path: builtin:drop
+    arguments:
+      field: type
+`)
+
+	source := enumerable.New([]map[string]any{
+		{"type": "user", "name": "John", "age": 32},
+		{"type": "admin", "name": "Fred"},
+		{"type": "user", "name": "Islam"},
+	})
+
+	results, err := config.LoadFromFile[map[string]any, map[string]any](filepath.Join(dir, "lens.yaml"), source)
+	require.NoError(t, err)
+
+	assertResults(t, results, []map[string]any{
+		{"fullName": "John", "displayName": "John", "age": float64(32)},
+		{"fullName": "Islam", "displayName": "Islam", "age": float64(18)},
+	})
+}
+
+func TestBuiltinModulesInverse(t *testing.T) {
+	source := enumerable.New([]map[string]any{{"fullName": "John", "displayName": "John"}})
+
+	results, err := config.Load[map[string]any, map[string]any](
+		model.Lens{
+			Lenses: []model.LensModule{
+				{
+					Path:      "builtin:copy",
+					Arguments: map[string]any{"src": "fullName", "dst": "displayName"},
+					Inverse:   true,
+				},
+				{
+					Path:      "builtin:rename",
+					Arguments: map[string]any{"src": "name", "dst": "fullName"},
+					Inverse:   true,
+				},
+			},
+		},
+		source,
+	)
+	require.NoError(t, err)
+
+	assertResults(t, results, []map[string]any{{"name": "John"}})
+}
+
+func TestBuiltinModuleRenameReturnsErrorGivenMissingField(t *testing.T) {
+	source := enumerable.New([]map[string]any{{"age": 32}})
+
+	results, err := config.Load[map[string]any, map[string]any](
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "builtin:rename", Arguments: map[string]any{"src": "name", "dst": "fullName"}},
+			},
+		},
+		source,
+	)
+	require.NoError(t, err)
+
+	_, err = results.Next()
+	require.ErrorContains(t, err, "property not found: name")
+}
+
+func TestBuiltinModuleCopyReturnsErrorGivenExistingField(t *testing.T) {
+	source := enumerable.New([]map[string]any{{"fullName": "John", "displayName": "Johnny"}})
+
+	results, err := config.Load[map[string]any, map[string]any](
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "builtin:copy", Arguments: map[string]any{"src": "fullName", "dst": "displayName"}},
+			},
+		},
+		source,
+	)
+	require.NoError(t, err)
+
+	_, err = results.Next()
+	require.ErrorContains(t, err, "property already exists: displayName")
+}
+
+func TestValidateBuiltinModules(t *testing.T) {
+	problems := config.Validate(model.Lens{
+		Lenses: []model.LensModule{
+			{Path: "builtin:drop", Arguments: map[string]any{"field": "type"}, Inverse: true},
+			{Path: "builtin:rename", Arguments: map[string]any{"src": "name"}},
+			{Path: "builtin:unknown"},
+		},
+	})
+	require.Len(t, problems, 3)
+
+	assert.ErrorContains(t, problems[0], "builtin:drop has no inverse")
+	assert.ErrorContains(t, problems[1], "missing argument: dst")
+	assert.ErrorContains(t, problems[2], `unknown builtin module "unknown"`)
+}
+
+func TestLoadAttributesErrorsToLensModule(t *testing.T) {
+	source := enumerable.New([]map[string]any{{"name": "John"}})
+
+	results, err := config.Load[map[string]any, map[string]any](
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "builtin:rename", Arguments: map[string]any{"src": "name", "dst": "fullName"}},
+				{Path: "builtin:rename", Arguments: map[string]any{"src": "name", "dst": "firstName"}},
+				{Path: "builtin:drop", Arguments: map[string]any{"field": "age"}},
+			},
+		},
+		source,
+		config.WithErrorTrace(),
+	)
+	require.NoError(t, err)
+
+	_, err = results.Next()
+	var stageErr *config.StageError
+	require.ErrorAs(t, err, &stageErr)
+	assert.Equal(t, 1, stageErr.Index)
+	assert.Equal(t, "builtin:rename", stageErr.Module)
+	assert.ErrorContains(t, stageErr, "property not found: name")
+}
+
+func TestLoadDoesNotAttributeErrorsToLensModuleByDefault(t *testing.T) {
+	source := enumerable.New([]map[string]any{{"name": "John"}})
+
+	results, err := config.Load[map[string]any, map[string]any](
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "builtin:rename", Arguments: map[string]any{"src": "age", "dst": "years"}},
+			},
+		},
+		source,
+	)
+	require.NoError(t, err)
+
+	_, err = results.Next()
+	require.ErrorContains(t, err, "property not found: age")
+	assert.False(t, errors.As(err, new(*config.StageError)))
+}
+
+func TestLoadAttributesLoadErrorsToLensModule(t *testing.T) {
+	_, err := config.Load[map[string]any, map[string]any](
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "builtin:drop", Arguments: map[string]any{"field": "age"}},
+				{Path: "builtin:unknown"},
+			},
+		},
+		enumerable.New([]map[string]any{}),
+	)
+
+	var stageErr *config.StageError
+	require.ErrorAs(t, err, &stageErr)
+	assert.Equal(t, 1, stageErr.Index)
+	assert.Equal(t, "builtin:unknown", stageErr.Module)
+}
+
+func TestLoadWithArgumentOverridesArguments(t *testing.T) {
+	source := enumerable.New([]map[string]any{{"name": "John"}})
+	lensConfig := model.Lens{
+		Lenses: []model.LensModule{
+			{Path: "builtin:rename", Arguments: map[string]any{"src": "name", "dst": "fullName"}},
+		},
+	}
+
+	results, err := config.Load[map[string]any, map[string]any](
+		lensConfig,
+		source,
+		config.WithArgument(0, "dst", "firstName"),
+	)
+	require.NoError(t, err)
+
+	assertResults(t, results, []map[string]any{{"firstName": "John"}})
+	// The given lens should not be modified.
+	assert.Equal(t, "fullName", lensConfig.Lenses[0].Arguments["dst"])
+}
+
+func TestLoadWithArgumentReturnsErrorGivenIndexOutOfRange(t *testing.T) {
+	_, err := config.Load[map[string]any, map[string]any](
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "builtin:drop", Arguments: map[string]any{"field": "age"}},
+			},
+		},
+		enumerable.New([]map[string]any{}),
+		config.WithArgument(2, "field", "name"),
+	)
+	require.ErrorContains(t, err, "cannot override the arguments of lenses[2], the lens has 1 entries")
+}
+
+func TestLoadWithInverseAppliesLensBackwards(t *testing.T) {
+	source := enumerable.New([]map[string]any{{"firstName": "John"}})
+
+	results, err := config.Load[map[string]any, map[string]any](
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "builtin:rename", Arguments: map[string]any{"src": "name", "dst": "fullName"}},
+				{Path: "builtin:rename", Arguments: map[string]any{"src": "fullName", "dst": "firstName"}},
+			},
+		},
+		source,
+		config.WithInverse(),
+	)
+	require.NoError(t, err)
+
+	assertResults(t, results, []map[string]any{{"name": "John"}})
+}
+
+func TestLoadWithInstanceHookWrapsInstances(t *testing.T) {
+	source := enumerable.New([]map[string]any{{"name": "John"}, {"name": "Fred"}})
+
+	transforms := map[string]int{}
+	hook := func(index int, moduleName string, instance module.Instance) module.Instance {
+		key := fmt.Sprintf("%d:%s", index, moduleName)
+		transform := instance.Transform
+		instance.Transform = func(next func() module.MemSize) (module.MemSize, error) {
+			transforms[key]++
+			return transform(next)
+		}
+		return instance
+	}
+
+	results, err := config.Load[map[string]any, map[string]any](
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "builtin:rename", Arguments: map[string]any{"src": "name", "dst": "fullName"}},
+				{Path: "builtin:drop", Arguments: map[string]any{"field": "age"}},
+			},
+		},
+		source,
+		config.WithInstanceHook(hook),
+	)
+	require.NoError(t, err)
+
+	assertResults(t, results, []map[string]any{{"fullName": "John"}, {"fullName": "Fred"}})
+	// Each instance is called once per item, and once more to find the end of the stream.
+	assert.Equal(t, map[string]int{"0:builtin:rename": 3, "1:builtin:drop": 3}, transforms)
+}
@@ -0,0 +1,287 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !js
+
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const jsonPatchLens = `
+lenses:
+  - path: builtin:json-patch
+    arguments:
+      patch:
+        - {op: test, path: /kind, value: user}
+        - {op: remove, path: /kind}
+        - {op: add, path: /tags/0, value: new}
+        - {op: move, from: /name, path: /fullName}
+        - {op: copy, from: /fullName, path: /address/owner}
+        - {op: test, path: /age, value: 32}
+        - {op: replace, path: /age, value: 33}
+      absent: [/fullName, /address/owner]
+`
+
+func TestBuiltinJsonPatch(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "lens.yaml", jsonPatchLens)
+
+	source := enumerable.New([]map[string]any{
+		{"kind": "user", "name": "John", "age": 32, "tags": []any{"a"}, "address": map[string]any{}},
+	})
+
+	results, err := config.LoadFromFile[map[string]any, map[string]any](filepath.Join(dir, "lens.yaml"), source)
+	require.NoError(t, err)
+
+	assertResults(t, results, []map[string]any{
+		{
+			"fullName": "John",
+			"age":      float64(33),
+			"tags":     []any{"new", "a"},
+			"address":  map[string]any{"owner": "John"},
+		},
+	})
+}
+
+func TestBuiltinJsonPatchInverse(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "lens.yaml", jsonPatchLens)
+	writeLensFile(t, dir, "inverse.yaml", `
+lenses:
+  - include: lens.yaml
+    inverse: true
+`)
+
+	source := enumerable.New([]map[string]any{
+		{
+			"fullName": "John",
+			"age":      33,
+			"tags":     []any{"new", "a"},
+			"address":  map[string]any{"owner": "John"},
+		},
+	})
+
+	results, err := config.LoadFromFile[map[string]any, map[string]any](filepath.Join(dir, "inverse.yaml"), source)
+	require.NoError(t, err)
+
+	assertResults(t, results, []map[string]any{
+		{"kind": "user", "name": "John", "age": float64(32), "tags": []any{"a"}, "address": map[string]any{}},
+	})
+}
+
+func TestBuiltinJsonPatchReturnsErrorGivenFailedTest(t *testing.T) {
+	results, err := config.Load[map[string]any, map[string]any](
+		model.Lens{
+			Lenses: []model.LensModule{
+				{
+					Path: "builtin:json-patch",
+					Arguments: map[string]any{
+						"patch": []any{map[string]any{"op": "test", "path": "/kind", "value": "user"}},
+					},
+				},
+			},
+		},
+		enumerable.New([]map[string]any{{"kind": "admin"}}),
+	)
+	require.NoError(t, err)
+
+	_, err = results.Next()
+	require.ErrorContains(t, err, "test failed: /kind")
+}
+
+func TestBuiltinJsonPatchInverseRestoresOverwrittenMembers(t *testing.T) {
+	tests := map[string]struct {
+		op       map[string]any
+		original map[string]any
+		patched  map[string]any
+	}{
+		"move": {
+			op:       map[string]any{"op": "move", "from": "/a", "path": "/b"},
+			original: map[string]any{"a": float64(1), "b": float64(2)},
+			patched:  map[string]any{"b": float64(1)},
+		},
+		"copy": {
+			op:       map[string]any{"op": "copy", "from": "/a", "path": "/b"},
+			original: map[string]any{"a": float64(1), "b": float64(2)},
+			patched:  map[string]any{"a": float64(1), "b": float64(1)},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			lens := func(inverse bool) model.Lens {
+				return model.Lens{
+					Lenses: []model.LensModule{
+						{
+							Path: "builtin:json-patch",
+							Arguments: map[string]any{
+								"patch": []any{
+									map[string]any{"op": "test", "path": "/b", "value": 2},
+									test.op,
+								},
+							},
+							Inverse: inverse,
+						},
+					},
+				}
+			}
+
+			results, err := config.Load[map[string]any, map[string]any](
+				lens(false),
+				enumerable.New([]map[string]any{test.original}),
+			)
+			require.NoError(t, err)
+			assertResults(t, results, []map[string]any{test.patched})
+
+			results, err = config.Load[map[string]any, map[string]any](
+				lens(true),
+				enumerable.New([]map[string]any{test.patched}),
+			)
+			require.NoError(t, err)
+			assertResults(t, results, []map[string]any{test.original})
+		})
+	}
+}
+
+func TestBuiltinMergePatch(t *testing.T) {
+	lens := func(inverse bool) model.Lens {
+		return model.Lens{
+			Lenses: []model.LensModule{
+				{
+					Path: "builtin:merge-patch",
+					Arguments: map[string]any{
+						"patch":    map[string]any{"kind": nil, "address": map[string]any{"city": "Oslo"}, "active": true},
+						"previous": map[string]any{"kind": "user", "address": map[string]any{"city": "Bergen"}},
+						"absent":   []any{"/active"},
+					},
+					Inverse: inverse,
+				},
+			},
+		}
+	}
+	original := map[string]any{"kind": "user", "name": "John", "address": map[string]any{"city": "Bergen", "zip": "5003"}}
+	patched := map[string]any{"name": "John", "active": true, "address": map[string]any{"city": "Oslo", "zip": "5003"}}
+
+	results, err := config.Load[map[string]any, map[string]any](lens(false), enumerable.New([]map[string]any{original}))
+	require.NoError(t, err)
+	assertResults(t, results, []map[string]any{patched})
+
+	results, err = config.Load[map[string]any, map[string]any](lens(true), enumerable.New([]map[string]any{patched}))
+	require.NoError(t, err)
+	assertResults(t, results, []map[string]any{original})
+}
+
+func TestValidateReturnsProblemsGivenNonInvertiblePatches(t *testing.T) {
+	problems := config.Validate(model.Lens{
+		Lenses: []model.LensModule{
+			{
+				Path: "builtin:json-patch",
+				Arguments: map[string]any{
+					"patch": []any{map[string]any{"op": "remove", "path": "/kind"}},
+				},
+				Inverse: true,
+			},
+			{
+				Path: "builtin:merge-patch",
+				Arguments: map[string]any{
+					"patch": map[string]any{"kind": nil},
+				},
+				Inverse: true,
+			},
+			{
+				Path: "builtin:json-patch",
+				Arguments: map[string]any{
+					"patch": []any{map[string]any{"op": "fly", "path": "/kind"}},
+				},
+			},
+			{
+				Path: "builtin:json-patch",
+				Arguments: map[string]any{
+					"patch": []any{map[string]any{"op": "move", "from": "/name", "path": "/tags/-"}},
+				},
+				Inverse: true,
+			},
+			{
+				Path: "builtin:merge-patch",
+				Arguments: map[string]any{
+					"patch":    map[string]any{"address": "unknown"},
+					"previous": map[string]any{"address": map[string]any{"city": "Oslo", "zip": nil}},
+				},
+				Inverse: true,
+			},
+			{
+				Path: "builtin:json-patch",
+				Arguments: map[string]any{
+					"patch": []any{map[string]any{"op": "copy", "from": "/name", "path": "/tags/-"}},
+				},
+				Inverse: true,
+			},
+			{
+				Path: "builtin:json-patch",
+				Arguments: map[string]any{
+					"patch": []any{map[string]any{"op": "add", "path": "/kind", "value": "user"}},
+				},
+				Inverse: true,
+			},
+			{
+				Path: "builtin:merge-patch",
+				Arguments: map[string]any{
+					"patch":    map[string]any{"kind": "user", "active": true},
+					"previous": map[string]any{"kind": "admin"},
+				},
+				Inverse: true,
+			},
+		},
+	})
+	require.Len(t, problems, 8)
+
+	assert.ErrorContains(t, problems[0], "the value at /kind is unknown, precede the remove with a test")
+	assert.ErrorContains(t, problems[1], "the value at /kind is unknown, declare it in previous")
+	assert.ErrorContains(t, problems[2], `unknown op "fly"`)
+	assert.ErrorContains(t, problems[3], "patch is not invertible: cannot invert move to the end of array /tags/-")
+	assert.ErrorContains(t, problems[4], "merge patch is not invertible: the null value at /address/zip cannot be restored")
+	assert.ErrorContains(t, problems[5], "patch is not invertible: cannot invert copy to the end of array /tags/-")
+	assert.ErrorContains(t, problems[6], "the value at /kind is unknown, precede the add with a test, or declare it absent")
+	assert.ErrorContains(t, problems[7], "the value at /active is unknown, declare it in previous or absent")
+}
+
+func TestBuiltinJsonPatchInverseRestoresValuesRemovedEarlierInThePatch(t *testing.T) {
+	lens := func(inverse bool) model.Lens {
+		return model.Lens{
+			Lenses: []model.LensModule{
+				{
+					Path: "builtin:json-patch",
+					Arguments: map[string]any{
+						"patch": []any{
+							map[string]any{"op": "test", "path": "/kind", "value": "user"},
+							map[string]any{"op": "remove", "path": "/kind"},
+							map[string]any{"op": "add", "path": "/kind", "value": "admin"},
+						},
+					},
+					Inverse: inverse,
+				},
+			},
+		}
+	}
+	original := map[string]any{"kind": "user"}
+	patched := map[string]any{"kind": "admin"}
+
+	results, err := config.Load[map[string]any, map[string]any](lens(false), enumerable.New([]map[string]any{original}))
+	require.NoError(t, err)
+	assertResults(t, results, []map[string]any{patched})
+
+	results, err = config.Load[map[string]any, map[string]any](lens(true), enumerable.New([]map[string]any{patched}))
+	require.NoError(t, err)
+	assertResults(t, results, []map[string]any{original})
+}
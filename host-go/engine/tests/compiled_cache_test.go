@@ -0,0 +1,94 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !js
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lens-vm/lens/host-go/runtimes/wasmtime"
+	"github.com/lens-vm/lens/host-go/runtimes/wazero"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWasmtimeWithCacheDirPersistsCompiledModule(t *testing.T) {
+	dir := t.TempDir()
+
+	assertIdentityWat(t, wasmtime.New(wasmtime.WithCacheDir(dir)))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	artifactPath := filepath.Join(dir, entries[0].Name())
+
+	artifact, err := os.ReadFile(artifactPath)
+	require.NoError(t, err)
+	// Artifacts are only rewritten if they cannot be deserialized, so an unchanged modification
+	// time shows that the artifact has been used rather than the module recompiled.
+	modTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = os.Chtimes(artifactPath, modTime, modTime)
+	require.NoError(t, err)
+
+	// A new runtime, as if in a new process, should load the module from the cache.
+	assertIdentityWat(t, wasmtime.New(wasmtime.WithCacheDir(dir)))
+
+	info, err := os.Stat(artifactPath)
+	require.NoError(t, err)
+	assert.True(t, modTime.Equal(info.ModTime()), "the artifact has been rewritten")
+
+	cached, err := os.ReadFile(artifactPath)
+	require.NoError(t, err)
+	assert.Equal(t, artifact, cached)
+}
+
+func TestWasmtimeWithCacheDirRecompilesCorruptModule(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := wasmtime.New(wasmtime.WithCacheDir(dir)).NewModule(emptyWasmModule)
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	artifactPath := filepath.Join(dir, entries[0].Name())
+
+	err = os.WriteFile(artifactPath, []byte("corrupt"), 0600)
+	require.NoError(t, err)
+
+	_, err = wasmtime.New(wasmtime.WithCacheDir(dir)).NewModule(emptyWasmModule)
+	require.NoError(t, err)
+
+	artifact, err := os.ReadFile(artifactPath)
+	require.NoError(t, err)
+	assert.NotEqual(t, []byte("corrupt"), artifact)
+}
+
+func TestWazeroWithCacheDirPersistsCompiledModule(t *testing.T) {
+	dir := t.TempDir()
+
+	wasmModule, err := wazero.New(wazero.WithCacheDir(dir)).NewModule(emptyWasmModule)
+	require.NoError(t, err)
+
+	// Wazero compiles upon instantiation, this will fail as the module is not a lens, but only
+	// after it has been compiled.
+	_, err = wasmModule.NewInstance("transform")
+	require.Error(t, err)
+
+	fileCount := 0
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			fileCount++
+		}
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fileCount)
+}
@@ -0,0 +1,218 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/lens-vm/lens/host-go/engine"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/engine/pipes"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newHostInstance returns a Go hosted instance that applies the given function to each item,
+// dropping the item if the function returns nil.
+func newHostInstance(t *testing.T, transform func(item map[string]any) map[string]any) module.Instance {
+	memory := module.NewHostMemory()
+
+	write := func(id module.TypeIdType, data []byte) module.MemSize {
+		var buf bytes.Buffer
+		require.NoError(t, pipes.WriteItem(&buf, id, data))
+		index, err := memory.Alloc(module.MemSize(buf.Len()))
+		require.NoError(t, err)
+		_, err = memory.WriteAt(buf.Bytes(), int64(index))
+		require.NoError(t, err)
+		return index
+	}
+
+	return module.Instance{
+		Alloc: memory.Alloc,
+		Transform: func(next func() module.MemSize) (module.MemSize, error) {
+			for {
+				r := io.NewSectionReader(memory, int64(next()), math.MaxInt64)
+				id, err := pipes.ReadTypeId(r)
+				require.NoError(t, err)
+				if id.IsEOS() {
+					return write(module.EOSTypeID, nil), nil
+				}
+				_, err = r.Seek(0, io.SeekStart)
+				require.NoError(t, err)
+				id, data, err := pipes.ReadItem(r)
+				require.NoError(t, err)
+				require.Equal(t, module.JSONTypeID, id)
+
+				var item map[string]any
+				require.NoError(t, json.Unmarshal(data, &item))
+				result := transform(item)
+				if result == nil {
+					continue
+				}
+				data, err = json.Marshal(result)
+				require.NoError(t, err)
+				return write(module.JSONTypeID, data), nil
+			}
+		},
+		Memory: func() module.Memory {
+			return memory
+		},
+	}
+}
+
+func isUser(item any) (bool, error) {
+	return item.(map[string]any)["__type"] == "user", nil
+}
+
+func TestConditionalTransformsOnlyMatchingItems(t *testing.T) {
+	source := enumerable.New([]map[string]any{
+		{"__type": "user", "name": "John"},
+		{"__type": "post", "name": "Hello"},
+		{"__type": "post", "name": "World"},
+		{"__type": "user", "name": "Fred"},
+		{"__type": "post", "name": "Bye"},
+	})
+
+	upper := newHostInstance(t, func(item map[string]any) map[string]any {
+		item["name"] = item["name"].(string) + "!"
+		return item
+	})
+
+	results := engine.Append[map[string]any, map[string]any](source, engine.NewConditional(upper, isUser))
+
+	assertResults(t, results, []map[string]any{
+		{"__type": "user", "name": "John!"},
+		{"__type": "post", "name": "Hello"},
+		{"__type": "post", "name": "World"},
+		{"__type": "user", "name": "Fred!"},
+		{"__type": "post", "name": "Bye"},
+	})
+}
+
+func TestConditionalKeepsOrderGivenFilteringInstance(t *testing.T) {
+	source := enumerable.New([]map[string]any{
+		{"__type": "user", "name": "John"},
+		{"__type": "post", "name": "Hello"},
+		{"__type": "user", "name": "Fred"},
+		{"__type": "post", "name": "World"},
+		{"__type": "user", "name": "Shahzad"},
+	})
+
+	// Drops users called Fred, so the wrapped instance will pull past bypassed items.
+	filter := newHostInstance(t, func(item map[string]any) map[string]any {
+		if item["name"] == "Fred" {
+			return nil
+		}
+		return item
+	})
+
+	results := engine.Append[map[string]any, map[string]any](
+		source,
+		engine.NewConditional(filter, isUser),
+		// A second stage to assert that conditional instances may be chained.
+		engine.NewConditional(newHostInstance(t, func(item map[string]any) map[string]any {
+			item["seen"] = true
+			return item
+		}), isUser),
+	)
+
+	assertResults(t, results, []map[string]any{
+		{"__type": "user", "name": "John", "seen": true},
+		{"__type": "post", "name": "Hello"},
+		{"__type": "post", "name": "World"},
+		{"__type": "user", "name": "Shahzad", "seen": true},
+	})
+}
+
+func TestNewPredicate(t *testing.T) {
+	yes, no := true, false
+	item := map[string]any{
+		"__type": "user",
+		"age":    float64(32),
+		"address": map[string]any{
+			"city": "London",
+		},
+		"tags": []any{"admin"},
+	}
+
+	testCases := []struct {
+		name      string
+		condition model.Condition
+		expected  bool
+	}{
+		{"empty", model.Condition{}, true},
+		{"equals", model.Condition{Field: "__type", Equals: "user"}, true},
+		{"equals mismatch", model.Condition{Field: "__type", Equals: "post"}, false},
+		{"equals integer", model.Condition{Field: "age", Equals: 32}, true},
+		{"equals nested", model.Condition{Field: "address.city", Equals: "London"}, true},
+		{"equals array item", model.Condition{Field: "tags.0", Equals: "admin"}, true},
+		{"field exists", model.Condition{Field: "address"}, true},
+		{"field missing", model.Condition{Field: "address.street"}, false},
+		{"exists false", model.Condition{Field: "deleted", Exists: &no}, true},
+		{"exists true", model.Condition{Field: "deleted", Exists: &yes}, false},
+		{
+			"all",
+			model.Condition{All: []model.Condition{{Field: "__type", Equals: "user"}, {Field: "age", Equals: 31}}},
+			false,
+		},
+		{
+			"any",
+			model.Condition{Any: []model.Condition{{Field: "__type", Equals: "post"}, {Field: "age", Equals: 32}}},
+			true,
+		},
+		{"not", model.Condition{Not: &model.Condition{Field: "__type", Equals: "user"}}, false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			predicate, err := config.NewPredicate(testCase.condition)
+			require.NoError(t, err)
+
+			matches, err := predicate(item)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, matches)
+		})
+	}
+}
+
+func TestLoadFileWithWhenCondition(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "lens.yaml", `
+lenses:
+  - path: rename.wasm
+    # Only users have a legacy name field.
+    when:
+      any:
+        - field: __type
+          equals: user
+        - not:
+            field: legacy
+            exists: false
+`)
+
+	lens, err := config.LoadFile(filepath.Join(dir, "lens.yaml"), config.WithStrict())
+	require.NoError(t, err)
+
+	exists := false
+	assert.Equal(
+		t,
+		&model.Condition{
+			Any: []model.Condition{
+				{Field: "__type", Equals: "user"},
+				{Not: &model.Condition{Field: "legacy", Exists: &exists}},
+			},
+		},
+		lens.Lenses[0].When,
+	)
+}
@@ -0,0 +1,161 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var expectedLensFile = model.Lens{
+	Lenses: []model.LensModule{
+		{
+			Path: "rename.wasm",
+			Arguments: map[string]any{
+				"src": "Name",
+				"dst": "FullName",
+			},
+		},
+		{
+			Path:    "add.wasm",
+			Inverse: true,
+		},
+	},
+}
+
+const jsonLensFile = `{
+	"lenses": [
+		{"path": "rename.wasm", "arguments": {"src": "Name", "dst": "FullName"}},
+		{"path": "add.wasm", "inverse": true}
+	]
+}`
+
+const yamlLensFile = `# Renames the legacy field before the schema v2 migration.
+lenses:
+  - path: rename.wasm
+    arguments:
+      src: Name
+      dst: FullName
+  # Undo the age offset applied by v1 clients.
+  - path: add.wasm
+    inverse: true
+`
+
+const tomlLensFile = `# Renames the legacy field before the schema v2 migration.
+[[lenses]]
+path = "rename.wasm"
+arguments = { src = "Name", dst = "FullName" }
+
+[[lenses]]
+path = "add.wasm"
+inverse = true
+`
+
+func TestLoadFileJSON(t *testing.T) {
+	assertLensFile(t, "lens.json", jsonLensFile)
+}
+
+func TestLoadFileYAML(t *testing.T) {
+	assertLensFile(t, "lens.yaml", yamlLensFile)
+	assertLensFile(t, "lens.yml", yamlLensFile)
+}
+
+func TestLoadFileTOML(t *testing.T) {
+	assertLensFile(t, "lens.toml", tomlLensFile)
+}
+
+func TestLoadFileDetectsFormatWithoutExtension(t *testing.T) {
+	assertLensFile(t, "json.lens", jsonLensFile)
+	assertLensFile(t, "yaml.lens", yamlLensFile)
+	assertLensFile(t, "toml.lens", tomlLensFile)
+}
+
+func TestDetectFormat(t *testing.T) {
+	assert.Equal(t, config.FormatJSON, config.DetectFormat([]byte("\xef\xbb\xbf\n  "+jsonLensFile)))
+	assert.Equal(t, config.FormatYAML, config.DetectFormat([]byte("---\n"+yamlLensFile)))
+	assert.Equal(t, config.FormatTOML, config.DetectFormat([]byte(tomlLensFile)))
+	assert.Equal(t, config.FormatTOML, config.DetectFormat([]byte(`lenses = []`)))
+}
+
+func TestLoadFileReturnsErrorGivenInvalidContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lens.yaml")
+	err := os.WriteFile(path, []byte("lenses: [\n"), 0600)
+	require.NoError(t, err)
+
+	_, err = config.LoadFile(path)
+	require.ErrorContains(t, err, path)
+}
+
+func TestMarshalRoundTrips(t *testing.T) {
+	exists := false
+	lens := model.Lens{
+		Version: 1,
+		Modules: map[string]model.ModuleDeclaration{
+			"rename": {Path: "rename.wasm", Hash: "sha256:0123"},
+		},
+		Lenses: []model.LensModule{
+			{Module: "rename", Arguments: map[string]any{"src": "name", "dst": "fullName"}},
+			{Include: "common.yaml", Inverse: true},
+			{
+				Wat:     "(module)",
+				Runtime: "wazero",
+				When: &model.Condition{
+					Any: []model.Condition{
+						{Field: "kind", Equals: "user"},
+						{Not: &model.Condition{Field: "age", Exists: &exists}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, format := range []config.Format{config.FormatJSON, config.FormatYAML, config.FormatTOML} {
+		t.Run(string(format), func(t *testing.T) {
+			content, err := config.Marshal(lens, format)
+			require.NoError(t, err)
+
+			parsed, err := config.Parse(content, format, config.WithStrict())
+			require.NoError(t, err)
+			assert.Equal(t, lens, parsed)
+		})
+	}
+}
+
+func TestInverse(t *testing.T) {
+	lens := config.Inverse(model.Lens{
+		Lenses: []model.LensModule{
+			{Path: "rename.wasm"},
+			{Include: "common.yaml", Inverse: true},
+		},
+	})
+
+	assert.Equal(
+		t,
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Include: "common.yaml"},
+				{Path: "rename.wasm", Inverse: true},
+			},
+		},
+		lens,
+	)
+}
+
+func assertLensFile(t *testing.T, name string, content string) {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0600)
+	require.NoError(t, err)
+
+	lens, err := config.LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expectedLensFile, lens)
+}
@@ -0,0 +1,252 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlattenSplicesIncludedLenses(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "common/rename.yaml", `
+lenses:
+  - path: rename.wasm
+  - include: add.json
+`)
+	writeLensFile(t, dir, "common/add.json", `{"lenses": [{"path": "add.wasm"}]}`)
+
+	lens, err := config.Flatten(
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "first.wasm"},
+				{Include: "common/rename.yaml"},
+				{Path: "last.wasm"},
+			},
+		},
+		dir,
+	)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "first.wasm"},
+				{Path: "rename.wasm"},
+				{Path: "add.wasm"},
+				{Path: "last.wasm"},
+			},
+		},
+		lens,
+	)
+}
+
+func TestFlattenInversesIncludedLens(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "common.json", `{
+		"lenses": [
+			{"path": "rename.wasm"},
+			{"path": "add.wasm", "inverse": true}
+		]
+	}`)
+
+	lens, err := config.Flatten(
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Include: "common.json", Inverse: true},
+			},
+		},
+		dir,
+	)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "add.wasm"},
+				{Path: "rename.wasm", Inverse: true},
+			},
+		},
+		lens,
+	)
+}
+
+func TestLoadFromFileReturnsErrorGivenIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "a.json", `{"lenses": [{"include": "nested/b.json"}]}`)
+	writeLensFile(t, dir, "nested/b.json", `{"lenses": [{"include": "../a.json"}]}`)
+
+	_, err := config.LoadFromFile[type1, type2](filepath.Join(dir, "a.json"), nil)
+	require.ErrorContains(t, err, "lens file include cycle")
+}
+
+func TestFlattenReturnsErrorGivenIncludeAndPath(t *testing.T) {
+	_, err := config.Flatten(
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Include: "common.json", Path: "add.wasm"},
+			},
+		},
+		"",
+	)
+	require.ErrorContains(t, err, "may not declare both an include")
+}
+
+func TestFlattenResolvesNamedModules(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "common.yaml", `
+modules:
+  rename:
+    path: common/rename.wasm
+lenses:
+  - module: rename
+`)
+	writeLensFile(t, dir, "lens.yaml", `
+modules:
+  rename:
+    path: https://example.com/rename.wasm
+    hash: sha256:0123
+lenses:
+  - module: rename
+    arguments:
+      src: Name
+  - include: common.yaml
+  - module: rename
+    arguments:
+      src: Age
+`)
+
+	lens, err := config.LoadFile(filepath.Join(dir, "lens.yaml"), config.WithStrict())
+	require.NoError(t, err)
+
+	lens, err = config.Flatten(lens, dir)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		model.Lens{
+			Lenses: []model.LensModule{
+				{
+					Module:    "rename",
+					Path:      "https://example.com/rename.wasm",
+					Hash:      "sha256:0123",
+					Arguments: map[string]any{"src": "Name"},
+				},
+				{
+					Module: "rename",
+					Path:   "common/rename.wasm",
+				},
+				{
+					Module:    "rename",
+					Path:      "https://example.com/rename.wasm",
+					Hash:      "sha256:0123",
+					Arguments: map[string]any{"src": "Age"},
+				},
+			},
+		},
+		lens,
+	)
+}
+
+func TestFlattenReturnsErrorGivenUndeclaredModule(t *testing.T) {
+	_, err := config.Flatten(
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Module: "rename"},
+			},
+		},
+		"",
+	)
+	require.ErrorContains(t, err, "module rename has not been declared")
+}
+
+func TestFlattenReturnsErrorGivenModuleAndPath(t *testing.T) {
+	_, err := config.Flatten(
+		model.Lens{
+			Modules: map[string]model.ModuleDeclaration{
+				"rename": {Path: "rename.wasm"},
+			},
+			Lenses: []model.LensModule{
+				{Module: "rename", Path: "rename.wasm"},
+			},
+		},
+		"",
+	)
+	require.ErrorContains(t, err, "may not also declare a path")
+}
+
+func TestLoadIntoFromFileResolvesModulePathsRelativeToLensFile(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "lens.yaml", `
+lenses:
+  - path: rename.wasm
+  - path: file:modules/add.wasm
+  - include: common/lens.yaml
+`)
+	writeLensFile(t, dir, "common/lens.yaml", `
+lenses:
+  - path: rename.wasm
+`)
+	for _, name := range []string{"rename.wasm", "modules/add.wasm", "common/rename.wasm"} {
+		writeLensFile(t, dir, name, string(emptyWasmModule))
+	}
+
+	modulesByPath := map[string]module.Module{}
+	_, err := config.LoadIntoFromFile[type1, type1](
+		&recordingRuntime{},
+		modulesByPath,
+		filepath.Join(dir, "lens.yaml"),
+		enumerable.New([]type1{}),
+	)
+	require.NoError(t, err)
+
+	// The two rename modules are different files, so must not have been de-duplicated.
+	assert.Len(t, modulesByPath, 3)
+}
+
+func TestLoadIntoFromFileResolvesInterpolatedModulePathsRelativeToLensFile(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "lens.yaml", `
+lenses:
+  - path: ${dir}/add.wasm
+  - path: $${dir}.wasm
+`)
+	for _, name := range []string{"modules/add.wasm", "${dir}.wasm"} {
+		writeLensFile(t, dir, name, string(emptyWasmModule))
+	}
+
+	modulesByPath := map[string]module.Module{}
+	_, err := config.LoadIntoFromFile[type1, type1](
+		&recordingRuntime{},
+		modulesByPath,
+		filepath.Join(dir, "lens.yaml"),
+		enumerable.New([]type1{}),
+		config.WithVariables(map[string]string{"dir": "modules"}),
+	)
+	require.NoError(t, err)
+
+	assert.Contains(t, modulesByPath, filepath.Join(dir, "modules", "add.wasm"))
+	assert.Contains(t, modulesByPath, filepath.Join(dir, "${dir}.wasm"))
+}
+
+func writeLensFile(t *testing.T, dir string, name string, content string) {
+	path := filepath.Join(dir, name)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	require.NoError(t, err)
+
+	err = os.WriteFile(path, []byte(content), 0600)
+	require.NoError(t, err)
+}
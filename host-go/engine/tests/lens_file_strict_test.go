@@ -0,0 +1,135 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tests
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFileStrictReturnsErrorGivenUnknownFieldJSON(t *testing.T) {
+	parseErr := loadFileParseError(t, "lens.json", `{
+	"version": 1,
+	"lenses": [
+		{"path": "rename.wasm"},
+		{
+			"path": "add.wasm",
+			"arguements": {"Age": 1}
+		}
+	]
+}`, config.WithStrict())
+
+	assert.Equal(t, 7, parseErr.Line)
+	assert.Equal(t, 4, parseErr.Column)
+	assert.Equal(t, "/lenses/1/arguements", parseErr.Pointer)
+	assert.ErrorContains(t, parseErr, `lens.json:7:4: /lenses/1/arguements: unknown field "arguements"`)
+}
+
+func TestLoadFileStrictReturnsErrorGivenUnknownFieldYAML(t *testing.T) {
+	parseErr := loadFileParseError(t, "lens.yaml", `
+version: 1
+lenses:
+  - path: rename.wasm
+  - path: add.wasm
+    arguements:
+      Age: 1
+`, config.WithStrict())
+
+	assert.Equal(t, 6, parseErr.Line)
+	assert.Equal(t, 5, parseErr.Column)
+	assert.Equal(t, "/lenses/1/arguements", parseErr.Pointer)
+}
+
+func TestLoadFileStrictReturnsErrorGivenUnknownFieldTOML(t *testing.T) {
+	parseErr := loadFileParseError(t, "lens.toml", `
+version = 1
+
+[[lenses]]
+path = "rename.wasm"
+
+[[lenses]]
+path = "add.wasm"
+arguements = { Age = 1 }
+`, config.WithStrict())
+
+	assert.Equal(t, 9, parseErr.Line)
+	assert.Equal(t, 1, parseErr.Column)
+	assert.Equal(t, "/lenses/1/arguements", parseErr.Pointer)
+}
+
+func TestLoadFileIgnoresUnknownFieldsByDefault(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "lens.json", `{"lenses": [{"path": "add.wasm", "arguements": {}}]}`)
+
+	lens, err := config.LoadFile(filepath.Join(dir, "lens.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "add.wasm", lens.Lenses[0].Path)
+}
+
+func TestLoadFileMatchesFieldsCaseInsensitivelyByDefault(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "lens.json", `{"Lenses": [{"Path": "add.wasm", "Inverse": true, "ARGUMENTS": {"Age": 1}}]}`)
+
+	lens, err := config.LoadFile(filepath.Join(dir, "lens.json"))
+	require.NoError(t, err)
+	require.Len(t, lens.Lenses, 1)
+	assert.Equal(t, "add.wasm", lens.Lenses[0].Path)
+	assert.True(t, lens.Lenses[0].Inverse)
+	assert.Equal(t, map[string]any{"Age": float64(1)}, lens.Lenses[0].Arguments)
+}
+
+func TestLoadFileStrictReturnsErrorGivenFieldOfWrongCase(t *testing.T) {
+	parseErr := loadFileParseError(t, "lens.json", `{"lenses": [{"Path": "add.wasm"}]}`, config.WithStrict())
+
+	assert.Equal(t, "/lenses/0/Path", parseErr.Pointer)
+	assert.ErrorContains(t, parseErr, `unknown field "Path", did you mean "path"`)
+}
+
+func TestLoadFileReturnsErrorGivenUnsupportedVersion(t *testing.T) {
+	parseErr := loadFileParseError(t, "lens.json", `{"version": 2, "lenses": []}`)
+
+	assert.Equal(t, 1, parseErr.Line)
+	assert.Equal(t, 2, parseErr.Column)
+	assert.Equal(t, "/version", parseErr.Pointer)
+	assert.ErrorContains(t, parseErr, "unsupported lens file version 2")
+}
+
+func TestLoadFileReturnsErrorGivenInvalidFieldType(t *testing.T) {
+	parseErr := loadFileParseError(t, "lens.yaml", `
+lenses:
+  - path: add.wasm
+    inverse: sometimes
+`)
+
+	assert.Equal(t, 4, parseErr.Line)
+	assert.Equal(t, "/lenses/0/inverse", parseErr.Pointer)
+	assert.ErrorContains(t, parseErr, `expected a boolean, got "sometimes"`)
+}
+
+func TestLoadFileReturnsErrorGivenJSONSyntaxError(t *testing.T) {
+	parseErr := loadFileParseError(t, "lens.json", "{\n\t\"lenses\": [,]\n}")
+
+	assert.Equal(t, 2, parseErr.Line)
+	assert.Equal(t, "", parseErr.Pointer)
+}
+
+func loadFileParseError(t *testing.T, name string, content string, opts ...config.Option) *config.ParseError {
+	dir := t.TempDir()
+	writeLensFile(t, dir, name, content)
+
+	_, err := config.LoadFile(filepath.Join(dir, name), opts...)
+	require.Error(t, err)
+
+	var parseErr *config.ParseError
+	require.True(t, errors.As(err, &parseErr), err.Error())
+	assert.Equal(t, filepath.Join(dir, name), parseErr.Path)
+	return parseErr
+}
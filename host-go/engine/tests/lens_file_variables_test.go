@@ -0,0 +1,130 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/lens-vm/lens/host-go/engine"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingRuntime is a runtime that creates no-op modules, recording the paths and parameters
+// with which they were created.
+type recordingRuntime struct {
+	paths  []string
+	params []map[string]any
+}
+
+var _ module.Runtime = (*recordingRuntime)(nil)
+
+func (rt *recordingRuntime) NewModule(wasmBytes []byte) (module.Module, error) {
+	return rt, nil
+}
+
+func (rt *recordingRuntime) NewInstance(functionName string, paramSets ...map[string]any) (module.Instance, error) {
+	rt.params = append(rt.params, paramSets...)
+	return module.Instance{}, nil
+}
+
+func (rt *recordingRuntime) resolvers() *engine.ModuleResolvers {
+	resolvers := engine.NewModuleResolvers()
+	resolvers.Register("test", engine.ModuleResolverFunc(func(req engine.ModuleRequest) ([]byte, error) {
+		rt.paths = append(rt.paths, req.Path)
+		return emptyWasmModule, nil
+	}))
+	return resolvers
+}
+
+func TestLoadIntoInterpolatesVariables(t *testing.T) {
+	t.Setenv("LENS_TEST_FIELD", "Name")
+
+	runtime := &recordingRuntime{}
+	lensConfig := model.Lens{
+		Lenses: []model.LensModule{
+			{
+				Path: "test://${env}/rename.wasm",
+				Arguments: map[string]any{
+					"src":     "${LENS_TEST_FIELD}",
+					"dst":     "${prefix}${LENS_TEST_FIELD}",
+					"literal": "$${prefix}",
+					"nested":  []any{"${env}", 1},
+				},
+			},
+		},
+	}
+
+	_, err := config.LoadInto[type1, type2](
+		runtime,
+		map[string]module.Module{},
+		lensConfig,
+		enumerable.New([]type1{}),
+		config.WithModuleResolvers(runtime.resolvers()),
+		config.WithVariables(map[string]string{"env": "staging", "prefix": "Full"}),
+		config.WithEnvVariables(),
+		config.WithStrictVariables(),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"test://staging/rename.wasm"}, runtime.paths)
+	assert.Equal(
+		t,
+		[]map[string]any{
+			{
+				"src":     "Name",
+				"dst":     "FullName",
+				"literal": "${prefix}",
+				"nested":  []any{"staging", 1},
+			},
+		},
+		runtime.params,
+	)
+	// The given config should not have been modified.
+	assert.Equal(t, "${LENS_TEST_FIELD}", lensConfig.Lenses[0].Arguments["src"])
+}
+
+func TestLoadIntoLeavesUndefinedVariables(t *testing.T) {
+	runtime := &recordingRuntime{}
+	_, err := config.LoadInto[type1, type2](
+		runtime,
+		map[string]module.Module{},
+		model.Lens{
+			Lenses: []model.LensModule{
+				{
+					Path:      "test://rename.wasm",
+					Arguments: map[string]any{"src": "${LENS_TEST_UNDEFINED}"},
+				},
+			},
+		},
+		enumerable.New([]type1{}),
+		config.WithModuleResolvers(runtime.resolvers()),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []map[string]any{{"src": "${LENS_TEST_UNDEFINED}"}}, runtime.params)
+}
+
+func TestLoadIntoReturnsErrorGivenUndefinedVariableInStrictMode(t *testing.T) {
+	runtime := &recordingRuntime{}
+	_, err := config.LoadInto[type1, type2](
+		runtime,
+		map[string]module.Module{},
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Path: "test://${env}/rename.wasm"},
+			},
+		},
+		enumerable.New([]type1{}),
+		config.WithModuleResolvers(runtime.resolvers()),
+		config.WithStrictVariables(),
+	)
+	require.ErrorContains(t, err, "undefined variable: env")
+}
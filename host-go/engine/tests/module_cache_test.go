@@ -0,0 +1,136 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/engine"
+	"github.com/lens-vm/lens/host-go/engine/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// emptyWasmModule is the smallest valid wasm binary, it is sufficient for testing module
+// retrieval but cannot be instantiated as a lens.
+var emptyWasmModule = []byte("\x00asm\x01\x00\x00\x00")
+
+func newModuleServer(t *testing.T, requestCount *int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requestCount++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, err := w.Write(emptyWasmModule)
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewModuleWithCacheServesFreshModuleFromCache(t *testing.T) {
+	requestCount := 0
+	server := newModuleServer(t, &requestCount)
+	moduleCache := cache.New(t.TempDir())
+
+	_, err := engine.NewModule(newRuntime(), server.URL+"/module.wasm", engine.WithCache(moduleCache))
+	require.NoError(t, err)
+
+	_, err = engine.NewModule(newRuntime(), server.URL+"/module.wasm", engine.WithCache(moduleCache))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, requestCount)
+}
+
+func TestNewModuleWithCacheRevalidatesStaleModule(t *testing.T) {
+	requestCount := 0
+	server := newModuleServer(t, &requestCount)
+	moduleCache := cache.New(t.TempDir())
+	moduleCache.TTL = 0
+
+	_, err := engine.NewModule(newRuntime(), server.URL+"/module.wasm", engine.WithCache(moduleCache))
+	require.NoError(t, err)
+
+	// The server will respond with 304 Not Modified, so the cached content must be used.
+	_, err = engine.NewModule(newRuntime(), server.URL+"/module.wasm", engine.WithCache(moduleCache))
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, requestCount)
+}
+
+func TestNewModuleWithCacheOfflineServesStaleModule(t *testing.T) {
+	requestCount := 0
+	server := newModuleServer(t, &requestCount)
+	moduleCache := cache.New(t.TempDir())
+	moduleCache.TTL = 0
+
+	_, err := engine.NewModule(newRuntime(), server.URL+"/module.wasm", engine.WithCache(moduleCache))
+	require.NoError(t, err)
+
+	server.Close()
+	moduleCache.Offline = true
+
+	_, err = engine.NewModule(newRuntime(), server.URL+"/module.wasm", engine.WithCache(moduleCache))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, requestCount)
+}
+
+func TestNewModuleWithCacheOfflineErrorsGivenUncachedModule(t *testing.T) {
+	requestCount := 0
+	server := newModuleServer(t, &requestCount)
+	moduleCache := cache.New(t.TempDir())
+	moduleCache.Offline = true
+
+	_, err := engine.NewModule(newRuntime(), server.URL+"/module.wasm", engine.WithCache(moduleCache))
+
+	require.ErrorIs(t, err, cache.ErrNotCached)
+	assert.Equal(t, 0, requestCount)
+}
+
+func TestNewModuleWithCacheAndHashServesModuleByContentAddress(t *testing.T) {
+	requestCount := 0
+	server := newModuleServer(t, &requestCount)
+	moduleCache := cache.New(t.TempDir())
+	hash := cache.Digest(emptyWasmModule)
+
+	_, err := engine.NewModule(
+		newRuntime(),
+		server.URL+"/module.wasm",
+		engine.WithCache(moduleCache),
+		engine.WithHash(hash),
+	)
+	require.NoError(t, err)
+
+	// A different url with the same content hash should not need to be fetched.
+	moduleCache.Offline = true
+	_, err = engine.NewModule(
+		newRuntime(),
+		server.URL+"/other.wasm",
+		engine.WithCache(moduleCache),
+		engine.WithHash(hash),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, requestCount)
+}
+
+func TestNewModuleErrorsGivenHashMismatch(t *testing.T) {
+	requestCount := 0
+	server := newModuleServer(t, &requestCount)
+
+	_, err := engine.NewModule(
+		newRuntime(),
+		server.URL+"/module.wasm",
+		engine.WithHash(cache.Digest([]byte("not the module"))),
+	)
+
+	require.ErrorContains(t, err, "module hash mismatch")
+}
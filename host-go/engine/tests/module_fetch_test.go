@@ -0,0 +1,155 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lens-vm/lens/host-go/engine"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadModuleErrorsGivenNotFoundStatus(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := engine.ReadModule(server.URL + "/module.wasm")
+
+	var statusErr *engine.HTTPStatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	assert.ErrorContains(t, err, server.URL+"/module.wasm")
+	assert.ErrorContains(t, err, "404 Not Found")
+}
+
+func TestReadModuleRetriesGivenServerError(t *testing.T) {
+	requestCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		if requestCount < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, err := w.Write(emptyWasmModule)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	content, err := engine.ReadModule(
+		server.URL+"/module.wasm",
+		engine.WithFetchPolicy(engine.FetchPolicy{
+			Retries: 2,
+			Backoff: time.Millisecond,
+		}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, emptyWasmModule, content)
+	assert.Equal(t, 3, requestCount)
+}
+
+func TestReadModuleDoesNotRetryGivenClientError(t *testing.T) {
+	requestCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := engine.ReadModule(
+		server.URL+"/module.wasm",
+		engine.WithFetchPolicy(engine.FetchPolicy{
+			Retries: 2,
+			Backoff: time.Millisecond,
+		}),
+	)
+
+	require.ErrorContains(t, err, "403 Forbidden")
+	assert.Equal(t, 1, requestCount)
+}
+
+func TestReadModuleErrorsGivenBodyExceedsMaxBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write(emptyWasmModule)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	_, err := engine.ReadModule(
+		server.URL+"/module.wasm",
+		engine.WithFetchPolicy(engine.FetchPolicy{
+			MaxBytes: 4,
+		}),
+	)
+
+	require.ErrorContains(t, err, "body exceeds the maximum size of 4 bytes")
+}
+
+func TestReadModuleErrorsGivenHostNotAllowed(t *testing.T) {
+	requestCount := 0
+	server := newModuleServer(t, &requestCount)
+
+	_, err := engine.ReadModule(
+		server.URL+"/module.wasm",
+		engine.WithFetchPolicy(engine.FetchPolicy{
+			AllowedHosts: []string{"*.example.com"},
+		}),
+	)
+
+	require.ErrorContains(t, err, "is not allowed")
+	assert.Equal(t, 0, requestCount)
+}
+
+func TestReadModuleErrorsGivenRedirectToHostNotAllowed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://modules.example.org/module.wasm", http.StatusFound)
+	}))
+	defer server.Close()
+
+	_, err := engine.ReadModule(
+		server.URL+"/module.wasm",
+		engine.WithFetchPolicy(engine.FetchPolicy{
+			AllowedHosts: []string{"127.0.0.1"},
+		}),
+	)
+
+	require.ErrorContains(t, err, `host "modules.example.org" is not allowed`)
+}
+
+func TestReadModuleErrorsGivenSchemeNotAllowed(t *testing.T) {
+	requestCount := 0
+	server := newModuleServer(t, &requestCount)
+
+	_, err := engine.ReadModule(
+		server.URL+"/module.wasm",
+		engine.WithFetchPolicy(engine.FetchPolicy{
+			AllowedSchemes: []string{"https"},
+		}),
+	)
+
+	require.ErrorContains(t, err, `scheme "http" is not allowed`)
+	assert.Equal(t, 0, requestCount)
+}
+
+func TestReadModuleErrorsGivenTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	_, err := engine.ReadModule(
+		server.URL+"/module.wasm",
+		engine.WithFetchPolicy(engine.FetchPolicy{
+			Timeout: 10 * time.Millisecond,
+		}),
+	)
+
+	require.ErrorContains(t, err, "context deadline exceeded")
+}
@@ -0,0 +1,67 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tests
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lens-vm/lens/host-go/engine"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadModuleWithDataURL(t *testing.T) {
+	path := "data:application/wasm;base64," + base64.StdEncoding.EncodeToString(emptyWasmModule)
+
+	content, err := engine.ReadModule(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, emptyWasmModule, content)
+}
+
+func TestReadModuleWithRelativePath(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "module.wasm"), emptyWasmModule, 0600)
+	require.NoError(t, err)
+
+	content, err := engine.ReadModule("module.wasm", engine.WithBaseDir(dir))
+	require.NoError(t, err)
+
+	assert.Equal(t, emptyWasmModule, content)
+}
+
+func TestReadModuleWithFSResolver(t *testing.T) {
+	resolvers := engine.NewModuleResolvers()
+	resolvers.Register("embed", engine.NewFSResolver(fstest.MapFS{
+		"lenses/module.wasm": &fstest.MapFile{Data: emptyWasmModule},
+	}))
+
+	content, err := engine.ReadModule("embed:lenses/module.wasm", engine.WithModuleResolvers(resolvers))
+	require.NoError(t, err)
+
+	assert.Equal(t, emptyWasmModule, content)
+}
+
+func TestReadModuleWithCustomResolver(t *testing.T) {
+	resolvers := engine.NewModuleResolvers()
+	resolvers.Register("store", engine.ModuleResolverFunc(func(req engine.ModuleRequest) ([]byte, error) {
+		assert.Equal(t, "abc", req.URL.Opaque)
+		return emptyWasmModule, nil
+	}))
+
+	_, err := engine.NewModule(newRuntime(), "store:abc", engine.WithModuleResolvers(resolvers))
+	require.NoError(t, err)
+}
+
+func TestReadModuleErrorsGivenUnregisteredScheme(t *testing.T) {
+	_, err := engine.ReadModule("store:abc")
+
+	require.ErrorContains(t, err, "invalid module path: store:abc")
+}
@@ -0,0 +1,127 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !js
+
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/lens-vm/lens/host-go/engine"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/runtimes/wazero"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapModuleMixedWithWasm(t *testing.T) {
+	source := enumerable.New([]type1{{Name: "John", Age: 32}, {Name: "Fred", Age: 55}})
+
+	olderModule := engine.MapModule(func(in type1) (type1, error) {
+		in.Age++
+		return in, nil
+	})
+	older, err := engine.NewInstance(olderModule)
+	require.NoError(t, err)
+
+	wasmModule, err := wazero.New().NewModule([]byte(identityWat))
+	require.NoError(t, err)
+	identity, err := engine.NewInstance(wasmModule)
+	require.NoError(t, err)
+
+	results := engine.Append[type1, type2](source, older, identity)
+
+	assertResults(t, results, []type2{{FullName: "", Age: 33}, {FullName: "", Age: 56}})
+}
+
+func TestNativeModuleMayFilterItems(t *testing.T) {
+	source := enumerable.New([]type1{{Name: "John", Age: 32}, {Name: "Fred", Age: 55}})
+
+	adults := engine.NativeModule(func(next func() (type1, bool, error)) (type1, bool, error) {
+		for {
+			item, hasNext, err := next()
+			if err != nil || !hasNext {
+				return item, false, err
+			}
+			if item.Age > 40 {
+				return item, true, nil
+			}
+		}
+	})
+	instance, err := engine.NewInstance(adults)
+	require.NoError(t, err)
+
+	results := engine.Append[type1, type1](source, instance)
+
+	assertResults(t, results, []type1{{Name: "Fred", Age: 55}})
+}
+
+func TestMapModuleReturnsError(t *testing.T) {
+	source := enumerable.New([]type1{{Name: "John", Age: 32}})
+
+	failing := engine.MapModule(func(in type1) (type1, error) {
+		return in, errors.New("boom")
+	})
+	instance, err := engine.NewInstance(failing)
+	require.NoError(t, err)
+
+	results := engine.Append[type1, type1](source, instance)
+
+	_, err = results.Next()
+	require.ErrorContains(t, err, "boom")
+}
+
+func TestNativeModuleHasNoInverse(t *testing.T) {
+	_, err := engine.NewInverse(engine.MapModule(func(in type1) (type1, error) {
+		return in, nil
+	}))
+	require.ErrorContains(t, err, "native modules cannot be inverted")
+}
+
+func TestNativeModuleReturnsErrorGivenArguments(t *testing.T) {
+	_, err := engine.NewInstance(
+		engine.MapModule(func(in type1) (type1, error) {
+			return in, nil
+		}),
+		map[string]any{"name": "John"},
+	)
+	require.ErrorContains(t, err, "native modules do not accept arguments")
+}
+
+func TestRegisteredNativeModuleFromLensConfig(t *testing.T) {
+	engine.RegisterNativeModule("tests/shout", engine.MapModule(func(in map[string]any) (map[string]any, error) {
+		in["Name"] = in["Name"].(string) + "!"
+		return in, nil
+	}))
+
+	source := enumerable.New([]type1{{Name: "John", Age: 32}})
+	lensConfig := model.Lens{
+		Lenses: []model.LensModule{
+			{Path: "native:tests/shout"},
+			{Wat: identityWat, Runtime: "wazero"},
+		},
+	}
+
+	// The default runtime cannot host wasm, native modules should not need it.
+	results, err := config.LoadInto[type1, type1](&recordingRuntime{}, map[string]module.Module{}, lensConfig, source)
+	require.NoError(t, err)
+
+	assertResults(t, results, []type1{{Name: "John!", Age: 32}})
+}
+
+func TestLoadReturnsErrorGivenUnregisteredNativeModule(t *testing.T) {
+	problems := config.Validate(model.Lens{
+		Lenses: []model.LensModule{
+			{Path: "native:tests/unknown"},
+		},
+	})
+	require.Len(t, problems, 1)
+	assert.ErrorContains(t, problems[0], "native module tests/unknown has not been registered")
+}
@@ -0,0 +1,127 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/engine"
+	"github.com/lens-vm/lens/host-go/engine/pipes"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeeYieldsEveryItemToEachBranch(t *testing.T) {
+	source := enumerable.New([]type1{{Name: "John", Age: 32}, {Name: "Fred", Age: 55}})
+
+	branches := pipes.Tee[type1](source, 2)
+	require.Len(t, branches, 2)
+
+	// Read the branches at different rates.
+	hasNext, err := branches[0].Next()
+	require.NoError(t, err)
+	require.True(t, hasNext)
+
+	assertResults(t, branches[1], []type1{{Name: "John", Age: 32}, {Name: "Fred", Age: 55}})
+
+	value, err := branches[0].Value()
+	require.NoError(t, err)
+	assert.Equal(t, type1{Name: "John", Age: 32}, value)
+	assertResults(t, branches[0], []type1{{Name: "Fred", Age: 55}})
+}
+
+func TestTeeBranchesCanBeAppendedToIndependently(t *testing.T) {
+	source := enumerable.New([]map[string]any{{"name": "John"}, {"name": "Fred"}})
+
+	branches := pipes.Tee[map[string]any](source, 2)
+
+	shout := engine.Append[map[string]any, map[string]any](
+		branches[0],
+		newHostInstance(t, func(item map[string]any) map[string]any {
+			item["name"] = item["name"].(string) + "!"
+			return item
+		}),
+	)
+	question := engine.Append[map[string]any, map[string]any](
+		branches[1],
+		newHostInstance(t, func(item map[string]any) map[string]any {
+			item["name"] = item["name"].(string) + "?"
+			return item
+		}),
+	)
+
+	// Merge the projections back together, which should still be appendable to.
+	merged := engine.Append[map[string]any, map[string]any](
+		pipes.Merge(shout, question),
+		newHostInstance(t, func(item map[string]any) map[string]any {
+			item["merged"] = true
+			return item
+		}),
+	)
+
+	assertResults(t, merged, []map[string]any{
+		{"name": "John!", "merged": true},
+		{"name": "John?", "merged": true},
+		{"name": "Fred!", "merged": true},
+		{"name": "Fred?", "merged": true},
+	})
+}
+
+func TestTeeResetResetsAllBranches(t *testing.T) {
+	source := enumerable.New([]type1{{Name: "John", Age: 32}})
+
+	branches := pipes.Tee[type1](source, 2)
+	assertResults(t, branches[0], []type1{{Name: "John", Age: 32}})
+
+	branches[1].Reset()
+
+	assertResults(t, branches[0], []type1{{Name: "John", Age: 32}})
+	assertResults(t, branches[1], []type1{{Name: "John", Age: 32}})
+}
+
+func TestMergeInterleavesSources(t *testing.T) {
+	merged := pipes.Merge[int](
+		enumerable.New([]int{1, 3, 5, 6}),
+		enumerable.New([]int{}),
+		enumerable.New([]int{2, 4}),
+	)
+
+	assertResults(t, merged, []int{1, 2, 3, 4, 5, 6})
+}
+
+func TestConcatYieldsSourcesInOrder(t *testing.T) {
+	concatenated := pipes.Concat[int](
+		enumerable.New([]int{1, 2}),
+		enumerable.New([]int{}),
+		enumerable.New([]int{3}),
+	)
+
+	assertResults(t, concatenated, []int{1, 2, 3})
+
+	concatenated.Reset()
+	assertResults(t, concatenated, []int{1, 2, 3})
+}
+
+func TestConcatBytesAppendableToInstance(t *testing.T) {
+	concatenated := pipes.Concat[map[string]any](
+		enumerable.New([]map[string]any{{"name": "John"}}),
+		enumerable.New([]map[string]any{{"name": "Fred"}}),
+	)
+
+	results := engine.Append[map[string]any, map[string]any](
+		concatenated,
+		newHostInstance(t, func(item map[string]any) map[string]any {
+			item["seen"] = true
+			return item
+		}),
+	)
+
+	assertResults(t, results, []map[string]any{
+		{"name": "John", "seen": true},
+		{"name": "Fred", "seen": true},
+	})
+}
@@ -0,0 +1,115 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !js
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/runtimes"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/require"
+)
+
+// loopingWat is a lens that never yields, looping forever.
+const loopingWat = `
+(module
+  (import "lens" "next" (func $next (result i32)))
+  (memory (export "memory") 1)
+
+  (func (export "alloc") (param $size i32) (result i32)
+    (i32.const 1024))
+
+  (func (export "transform") (result i32)
+    (loop $forever
+      (br $forever))
+    (i32.const 0)))
+`
+
+func TestRuntimeWithTimeoutInterruptsTransform(t *testing.T) {
+	for _, name := range []string{"wasmtime", "wazero"} {
+		t.Run(name, func(t *testing.T) {
+			runtime, err := runtimes.New(name, runtimes.WithTimeout(50*time.Millisecond))
+			require.NoError(t, err)
+
+			assertLoopingWatFails(t, runtime)
+		})
+	}
+}
+
+func TestRuntimeWithFuelInterruptsTransform(t *testing.T) {
+	runtime, err := runtimes.New("wasmtime", runtimes.WithFuel(10_000))
+	require.NoError(t, err)
+
+	assertLoopingWatFails(t, runtime)
+}
+
+func TestRuntimeWithLimitsTransforms(t *testing.T) {
+	tests := map[string][]runtimes.Option{
+		"wasmtime": {runtimes.WithFuel(1_000_000), runtimes.WithTimeout(time.Minute)},
+		"wazero":   {runtimes.WithTimeout(time.Minute)},
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			runtime, err := runtimes.New(name, opts...)
+			require.NoError(t, err)
+
+			assertIdentityWat(t, runtime)
+		})
+	}
+}
+
+func TestRuntimeReturnsErrorGivenUnsupportedLimit(t *testing.T) {
+	_, err := runtimes.New("wazero", runtimes.WithFuel(1_000_000))
+	require.ErrorContains(t, err, "the wazero runtime does not support fuel")
+}
+
+func TestLoadReturnsErrorGivenUnsupportedLimitForPerLensRuntime(t *testing.T) {
+	source := enumerable.New([]type1{{Name: "John", Age: 32}})
+	lensConfig := model.Lens{
+		Lenses: []model.LensModule{
+			{Wat: identityWat, Runtime: "wazero"},
+		},
+	}
+
+	_, err := config.Load[type1, type1](
+		lensConfig,
+		source,
+		config.WithRuntimeOptions(runtimes.WithFuel(1_000_000)),
+	)
+	require.ErrorContains(t, err, "the wazero runtime does not support fuel")
+}
+
+func assertLoopingWatFails(t *testing.T, runtime module.Runtime) {
+	source := enumerable.New([]type1{{Name: "John", Age: 32}})
+	lensConfig := model.Lens{
+		Lenses: []model.LensModule{
+			{Wat: loopingWat},
+		},
+	}
+
+	results, err := config.LoadInto[type1, type1](runtime, map[string]module.Module{}, lensConfig, source)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := results.Next()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		require.Error(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("transform was not interrupted")
+	}
+}
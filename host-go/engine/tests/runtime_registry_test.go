@@ -0,0 +1,95 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !js
+
+package tests
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/runtimes"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimesNewWithRegisteredRuntimes(t *testing.T) {
+	names := runtimes.Names()
+	assert.Contains(t, names, "wasmtime")
+	assert.Contains(t, names, "wazero")
+
+	for _, name := range names {
+		if !slices.Contains([]string{"wasmtime", "wazero", "wasmer"}, name) {
+			// Skip any runtimes registered by other tests.
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			runtime, err := runtimes.New(name)
+			require.NoError(t, err)
+			assertIdentityWat(t, runtime)
+		})
+	}
+}
+
+func TestRuntimesNewReturnsErrorGivenUnknownRuntime(t *testing.T) {
+	_, err := runtimes.New("v8")
+	require.ErrorContains(t, err, `unknown runtime "v8"`)
+}
+
+func TestRuntimesNewUsesEnvRuntime(t *testing.T) {
+	t.Setenv(runtimes.EnvRuntime, "v8")
+
+	_, err := runtimes.New("")
+	require.ErrorContains(t, err, `unknown runtime "v8"`)
+}
+
+func TestRuntimesRegister(t *testing.T) {
+	recording := &recordingRuntime{}
+	runtimes.Register("recording", func(opts ...runtimes.Option) (module.Runtime, error) {
+		return recording, nil
+	})
+	assert.True(t, slices.Contains(runtimes.Names(), "recording"))
+
+	runtime, err := runtimes.New("recording")
+	require.NoError(t, err)
+	assert.Same(t, recording, runtime)
+}
+
+func TestLoadWithRuntime(t *testing.T) {
+	_, err := config.Load[type1, type1](
+		model.Lens{},
+		enumerable.New([]type1{}),
+		config.WithRuntime("v8"),
+	)
+	require.ErrorContains(t, err, `unknown runtime "v8"`)
+}
+
+func TestLoadIntoWithPerLensRuntime(t *testing.T) {
+	source := enumerable.New([]type1{{Name: "John", Age: 32}})
+
+	// The default runtime cannot host wasm, so the lens would fail to load if it did not respect
+	// the runtime declared by the lens module.
+	modulesByPath := map[string]module.Module{}
+	lensConfig := model.Lens{
+		Modules: map[string]model.ModuleDeclaration{
+			"identity": {Wat: identityWat, Runtime: "wazero"},
+		},
+		Lenses: []model.LensModule{
+			{Module: "identity"},
+			{Wat: identityWat, Runtime: "wasmtime"},
+		},
+	}
+
+	results, err := config.LoadInto[type1, type1](&recordingRuntime{}, modulesByPath, lensConfig, source)
+	require.NoError(t, err)
+	assert.Len(t, modulesByPath, 2)
+
+	assertResults(t, results, []type1{{Name: "John", Age: 32}})
+}
@@ -5,8 +5,14 @@
 package tests
 
 import (
+	"testing"
+
 	"github.com/lens-vm/lens/host-go/engine/module"
 	"github.com/lens-vm/lens/host-go/runtimes"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type type1 struct {
@@ -22,3 +28,18 @@ type type2 struct {
 func newRuntime() module.Runtime {
 	return runtimes.Default()
 }
+
+func assertResults[T any](t *testing.T, results enumerable.Enumerable[T], expected []T) {
+	actual := []T{}
+	for {
+		hasNext, err := results.Next()
+		require.NoError(t, err)
+		if !hasNext {
+			break
+		}
+		value, err := results.Value()
+		require.NoError(t, err)
+		actual = append(actual, value)
+	}
+	assert.Equal(t, expected, actual)
+}
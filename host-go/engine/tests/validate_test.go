@@ -0,0 +1,78 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !js
+
+package tests
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateReturnsNoProblemsGivenValidLens(t *testing.T) {
+	problems := config.Validate(
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Wat: identityWat},
+				{Wat: identityWat, When: &model.Condition{Field: "name", Equals: "John"}},
+			},
+		},
+		config.WithRuntime("wazero"),
+	)
+	assert.Empty(t, problems)
+}
+
+func TestValidateReturnsAllProblems(t *testing.T) {
+	problems := config.Validate(
+		model.Lens{
+			Lenses: []model.LensModule{
+				{Wat: identityWat},
+				// The identity lens has no inverse.
+				{Wat: identityWat, Inverse: true},
+				// The identity lens accepts no arguments.
+				{Wat: identityWat, Arguments: map[string]any{"src": "Name"}},
+				{Wat: "(module"},
+				// Infinity cannot be represented in json, so could never match an item.
+				{Wat: identityWat, When: &model.Condition{Field: "age", Equals: math.Inf(1)}},
+			},
+		},
+		config.WithRuntime("wazero"),
+	)
+	require.Len(t, problems, 4)
+
+	assert.Equal(t, 1, problems[0].Index)
+	assert.ErrorContains(t, problems[0], "`inverse` does not exist")
+
+	assert.Equal(t, 2, problems[1].Index)
+	assert.ErrorContains(t, problems[1], "`set_param` does not exist")
+
+	assert.Equal(t, 3, problems[2].Index)
+	assert.Equal(t, "wat", problems[2].Module)
+
+	assert.Equal(t, 4, problems[3].Index)
+}
+
+func TestValidateReturnsProblemGivenUnknownRuntime(t *testing.T) {
+	problems := config.Validate(model.Lens{}, config.WithRuntime("v8"))
+	require.Len(t, problems, 1)
+	assert.Equal(t, -1, problems[0].Index)
+	assert.ErrorContains(t, problems[0], `unknown runtime "v8"`)
+}
+
+func TestValidateFileReturnsProblemGivenInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	writeLensFile(t, dir, "lens.json", `{"lenses": [{"include": "missing.json"}]}`)
+
+	problems := config.ValidateFile(filepath.Join(dir, "lens.json"))
+	require.Len(t, problems, 1)
+	assert.Equal(t, -1, problems[0].Index)
+}
@@ -0,0 +1,38 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build wasmer && !windows && !js
+
+package tests
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lens-vm/lens/host-go/runtimes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWasmerWithCacheDirPersistsCompiledModule(t *testing.T) {
+	dir := t.TempDir()
+
+	runtime, err := runtimes.New("wasmer", runtimes.WithCacheDir(dir))
+	require.NoError(t, err)
+	assertIdentityWat(t, runtime)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestWasmerReturnsErrorGivenLimits(t *testing.T) {
+	_, err := runtimes.New("wasmer", runtimes.WithFuel(1_000_000))
+	require.ErrorContains(t, err, "the wasmer runtime does not support fuel")
+
+	_, err = runtimes.New("wasmer", runtimes.WithTimeout(time.Minute))
+	require.ErrorContains(t, err, "the wasmer runtime does not support timeouts")
+}
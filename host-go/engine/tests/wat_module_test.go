@@ -0,0 +1,136 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !js
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/lens-vm/lens/host-go/config"
+	"github.com/lens-vm/lens/host-go/config/model"
+	"github.com/lens-vm/lens/host-go/engine"
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/runtimes/wasmtime"
+	"github.com/lens-vm/lens/host-go/runtimes/wazero"
+	"github.com/sourcenetwork/immutable/enumerable"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// identityWat is a lens that yields every item it receives unchanged.
+const identityWat = `
+(module
+  (import "lens" "next" (func $next (result i32)))
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  ;; A bump allocator, memory is never freed.
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $heap))
+    (global.set $heap (i32.add (local.get $ptr) (local.get $size)))
+    (local.get $ptr))
+
+  (func (export "transform") (result i32)
+    (call $next)))
+`
+
+func TestWatModuleWithWasmtime(t *testing.T) {
+	assertIdentityWat(t, wasmtime.New())
+}
+
+func TestWatModuleWithWazero(t *testing.T) {
+	assertIdentityWat(t, wazero.New())
+}
+
+func TestWatModuleWithByteOrderMark(t *testing.T) {
+	for name, runtime := range map[string]module.Runtime{"wasmtime": wasmtime.New(), "wazero": wazero.New()} {
+		t.Run(name, func(t *testing.T) {
+			_, err := runtime.NewModule([]byte("\ufeff" + identityWat))
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWatModuleWithInvalidWat(t *testing.T) {
+	_, err := wazero.New().NewModule([]byte(`(module (func (export "f") (result i32) (i32.foo)))`))
+	require.ErrorContains(t, err, "i32.foo")
+}
+
+func TestWatModuleFromLensConfig(t *testing.T) {
+	source := enumerable.New([]type1{{Name: "John", Age: 32}})
+
+	modulesByPath := map[string]module.Module{}
+	lensConfig := model.Lens{
+		Lenses: []model.LensModule{
+			{Wat: identityWat},
+			{Wat: identityWat},
+		},
+	}
+
+	results, err := config.LoadInto[type1, type1](wazero.New(), modulesByPath, lensConfig, source)
+	require.NoError(t, err)
+	// Identical inline modules should only be compiled once.
+	assert.Len(t, modulesByPath, 1)
+
+	assertResults(t, results, []type1{{Name: "John", Age: 32}})
+}
+
+func TestWatModuleFromLensConfigWithPathAndWat(t *testing.T) {
+	source := enumerable.New([]type1{})
+
+	lensConfig := model.Lens{
+		Lenses: []model.LensModule{
+			{Path: "lens.wasm", Wat: identityWat},
+		},
+	}
+
+	_, err := config.LoadInto[type1, type1](wazero.New(), map[string]module.Module{}, lensConfig, source)
+	require.ErrorContains(t, err, "may not declare both a path")
+}
+
+func TestWatModuleFromLensConfigWithWhenCondition(t *testing.T) {
+	source := enumerable.New([]map[string]any{
+		{"__type": "user", "name": "John"},
+		{"__type": "post", "name": "Hello"},
+	})
+
+	lensConfig := model.Lens{
+		Lenses: []model.LensModule{
+			{
+				Wat:  identityWat,
+				When: &model.Condition{Field: "__type", Equals: "user"},
+			},
+		},
+	}
+
+	results, err := config.LoadInto[map[string]any, map[string]any](
+		wazero.New(),
+		map[string]module.Module{},
+		lensConfig,
+		source,
+	)
+	require.NoError(t, err)
+
+	assertResults(t, results, []map[string]any{
+		{"__type": "user", "name": "John"},
+		{"__type": "post", "name": "Hello"},
+	})
+}
+
+func assertIdentityWat(t *testing.T, runtime module.Runtime) {
+	lensModule, err := runtime.NewModule([]byte(identityWat))
+	require.NoError(t, err)
+
+	instance, err := engine.NewInstance(lensModule)
+	require.NoError(t, err)
+
+	source := enumerable.New([]type1{{Name: "John", Age: 32}, {Name: "Fred", Age: 55}})
+	results := engine.Append[type1, type1](source, instance)
+
+	assertResults(t, results, []type1{{Name: "John", Age: 32}, {Name: "Fred", Age: 55}})
+}
@@ -3,17 +3,19 @@ module github.com/lens-vm/lens/host-go
 go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/bytecodealliance/wasmtime-go/v21 v21.0.0
 	github.com/lens-vm/lens/tests/modules v0.0.0-20230720125121-328ae81f5744
 	github.com/sourcenetwork/immutable v0.2.0
 	github.com/stretchr/testify v1.8.1
 	github.com/tetratelabs/wazero v1.7.2
 	github.com/wasmerio/wasmer-go v1.0.4
+	golang.org/x/sys v0.20.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/kr/text v0.2.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,134 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+/*
+The artifacts package contains helpers for persisting compiled module code on disk, so that it may
+be reused across processes.
+
+Compiled code is native machine code, it is only valid for the runtime version and host CPU that
+produced it, and must only ever be read from a trusted directory.
+*/
+package artifacts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/cpu"
+)
+
+// Key returns the key under which the compiled form of the given wasm binary should be stored.
+//
+// The key is derived from the module content, the given runtime version and configuration, and
+// the host platform and CPU features.
+func Key(wasmBytes []byte, runtimeVersion string, config string) string {
+	moduleSum := sha256.Sum256(wasmBytes)
+
+	return hash(
+		hex.EncodeToString(moduleSum[:]),
+		runtimeVersion,
+		config,
+		PlatformKey(),
+	)
+}
+
+// PlatformKey returns a key describing the host platform and CPU features.
+//
+// Compiled code is only valid for the platform that produced it.
+func PlatformKey() string {
+	return hash(runtime.GOOS, runtime.GOARCH, CPUFeatures())
+}
+
+func hash(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		// Separate the parts so that adjacent values cannot collide.
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CPUFeatures returns a stable description of the features supported by the host CPU.
+func CPUFeatures() string {
+	features := []string{}
+	for _, arch := range []any{cpu.X86, cpu.ARM64, cpu.ARM, cpu.PPC64, cpu.S390X} {
+		v := reflect.ValueOf(arch)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if field.Kind() == reflect.Bool && field.Bool() {
+				features = append(features, v.Type().Field(i).Name)
+			}
+		}
+	}
+	sort.Strings(features)
+	return strings.Join(features, ",")
+}
+
+// ModuleVersion returns the version of the given Go module that this binary was built with.
+//
+// If the version cannot be determined "unknown" is returned.
+func ModuleVersion(path string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == path {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			return dep.Version + dep.Sum
+		}
+	}
+	return "unknown"
+}
+
+// Load returns the artifact stored under the given key in the given directory, and true if it was found.
+func Load(dir string, key string) ([]byte, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Store writes the given artifact under the given key in the given directory.
+//
+// The artifact is written to a temporary file before being moved into place, so that concurrent
+// readers never observe a partially written artifact.
+func Store(dir string, key string, data []byte) error {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	err = os.Rename(f.Name(), filepath.Join(dir, key))
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package wat
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func appendU32(b []byte, v uint32) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func appendS64(b []byte, v int64) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		done := (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0)
+		if !done {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if done {
+			return b
+		}
+	}
+}
+
+func appendName(b []byte, name []byte) []byte {
+	b = appendU32(b, uint32(len(name)))
+	return append(b, name...)
+}
+
+// appendSection appends the section with the given id and content, if the content is not nil.
+func appendSection(b []byte, id byte, content []byte) []byte {
+	if content == nil {
+		return b
+	}
+	b = append(b, id)
+	b = appendU32(b, uint32(len(content)))
+	return append(b, content...)
+}
+
+// parseUint parses an unsigned integer literal, including hex and underscore separated forms.
+func parseUint(s string, bits int) (uint64, error) {
+	clean := strings.ReplaceAll(s, "_", "")
+	clean = strings.TrimPrefix(clean, "+")
+	base := 10
+	if strings.HasPrefix(clean, "0x") {
+		clean = clean[2:]
+		base = 16
+	}
+	return strconv.ParseUint(clean, base, bits)
+}
+
+// parseInt parses a signed or unsigned integer literal of the given bit width, returning its
+// two's complement value.
+func parseInt(s string, bits int) (int64, error) {
+	negative := strings.HasPrefix(s, "-")
+	u, err := parseUint(strings.TrimPrefix(s, "-"), bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %q", s)
+	}
+	if negative {
+		if u > 1<<(bits-1) {
+			return 0, fmt.Errorf("integer %q out of range", s)
+		}
+		return -int64(u), nil
+	}
+	if bits == 32 {
+		return int64(int32(uint32(u))), nil
+	}
+	return int64(u), nil
+}
+
+// parseFloat parses a float literal of the given bit width, returning its little endian encoding.
+func parseFloat(s string, bits int) ([]byte, error) {
+	clean := strings.ReplaceAll(s, "_", "")
+	negative := strings.HasPrefix(clean, "-")
+	unsigned := strings.TrimLeft(clean, "+-")
+
+	var bitsValue uint64
+	switch {
+	case strings.HasPrefix(unsigned, "nan"):
+		payload := uint64(0)
+		if p, ok := strings.CutPrefix(unsigned, "nan:0x"); ok {
+			var err error
+			payload, err = strconv.ParseUint(p, 16, 64)
+			if err != nil || payload == 0 {
+				return nil, fmt.Errorf("invalid float %q", s)
+			}
+		} else if unsigned != "nan" {
+			return nil, fmt.Errorf("invalid float %q", s)
+		}
+		if bits == 32 {
+			if payload == 0 {
+				payload = 1 << 22
+			}
+			bitsValue = uint64(0x7f800000) | payload
+		} else {
+			if payload == 0 {
+				payload = 1 << 51
+			}
+			bitsValue = uint64(0x7ff0000000000000) | payload
+		}
+		if negative {
+			bitsValue |= 1 << (bits - 1)
+		}
+
+	default:
+		parseable := unsigned
+		if strings.HasPrefix(parseable, "0x") && !strings.ContainsAny(parseable, "pP") {
+			// Go requires hex floats to have an exponent.
+			parseable += "p0"
+		}
+		if negative {
+			parseable = "-" + parseable
+		}
+		f, err := strconv.ParseFloat(parseable, bits)
+		if err != nil {
+			// Values that are out of range are returned by Go as +/-Inf, which is the value we want.
+			var numErr *strconv.NumError
+			if !errors.As(err, &numErr) || numErr.Err != strconv.ErrRange {
+				return nil, fmt.Errorf("invalid float %q", s)
+			}
+		}
+		if bits == 32 {
+			bitsValue = uint64(math.Float32bits(float32(f)))
+		} else {
+			bitsValue = math.Float64bits(f)
+		}
+	}
+
+	out := make([]byte, bits/8)
+	if bits == 32 {
+		binary.LittleEndian.PutUint32(out, uint32(bitsValue))
+	} else {
+		binary.LittleEndian.PutUint64(out, bitsValue)
+	}
+	return out, nil
+}
+
+// isNumber returns true if the given atom is a numeric literal.
+func isNumber(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	if s == "" {
+		return false
+	}
+	if s == "inf" || strings.HasPrefix(s, "nan") {
+		return true
+	}
+	return s[0] >= '0' && s[0] <= '9'
+}
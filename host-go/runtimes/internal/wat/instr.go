@@ -0,0 +1,538 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package wat
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// funcEncoder encodes the instructions of a single function body or constant expression.
+type funcEncoder struct {
+	m      *moduleBuilder
+	locals *namespace
+
+	// labels is the stack of enclosing block labels, the innermost label is last. Unnamed
+	// blocks have an empty label.
+	labels []string
+
+	out []byte
+}
+
+func newFuncEncoder(m *moduleBuilder, locals *namespace) *funcEncoder {
+	if locals == nil {
+		locals = newNamespace("local")
+	}
+	return &funcEncoder{
+		m:      m,
+		locals: locals,
+		// The function body is itself the outermost block.
+		labels: []string{""},
+	}
+}
+
+func (m *moduleBuilder) encodeFunc(f *funcDef) ([]byte, error) {
+	locals := newNamespace("local")
+	for _, name := range f.paramNames {
+		_, err := locals.add(f.node, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Local declarations are run-length encoded by type.
+	localTypes := []byte{}
+	body := f.body
+	for len(body) > 0 && body[0].keyword() == "local" {
+		local := body[0].list[1:]
+		if len(local) == 2 && local[0].isID() {
+			valueType, err := parseValueType(local[1])
+			if err != nil {
+				return nil, err
+			}
+			_, err = locals.add(body[0], local[0].atom)
+			if err != nil {
+				return nil, err
+			}
+			localTypes = append(localTypes, valueType)
+		} else {
+			for _, l := range local {
+				valueType, err := parseValueType(l)
+				if err != nil {
+					return nil, err
+				}
+				_, err = locals.add(body[0], "")
+				if err != nil {
+					return nil, err
+				}
+				localTypes = append(localTypes, valueType)
+			}
+		}
+		body = body[1:]
+	}
+
+	runs := []byte{}
+	runCount := uint32(0)
+	for i := 0; i < len(localTypes); {
+		j := i
+		for j < len(localTypes) && localTypes[j] == localTypes[i] {
+			j++
+		}
+		runs = appendU32(runs, uint32(j-i))
+		runs = append(runs, localTypes[i])
+		runCount++
+		i = j
+	}
+
+	e := newFuncEncoder(m, locals)
+	err := e.encodeInstrs(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.labels) != 1 {
+		return nil, f.node.errorf("unterminated block")
+	}
+
+	out := appendU32(nil, runCount)
+	out = append(out, runs...)
+	out = append(out, e.out...)
+	return append(out, 0x0B), nil
+}
+
+// encodeInstrs encodes a sequence of instructions, which may be in the flat or folded form.
+func (e *funcEncoder) encodeInstrs(items []*node) error {
+	for i := 0; i < len(items); {
+		item := items[i]
+		if item.isList() {
+			err := e.encodeFolded(item)
+			if err != nil {
+				return err
+			}
+			i++
+			continue
+		}
+		if !item.isAtom() {
+			return item.errorf("unexpected %s", describe(item))
+		}
+
+		switch item.atom {
+		case "end":
+			if len(e.labels) <= 1 {
+				return item.errorf("unexpected end")
+			}
+			e.out = append(e.out, 0x0B)
+			e.labels = e.labels[:len(e.labels)-1]
+			i++
+			// The closing label is optional, and must match the opening one if provided.
+			if i < len(items) && items[i].isID() {
+				i++
+			}
+			continue
+
+		case "else":
+			if len(e.labels) <= 1 {
+				return item.errorf("unexpected else")
+			}
+			e.out = append(e.out, 0x05)
+			i++
+			if i < len(items) && items[i].isID() {
+				i++
+			}
+			continue
+		}
+
+		o, ok := ops[item.atom]
+		if !ok {
+			return item.errorf("unknown instruction %q", item.atom)
+		}
+
+		if o.imm == immBlock {
+			label, blockType, consumed, err := e.blockHeader(items[i+1:])
+			if err != nil {
+				return err
+			}
+			e.out = append(e.out, byte(o.code))
+			e.out = append(e.out, blockType...)
+			e.labels = append(e.labels, label)
+			i += 1 + consumed
+			continue
+		}
+
+		immediates, consumed, err := e.immediates(item, o, items[i+1:])
+		if err != nil {
+			return err
+		}
+		e.emit(o, immediates)
+		i += 1 + consumed
+	}
+	return nil
+}
+
+// encodeFolded encodes a single folded instruction, such as `(i32.add (local.get 0) (i32.const 1))`.
+func (e *funcEncoder) encodeFolded(n *node) error {
+	name := n.keyword()
+	o, ok := ops[name]
+	if !ok {
+		return n.errorf("unknown instruction %q", name)
+	}
+	items := n.list[1:]
+
+	switch name {
+	case "block", "loop":
+		label, blockType, consumed, err := e.blockHeader(items)
+		if err != nil {
+			return err
+		}
+		e.out = append(e.out, byte(o.code))
+		e.out = append(e.out, blockType...)
+		e.labels = append(e.labels, label)
+		err = e.encodeInstrs(items[consumed:])
+		if err != nil {
+			return err
+		}
+		e.out = append(e.out, 0x0B)
+		e.labels = e.labels[:len(e.labels)-1]
+		return nil
+
+	case "if":
+		label, blockType, consumed, err := e.blockHeader(items)
+		if err != nil {
+			return err
+		}
+		items = items[consumed:]
+
+		// The condition is every folded instruction preceding the `(then ...)` clause.
+		condition := []*node{}
+		for len(items) > 0 && items[0].keyword() != "then" {
+			condition = append(condition, items[0])
+			items = items[1:]
+		}
+		if len(items) == 0 {
+			return n.errorf("if is missing then clause")
+		}
+		err = e.encodeInstrs(condition)
+		if err != nil {
+			return err
+		}
+
+		e.out = append(e.out, byte(o.code))
+		e.out = append(e.out, blockType...)
+		e.labels = append(e.labels, label)
+
+		err = e.encodeInstrs(items[0].list[1:])
+		if err != nil {
+			return err
+		}
+		items = items[1:]
+		if len(items) > 0 && items[0].keyword() == "else" {
+			e.out = append(e.out, 0x05)
+			err = e.encodeInstrs(items[0].list[1:])
+			if err != nil {
+				return err
+			}
+			items = items[1:]
+		}
+		if len(items) != 0 {
+			return items[0].errorf("unexpected %s in if", describe(items[0]))
+		}
+
+		e.out = append(e.out, 0x0B)
+		e.labels = e.labels[:len(e.labels)-1]
+		return nil
+	}
+
+	immediates, consumed, err := e.immediates(n, o, items)
+	if err != nil {
+		return err
+	}
+
+	// Operands are evaluated before the instruction itself.
+	for _, operand := range items[consumed:] {
+		if !operand.isList() {
+			return operand.errorf("unexpected %s", describe(operand))
+		}
+		err = e.encodeFolded(operand)
+		if err != nil {
+			return err
+		}
+	}
+	e.emit(o, immediates)
+	return nil
+}
+
+func (e *funcEncoder) emit(o op, immediates []byte) {
+	if o.imm == immSelect && len(immediates) > 0 {
+		// Select with explicit result types has its own opcode.
+		o.code = 0x1C
+	}
+	if o.prefix != 0 {
+		e.out = append(e.out, o.prefix)
+		e.out = appendU32(e.out, o.code)
+	} else {
+		e.out = append(e.out, byte(o.code))
+	}
+	e.out = append(e.out, immediates...)
+}
+
+// blockHeader parses the optional label and block type of a block, loop or if instruction.
+func (e *funcEncoder) blockHeader(items []*node) (string, []byte, int, error) {
+	consumed := 0
+	label := ""
+	if len(items) > 0 && items[0].isID() {
+		label = items[0].atom
+		consumed++
+	}
+
+	var explicit *node
+	if consumed < len(items) && items[consumed].keyword() == "type" {
+		explicit = items[consumed]
+		consumed++
+	}
+
+	signature := items[consumed:]
+	t, _, rest, err := parseSignature(signature)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	consumed += len(signature) - len(rest)
+
+	if explicit != nil {
+		index, _, _, err := e.m.typeUse([]*node{explicit})
+		if err != nil {
+			return "", nil, 0, err
+		}
+		return label, appendS64(nil, int64(index)), consumed, nil
+	}
+
+	switch {
+	case len(t.params) == 0 && len(t.results) == 0:
+		return label, []byte{0x40}, consumed, nil
+	case len(t.params) == 0 && len(t.results) == 1:
+		return label, []byte{t.results[0]}, consumed, nil
+	default:
+		return label, appendS64(nil, int64(e.m.findOrAddType(t))), consumed, nil
+	}
+}
+
+// immediates parses the immediate arguments of the given instruction from the given items,
+// returning their encoding and the number of items consumed.
+func (e *funcEncoder) immediates(n *node, o op, items []*node) ([]byte, int, error) {
+	next := func() (*node, error) {
+		if len(items) == 0 || !items[0].isAtom() {
+			return nil, n.errorf("%s is missing an immediate argument", describe(n))
+		}
+		return items[0], nil
+	}
+
+	switch o.imm {
+	case immNone:
+		return nil, 0, nil
+
+	case immLabel:
+		arg, err := next()
+		if err != nil {
+			return nil, 0, err
+		}
+		depth, err := e.resolveLabel(arg)
+		if err != nil {
+			return nil, 0, err
+		}
+		return appendU32(nil, depth), 1, nil
+
+	case immBrTable:
+		depths := []uint32{}
+		for _, item := range items {
+			if !item.isAtom() || !(item.isID() || isNumber(item.atom)) {
+				break
+			}
+			depth, err := e.resolveLabel(item)
+			if err != nil {
+				return nil, 0, err
+			}
+			depths = append(depths, depth)
+		}
+		if len(depths) == 0 {
+			return nil, 0, n.errorf("br_table requires at least one label")
+		}
+		out := appendU32(nil, uint32(len(depths)-1))
+		for _, depth := range depths {
+			out = appendU32(out, depth)
+		}
+		return out, len(depths), nil
+
+	case immFunc:
+		arg, err := next()
+		if err != nil {
+			return nil, 0, err
+		}
+		index, err := e.m.funcs.resolve(arg)
+		if err != nil {
+			return nil, 0, err
+		}
+		return appendU32(nil, index), 1, nil
+
+	case immCallIndirect:
+		consumed := 0
+		tableIndex := uint32(0)
+		if len(items) > 0 && items[0].isAtom() {
+			var err error
+			tableIndex, err = e.m.tables.resolve(items[0])
+			if err != nil {
+				return nil, 0, err
+			}
+			consumed++
+		}
+		typeIndex, _, rest, err := e.m.typeUse(items[consumed:])
+		if err != nil {
+			return nil, 0, err
+		}
+		consumed = len(items) - len(rest)
+		return appendU32(appendU32(nil, typeIndex), tableIndex), consumed, nil
+
+	case immLocal:
+		arg, err := next()
+		if err != nil {
+			return nil, 0, err
+		}
+		index, err := e.locals.resolve(arg)
+		if err != nil {
+			return nil, 0, err
+		}
+		return appendU32(nil, index), 1, nil
+
+	case immGlobal:
+		arg, err := next()
+		if err != nil {
+			return nil, 0, err
+		}
+		index, err := e.m.globals.resolve(arg)
+		if err != nil {
+			return nil, 0, err
+		}
+		return appendU32(nil, index), 1, nil
+
+	case immMemArg:
+		align := o.align
+		offset := uint64(0)
+		consumed := 0
+		for _, item := range items {
+			if !item.isAtom() {
+				break
+			}
+			if v, ok := strings.CutPrefix(item.atom, "offset="); ok {
+				parsed, err := parseUint(v, 32)
+				if err != nil {
+					return nil, 0, item.errorf("invalid offset %q", v)
+				}
+				offset = parsed
+			} else if v, ok := strings.CutPrefix(item.atom, "align="); ok {
+				parsed, err := parseUint(v, 32)
+				if err != nil || parsed == 0 || parsed&(parsed-1) != 0 {
+					return nil, 0, item.errorf("invalid alignment %q", v)
+				}
+				align = uint32(bits.TrailingZeros64(parsed))
+			} else {
+				break
+			}
+			consumed++
+		}
+		return appendU32(appendU32(nil, align), uint32(offset)), consumed, nil
+
+	case immMemIndex:
+		return []byte{0x00}, 0, nil
+
+	case immMemCopy:
+		return []byte{0x00, 0x00}, 0, nil
+
+	case immData:
+		arg, err := next()
+		if err != nil {
+			return nil, 0, err
+		}
+		index, err := e.m.datas.resolve(arg)
+		if err != nil {
+			return nil, 0, err
+		}
+		e.m.usesDataCount = true
+		out := appendU32(nil, index)
+		if o.code == 8 {
+			// memory.init is followed by the memory index.
+			out = append(out, 0x00)
+		}
+		return out, 1, nil
+
+	case immI32, immI64:
+		arg, err := next()
+		if err != nil {
+			return nil, 0, err
+		}
+		width := 32
+		if o.imm == immI64 {
+			width = 64
+		}
+		v, err := parseInt(arg.atom, width)
+		if err != nil {
+			return nil, 0, arg.errorf("%s", err.Error())
+		}
+		return appendS64(nil, v), 1, nil
+
+	case immF32, immF64:
+		arg, err := next()
+		if err != nil {
+			return nil, 0, err
+		}
+		width := 32
+		if o.imm == immF64 {
+			width = 64
+		}
+		encoded, err := parseFloat(arg.atom, width)
+		if err != nil {
+			return nil, 0, arg.errorf("%s", err.Error())
+		}
+		return encoded, 1, nil
+
+	case immSelect:
+		if len(items) > 0 && items[0].keyword() == "result" {
+			t, _, rest, err := parseSignature(items)
+			if err != nil {
+				return nil, 0, err
+			}
+			out := appendU32(nil, uint32(len(t.results)))
+			out = append(out, t.results...)
+			return out, len(items) - len(rest), nil
+		}
+		return nil, 0, nil
+
+	case immRefType:
+		arg, err := next()
+		if err != nil {
+			return nil, 0, err
+		}
+		refType, ok := refTypes[arg.atom]
+		if !ok {
+			return nil, 0, arg.errorf("invalid reference type %q", arg.atom)
+		}
+		return []byte{refType}, 1, nil
+	}
+
+	return nil, 0, n.errorf("unsupported instruction %s", describe(n))
+}
+
+// resolveLabel returns the relative depth of the given label reference.
+func (e *funcEncoder) resolveLabel(n *node) (uint32, error) {
+	if n.isID() {
+		for i := len(e.labels) - 1; i >= 0; i-- {
+			if e.labels[i] == n.atom {
+				return uint32(len(e.labels) - 1 - i), nil
+			}
+		}
+		return 0, n.errorf("unknown label %s", n.atom)
+	}
+	depth, err := parseUint(n.atom, 32)
+	if err != nil {
+		return 0, n.errorf("invalid label %q", n.atom)
+	}
+	return uint32(depth), nil
+}
@@ -0,0 +1,976 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package wat
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Export and import kinds, as encoded in the binary format.
+const (
+	kindFunc   byte = 0x00
+	kindTable  byte = 0x01
+	kindMemory byte = 0x02
+	kindGlobal byte = 0x03
+)
+
+const pageSize = 65536
+
+type funcType struct {
+	params  []byte
+	results []byte
+}
+
+func (t funcType) equals(other funcType) bool {
+	return bytes.Equal(t.params, other.params) && bytes.Equal(t.results, other.results)
+}
+
+func (t funcType) encode() []byte {
+	b := []byte{0x60}
+	b = appendU32(b, uint32(len(t.params)))
+	b = append(b, t.params...)
+	b = appendU32(b, uint32(len(t.results)))
+	return append(b, t.results...)
+}
+
+// namespace maps the `$names` of a single index space to their indices.
+type namespace struct {
+	kind  string
+	names map[string]uint32
+	count uint32
+}
+
+func newNamespace(kind string) *namespace {
+	return &namespace{kind: kind, names: map[string]uint32{}}
+}
+
+// add allocates the next index in the namespace, binding it to the given name if there is one.
+func (ns *namespace) add(n *node, name string) (uint32, error) {
+	index := ns.count
+	ns.count++
+	if name != "" {
+		if _, ok := ns.names[name]; ok {
+			return 0, n.errorf("duplicate %s %s", ns.kind, name)
+		}
+		ns.names[name] = index
+	}
+	return index, nil
+}
+
+// resolve returns the index referenced by the given node, which may be a `$name` or a number.
+func (ns *namespace) resolve(n *node) (uint32, error) {
+	if n == nil || !n.isAtom() {
+		return 0, fmt.Errorf("wat: expected %s index", ns.kind)
+	}
+	if n.isID() {
+		index, ok := ns.names[n.atom]
+		if !ok {
+			return 0, n.errorf("unknown %s %s", ns.kind, n.atom)
+		}
+		return index, nil
+	}
+	index, err := parseUint(n.atom, 32)
+	if err != nil {
+		return 0, n.errorf("invalid %s index %q", ns.kind, n.atom)
+	}
+	return uint32(index), nil
+}
+
+type funcDef struct {
+	node       *node
+	typeIndex  uint32
+	paramNames []string
+	body       []*node
+}
+
+type globalDef struct {
+	node    *node
+	typ     []byte
+	initial []*node
+}
+
+type dataDef struct {
+	node    *node
+	encoded []byte
+}
+
+type exportDef struct {
+	name []byte
+	kind byte
+	ref  *node
+}
+
+type moduleBuilder struct {
+	types     []funcType
+	typeNames *namespace
+
+	funcs    *namespace
+	tables   *namespace
+	memories *namespace
+	globals  *namespace
+	datas    *namespace
+
+	imports     []byte
+	importCount uint32
+
+	funcDefs   []*funcDef
+	tableDefs  [][]byte
+	memoryDefs [][]byte
+	globalDefs []*globalDef
+	exports    []exportDef
+	start      *node
+	elems      [][]*node
+	// dataDefs holds the data segments in index order, inline segments declared by a memory
+	// abbreviation are already encoded, the rest are encoded once all names are known.
+	dataDefs []dataDef
+
+	// usesDataCount is true if any instruction references a data segment, which requires
+	// the data count section to be present.
+	usesDataCount bool
+}
+
+func newModuleBuilder() *moduleBuilder {
+	return &moduleBuilder{
+		typeNames: newNamespace("type"),
+		funcs:     newNamespace("func"),
+		tables:    newNamespace("table"),
+		memories:  newNamespace("memory"),
+		globals:   newNamespace("global"),
+		datas:     newNamespace("data"),
+	}
+}
+
+// build declares all of the given module fields.
+//
+// It is done in multiple passes as imports must occupy the lowest indices of each index space,
+// and explicitly declared types the lowest indices of the type space, regardless of where they
+// are declared.
+func (m *moduleBuilder) build(fields []*node) error {
+	for _, field := range fields {
+		if !field.isList() {
+			return field.errorf("expected module field")
+		}
+		if field.keyword() == "type" {
+			err := m.declareType(field)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, field := range fields {
+		if field.keyword() == "import" || inlineImport(field) != nil {
+			err := m.declareImport(field)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, field := range fields {
+		if inlineImport(field) != nil {
+			continue
+		}
+
+		var err error
+		switch field.keyword() {
+		case "type", "import":
+			// Already declared.
+		case "func":
+			err = m.declareFunc(field)
+		case "table":
+			err = m.declareTable(field)
+		case "memory":
+			err = m.declareMemory(field)
+		case "global":
+			err = m.declareGlobal(field)
+		case "export":
+			err = m.declareExport(field)
+		case "start":
+			if len(field.list) != 2 {
+				return field.errorf("invalid start")
+			}
+			m.start = field.list[1]
+		case "elem":
+			m.elems = append(m.elems, field.list[1:])
+		case "data":
+			err = m.declareData(field)
+		default:
+			err = field.errorf("unsupported module field %q", field.keyword())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inlineImport returns the `(import "module" "name")` abbreviation within the given field,
+// or nil if there is none.
+func inlineImport(field *node) *node {
+	switch field.keyword() {
+	case "func", "table", "memory", "global":
+	default:
+		return nil
+	}
+	for _, item := range field.list[1:] {
+		if item.keyword() == "import" {
+			return item
+		}
+		if item.isList() && item.keyword() != "export" {
+			return nil
+		}
+	}
+	return nil
+}
+
+// fieldHeader returns the optional `$name` and any inline exports of the given field, along with
+// the remaining items.
+func (m *moduleBuilder) fieldHeader(field *node) (string, []*node, []*node) {
+	items := field.list[1:]
+	name := ""
+	if len(items) > 0 && items[0].isID() {
+		name = items[0].atom
+		items = items[1:]
+	}
+	exports := []*node{}
+	for len(items) > 0 && (items[0].keyword() == "export" || items[0].keyword() == "import") {
+		if items[0].keyword() == "export" {
+			exports = append(exports, items[0])
+		}
+		items = items[1:]
+	}
+	return name, exports, items
+}
+
+func (m *moduleBuilder) addInlineExports(exports []*node, kind byte, index uint32) error {
+	for _, export := range exports {
+		if len(export.list) != 2 || !export.list[1].isString {
+			return export.errorf("invalid export")
+		}
+		m.exports = append(m.exports, exportDef{
+			name: export.list[1].str,
+			kind: kind,
+			ref:  &node{atom: fmt.Sprint(index), line: export.line, col: export.col},
+		})
+	}
+	return nil
+}
+
+func (m *moduleBuilder) declareType(field *node) error {
+	items := field.list[1:]
+	name := ""
+	if len(items) > 0 && items[0].isID() {
+		name = items[0].atom
+		items = items[1:]
+	}
+	if len(items) != 1 || items[0].keyword() != "func" {
+		return field.errorf("invalid type")
+	}
+	t, _, rest, err := parseSignature(items[0].list[1:])
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return rest[0].errorf("unexpected %s in type", describe(rest[0]))
+	}
+	_, err = m.typeNames.add(field, name)
+	if err != nil {
+		return err
+	}
+	m.types = append(m.types, t)
+	return nil
+}
+
+// parseSignature parses any leading `(param ...)` and `(result ...)` items, returning the
+// resultant type, param names, and any remaining items.
+func parseSignature(items []*node) (funcType, []string, []*node, error) {
+	t := funcType{params: []byte{}, results: []byte{}}
+	names := []string{}
+
+	for len(items) > 0 && items[0].keyword() == "param" {
+		param := items[0].list[1:]
+		if len(param) == 2 && param[0].isID() {
+			valueType, err := parseValueType(param[1])
+			if err != nil {
+				return funcType{}, nil, nil, err
+			}
+			t.params = append(t.params, valueType)
+			names = append(names, param[0].atom)
+		} else {
+			for _, p := range param {
+				valueType, err := parseValueType(p)
+				if err != nil {
+					return funcType{}, nil, nil, err
+				}
+				t.params = append(t.params, valueType)
+				names = append(names, "")
+			}
+		}
+		items = items[1:]
+	}
+
+	for len(items) > 0 && items[0].keyword() == "result" {
+		for _, r := range items[0].list[1:] {
+			valueType, err := parseValueType(r)
+			if err != nil {
+				return funcType{}, nil, nil, err
+			}
+			t.results = append(t.results, valueType)
+		}
+		items = items[1:]
+	}
+
+	return t, names, items, nil
+}
+
+func parseValueType(n *node) (byte, error) {
+	if n.isAtom() {
+		if valueType, ok := valueTypes[n.atom]; ok {
+			return valueType, nil
+		}
+	}
+	return 0, n.errorf("invalid value type %s", describe(n))
+}
+
+// typeUse parses a type use, which may be an explicit `(type $t)` reference, an inline
+// signature, or both. It returns the type index, param names and any remaining items.
+func (m *moduleBuilder) typeUse(items []*node) (uint32, []string, []*node, error) {
+	var explicit *node
+	if len(items) > 0 && items[0].keyword() == "type" {
+		if len(items[0].list) != 2 {
+			return 0, nil, nil, items[0].errorf("invalid type use")
+		}
+		explicit = items[0].list[1]
+		items = items[1:]
+	}
+
+	t, names, rest, err := parseSignature(items)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	if explicit != nil {
+		index, err := m.typeNames.resolve(explicit)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		if int(index) >= len(m.types) {
+			return 0, nil, nil, explicit.errorf("unknown type %s", explicit.atom)
+		}
+		if len(rest) != len(items) && !t.equals(m.types[index]) {
+			return 0, nil, nil, explicit.errorf("inline signature does not match type %s", explicit.atom)
+		}
+		if len(names) == 0 {
+			names = make([]string, len(m.types[index].params))
+		}
+		return index, names, rest, nil
+	}
+
+	return m.findOrAddType(t), names, rest, nil
+}
+
+// findOrAddType returns the index of the first type matching the given signature, adding it
+// if there is none.
+func (m *moduleBuilder) findOrAddType(t funcType) uint32 {
+	for i, existing := range m.types {
+		if existing.equals(t) {
+			return uint32(i)
+		}
+	}
+	m.types = append(m.types, t)
+	m.typeNames.count++
+	return uint32(len(m.types) - 1)
+}
+
+func (m *moduleBuilder) declareImport(field *node) error {
+	var moduleName, name []byte
+	var desc *node
+
+	if field.keyword() == "import" {
+		if len(field.list) != 4 || !field.list[1].isString || !field.list[2].isString || !field.list[3].isList() {
+			return field.errorf("invalid import")
+		}
+		moduleName = field.list[1].str
+		name = field.list[2].str
+		desc = field.list[3]
+	} else {
+		// Convert the abbreviated `(func $f (import "m" "n") ...)` form into the equivalent
+		// `(func $f ...)` descriptor, noting any inline exports.
+		imp := inlineImport(field)
+		if len(imp.list) != 3 || !imp.list[1].isString || !imp.list[2].isString {
+			return imp.errorf("invalid import")
+		}
+		moduleName = imp.list[1].str
+		name = imp.list[2].str
+
+		desc = &node{isListed: true, line: field.line, col: field.col}
+		for _, item := range field.list {
+			if item != imp && item.keyword() != "export" {
+				desc.list = append(desc.list, item)
+			}
+		}
+	}
+
+	descName, exports, items := m.fieldHeader(desc)
+	if field.keyword() != "import" {
+		// Inline exports were excluded from the descriptor, so they need to be re-read from the field.
+		_, exports, _ = m.fieldHeader(field)
+	}
+
+	var kind byte
+	var encoded []byte
+	var index uint32
+	var err error
+
+	switch desc.keyword() {
+	case "func":
+		kind = kindFunc
+		var typeIndex uint32
+		var rest []*node
+		typeIndex, _, rest, err = m.typeUse(items)
+		if err != nil {
+			return err
+		}
+		if len(rest) != 0 {
+			return rest[0].errorf("unexpected %s in imported func", describe(rest[0]))
+		}
+		encoded = appendU32(nil, typeIndex)
+		index, err = m.funcs.add(desc, descName)
+
+	case "table":
+		kind = kindTable
+		encoded, err = parseTableType(desc, items)
+		if err != nil {
+			return err
+		}
+		index, err = m.tables.add(desc, descName)
+
+	case "memory":
+		kind = kindMemory
+		encoded, err = parseLimits(desc, items)
+		if err != nil {
+			return err
+		}
+		index, err = m.memories.add(desc, descName)
+
+	case "global":
+		kind = kindGlobal
+		if len(items) != 1 {
+			return desc.errorf("invalid imported global")
+		}
+		encoded, err = parseGlobalType(items[0])
+		if err != nil {
+			return err
+		}
+		index, err = m.globals.add(desc, descName)
+
+	default:
+		return desc.errorf("unsupported import kind %q", desc.keyword())
+	}
+	if err != nil {
+		return err
+	}
+
+	m.imports = appendName(m.imports, moduleName)
+	m.imports = appendName(m.imports, name)
+	m.imports = append(m.imports, kind)
+	m.imports = append(m.imports, encoded...)
+	m.importCount++
+
+	return m.addInlineExports(exports, kind, index)
+}
+
+func (m *moduleBuilder) declareFunc(field *node) error {
+	name, exports, items := m.fieldHeader(field)
+
+	typeIndex, paramNames, body, err := m.typeUse(items)
+	if err != nil {
+		return err
+	}
+
+	index, err := m.funcs.add(field, name)
+	if err != nil {
+		return err
+	}
+
+	m.funcDefs = append(m.funcDefs, &funcDef{
+		node:       field,
+		typeIndex:  typeIndex,
+		paramNames: paramNames,
+		body:       body,
+	})
+
+	return m.addInlineExports(exports, kindFunc, index)
+}
+
+func (m *moduleBuilder) declareTable(field *node) error {
+	name, exports, items := m.fieldHeader(field)
+
+	index, err := m.tables.add(field, name)
+	if err != nil {
+		return err
+	}
+
+	// The `(table funcref (elem $f...))` abbreviation declares a table exactly large enough
+	// to hold the given functions.
+	if len(items) == 2 && items[1].keyword() == "elem" {
+		refType, err := parseValueType(items[0])
+		if err != nil {
+			return err
+		}
+		funcRefs := items[1].list[1:]
+
+		table := []byte{refType, 0x01}
+		table = appendU32(table, uint32(len(funcRefs)))
+		table = appendU32(table, uint32(len(funcRefs)))
+		m.tableDefs = append(m.tableDefs, table)
+
+		offset := &node{isListed: true, list: []*node{{atom: "i32.const"}, {atom: "0"}}}
+		elem := []*node{{isListed: true, list: []*node{{atom: "table"}, {atom: fmt.Sprint(index)}}}, offset}
+		m.elems = append(m.elems, append(elem, funcRefs...))
+	} else {
+		table, err := parseTableType(field, items)
+		if err != nil {
+			return err
+		}
+		m.tableDefs = append(m.tableDefs, table)
+	}
+
+	return m.addInlineExports(exports, kindTable, index)
+}
+
+func parseTableType(field *node, items []*node) ([]byte, error) {
+	if len(items) == 0 {
+		return nil, field.errorf("invalid table")
+	}
+	refType, err := parseValueType(items[len(items)-1])
+	if err != nil {
+		return nil, err
+	}
+	limits, err := parseLimits(field, items[:len(items)-1])
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{refType}, limits...), nil
+}
+
+func parseLimits(field *node, items []*node) ([]byte, error) {
+	values := []uint32{}
+	for _, item := range items {
+		if !item.isAtom() {
+			return nil, item.errorf("invalid limits")
+		}
+		v, err := parseUint(item.atom, 32)
+		if err != nil {
+			return nil, item.errorf("invalid limit %q", item.atom)
+		}
+		values = append(values, uint32(v))
+	}
+
+	switch len(values) {
+	case 1:
+		return appendU32([]byte{0x00}, values[0]), nil
+	case 2:
+		return appendU32(appendU32([]byte{0x01}, values[0]), values[1]), nil
+	default:
+		return nil, field.errorf("invalid limits")
+	}
+}
+
+func (m *moduleBuilder) declareMemory(field *node) error {
+	name, exports, items := m.fieldHeader(field)
+
+	index, err := m.memories.add(field, name)
+	if err != nil {
+		return err
+	}
+
+	// The `(memory (data "..."))` abbreviation declares a memory exactly large enough to hold
+	// the given data.
+	if len(items) == 1 && items[0].keyword() == "data" {
+		var data []byte
+		for _, str := range items[0].list[1:] {
+			if !str.isString {
+				return str.errorf("expected string")
+			}
+			data = append(data, str.str...)
+		}
+		pages := uint32((len(data) + pageSize - 1) / pageSize)
+		m.memoryDefs = append(m.memoryDefs, appendU32(appendU32([]byte{0x01}, pages), pages))
+
+		segment := []byte{0x00}
+		if index != 0 {
+			segment = appendU32([]byte{0x02}, index)
+		}
+		segment = append(segment, 0x41, 0x00, 0x0B)
+		segment = appendName(segment, data)
+		m.dataDefs = append(m.dataDefs, dataDef{encoded: segment})
+		m.datas.count++
+	} else {
+		limits, err := parseLimits(field, items)
+		if err != nil {
+			return err
+		}
+		m.memoryDefs = append(m.memoryDefs, limits)
+	}
+
+	return m.addInlineExports(exports, kindMemory, index)
+}
+
+func parseGlobalType(n *node) ([]byte, error) {
+	if n.keyword() == "mut" && len(n.list) == 2 {
+		valueType, err := parseValueType(n.list[1])
+		if err != nil {
+			return nil, err
+		}
+		return []byte{valueType, 0x01}, nil
+	}
+	valueType, err := parseValueType(n)
+	if err != nil {
+		return nil, err
+	}
+	return []byte{valueType, 0x00}, nil
+}
+
+func (m *moduleBuilder) declareGlobal(field *node) error {
+	name, exports, items := m.fieldHeader(field)
+	if len(items) < 1 {
+		return field.errorf("invalid global")
+	}
+
+	typ, err := parseGlobalType(items[0])
+	if err != nil {
+		return err
+	}
+
+	index, err := m.globals.add(field, name)
+	if err != nil {
+		return err
+	}
+
+	m.globalDefs = append(m.globalDefs, &globalDef{
+		node:    field,
+		typ:     typ,
+		initial: items[1:],
+	})
+
+	return m.addInlineExports(exports, kindGlobal, index)
+}
+
+func (m *moduleBuilder) declareExport(field *node) error {
+	if len(field.list) != 3 || !field.list[1].isString || len(field.list[2].list) != 2 {
+		return field.errorf("invalid export")
+	}
+
+	var kind byte
+	switch field.list[2].keyword() {
+	case "func":
+		kind = kindFunc
+	case "table":
+		kind = kindTable
+	case "memory":
+		kind = kindMemory
+	case "global":
+		kind = kindGlobal
+	default:
+		return field.errorf("unsupported export kind %q", field.list[2].keyword())
+	}
+
+	m.exports = append(m.exports, exportDef{
+		name: field.list[1].str,
+		kind: kind,
+		ref:  field.list[2].list[1],
+	})
+	return nil
+}
+
+func (m *moduleBuilder) declareData(field *node) error {
+	items := field.list[1:]
+	name := ""
+	if len(items) > 0 && items[0].isID() {
+		name = items[0].atom
+	}
+	_, err := m.datas.add(field, name)
+	if err != nil {
+		return err
+	}
+	m.dataDefs = append(m.dataDefs, dataDef{node: field})
+	return nil
+}
+
+// encode returns the binary encoding of the declared module.
+func (m *moduleBuilder) encode() ([]byte, error) {
+	// The function bodies must be encoded before the type section, as block types may
+	// declare new types.
+	code := appendU32(nil, uint32(len(m.funcDefs)))
+	for _, f := range m.funcDefs {
+		body, err := m.encodeFunc(f)
+		if err != nil {
+			return nil, err
+		}
+		code = appendName(code, body)
+	}
+
+	var globals []byte
+	if len(m.globalDefs) > 0 {
+		globals = appendU32(nil, uint32(len(m.globalDefs)))
+		for _, g := range m.globalDefs {
+			globals = append(globals, g.typ...)
+			init, err := m.encodeConstExpr(g.node, g.initial)
+			if err != nil {
+				return nil, err
+			}
+			globals = append(globals, init...)
+		}
+	}
+
+	var exports []byte
+	if len(m.exports) > 0 {
+		exports = appendU32(nil, uint32(len(m.exports)))
+		for _, e := range m.exports {
+			var ns *namespace
+			switch e.kind {
+			case kindFunc:
+				ns = m.funcs
+			case kindTable:
+				ns = m.tables
+			case kindMemory:
+				ns = m.memories
+			case kindGlobal:
+				ns = m.globals
+			}
+			index, err := ns.resolve(e.ref)
+			if err != nil {
+				return nil, err
+			}
+			exports = appendName(exports, e.name)
+			exports = append(exports, e.kind)
+			exports = appendU32(exports, index)
+		}
+	}
+
+	var start []byte
+	if m.start != nil {
+		index, err := m.funcs.resolve(m.start)
+		if err != nil {
+			return nil, err
+		}
+		start = appendU32(nil, index)
+	}
+
+	var elems []byte
+	if len(m.elems) > 0 {
+		elems = appendU32(nil, uint32(len(m.elems)))
+		for _, items := range m.elems {
+			segment, err := m.encodeElem(items)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, segment...)
+		}
+	}
+
+	var datas []byte
+	if len(m.dataDefs) > 0 {
+		datas = appendU32(nil, uint32(len(m.dataDefs)))
+		for _, d := range m.dataDefs {
+			segment := d.encoded
+			if segment == nil {
+				var err error
+				segment, err = m.encodeData(d.node)
+				if err != nil {
+					return nil, err
+				}
+			}
+			datas = append(datas, segment...)
+		}
+	}
+
+	var types []byte
+	if len(m.types) > 0 {
+		types = appendU32(nil, uint32(len(m.types)))
+		for _, t := range m.types {
+			types = append(types, t.encode()...)
+		}
+	}
+
+	var imports []byte
+	if m.importCount > 0 {
+		imports = append(appendU32(nil, m.importCount), m.imports...)
+	}
+
+	var funcs []byte
+	if len(m.funcDefs) > 0 {
+		funcs = appendU32(nil, uint32(len(m.funcDefs)))
+		for _, f := range m.funcDefs {
+			funcs = appendU32(funcs, f.typeIndex)
+		}
+	}
+
+	out := append([]byte{}, wasmMagic...)
+	out = append(out, 0x01, 0x00, 0x00, 0x00)
+	out = appendSection(out, 1, types)
+	out = appendSection(out, 2, imports)
+	out = appendSection(out, 3, funcs)
+	out = appendSection(out, 4, encodeVec(m.tableDefs))
+	out = appendSection(out, 5, encodeVec(m.memoryDefs))
+	out = appendSection(out, 6, globals)
+	out = appendSection(out, 7, exports)
+	out = appendSection(out, 8, start)
+	out = appendSection(out, 9, elems)
+	if m.usesDataCount && datas != nil {
+		out = appendSection(out, 12, appendU32(nil, uint32(len(m.dataDefs))))
+	}
+	if len(m.funcDefs) > 0 {
+		out = appendSection(out, 10, code)
+	}
+	out = appendSection(out, 11, datas)
+	return out, nil
+}
+
+func encodeVec(items [][]byte) []byte {
+	if len(items) == 0 {
+		return nil
+	}
+	out := appendU32(nil, uint32(len(items)))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+// encodeConstExpr encodes the given constant expression, such as a global initializer or
+// segment offset.
+func (m *moduleBuilder) encodeConstExpr(parent *node, items []*node) ([]byte, error) {
+	if len(items) == 0 {
+		return nil, parent.errorf("missing constant expression")
+	}
+	e := newFuncEncoder(m, nil)
+	err := e.encodeInstrs(items)
+	if err != nil {
+		return nil, err
+	}
+	return append(e.out, 0x0B), nil
+}
+
+// encodeOffset encodes an `(offset ...)` expression, or its single instruction abbreviation.
+func (m *moduleBuilder) encodeOffset(n *node) ([]byte, error) {
+	if n.keyword() == "offset" {
+		return m.encodeConstExpr(n, n.list[1:])
+	}
+	return m.encodeConstExpr(n, []*node{n})
+}
+
+func (m *moduleBuilder) encodeElem(items []*node) ([]byte, error) {
+	if len(items) > 0 && items[0].isID() {
+		items = items[1:]
+	}
+
+	tableIndex := uint32(0)
+	if len(items) > 0 && items[0].keyword() == "table" {
+		if len(items[0].list) != 2 {
+			return nil, items[0].errorf("invalid elem table")
+		}
+		var err error
+		tableIndex, err = m.tables.resolve(items[0].list[1])
+		if err != nil {
+			return nil, err
+		}
+		items = items[1:]
+	}
+
+	if len(items) == 0 || !items[0].isList() {
+		return nil, fmt.Errorf("wat: only active elem segments are supported")
+	}
+	offset, err := m.encodeOffset(items[0])
+	if err != nil {
+		return nil, err
+	}
+	items = items[1:]
+	if len(items) > 0 && items[0].isAtom() && items[0].atom == "func" {
+		items = items[1:]
+	}
+
+	var segment []byte
+	if tableIndex == 0 {
+		segment = []byte{0x00}
+		segment = append(segment, offset...)
+	} else {
+		segment = appendU32([]byte{0x02}, tableIndex)
+		segment = append(segment, offset...)
+		// elemkind funcref
+		segment = append(segment, 0x00)
+	}
+	segment = appendU32(segment, uint32(len(items)))
+	for _, item := range items {
+		index, err := m.funcs.resolve(item)
+		if err != nil {
+			return nil, err
+		}
+		segment = appendU32(segment, index)
+	}
+	return segment, nil
+}
+
+func (m *moduleBuilder) encodeData(field *node) ([]byte, error) {
+	items := field.list[1:]
+	if len(items) > 0 && items[0].isID() {
+		items = items[1:]
+	}
+
+	memoryIndex := uint32(0)
+	if len(items) > 0 && items[0].keyword() == "memory" {
+		if len(items[0].list) != 2 {
+			return nil, items[0].errorf("invalid data memory")
+		}
+		var err error
+		memoryIndex, err = m.memories.resolve(items[0].list[1])
+		if err != nil {
+			return nil, err
+		}
+		items = items[1:]
+	}
+
+	var segment []byte
+	if len(items) > 0 && items[0].isList() {
+		offset, err := m.encodeOffset(items[0])
+		if err != nil {
+			return nil, err
+		}
+		items = items[1:]
+		if memoryIndex == 0 {
+			segment = []byte{0x00}
+		} else {
+			segment = appendU32([]byte{0x02}, memoryIndex)
+		}
+		segment = append(segment, offset...)
+	} else {
+		// Segments without an offset are passive.
+		segment = []byte{0x01}
+	}
+
+	var data []byte
+	for _, item := range items {
+		if !item.isString {
+			return nil, item.errorf("expected string")
+		}
+		data = append(data, item.str...)
+	}
+	return appendName(segment, data), nil
+}
+
+func describe(n *node) string {
+	switch {
+	case n.isList():
+		return "(" + n.keyword() + " ...)"
+	case n.isString:
+		return fmt.Sprintf("%q", n.str)
+	default:
+		return strings.TrimSpace(n.atom)
+	}
+}
@@ -0,0 +1,162 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package wat
+
+// immediate describes the immediate arguments that follow an instruction's opcode.
+type immediate int
+
+const (
+	immNone immediate = iota
+	immBlock
+	immLabel
+	immBrTable
+	immFunc
+	immCallIndirect
+	immLocal
+	immGlobal
+	immMemArg
+	immMemIndex
+	immMemCopy
+	immData
+	immI32
+	immI64
+	immF32
+	immF64
+	immSelect
+	immRefType
+)
+
+type op struct {
+	// prefix is the opcode prefix byte, or zero if the instruction is not prefixed.
+	prefix byte
+	code   uint32
+	imm    immediate
+
+	// align is the natural alignment of memory instructions, as a power of two.
+	align uint32
+}
+
+var ops = map[string]op{
+	"unreachable":   {code: 0x00},
+	"nop":           {code: 0x01},
+	"block":         {code: 0x02, imm: immBlock},
+	"loop":          {code: 0x03, imm: immBlock},
+	"if":            {code: 0x04, imm: immBlock},
+	"br":            {code: 0x0C, imm: immLabel},
+	"br_if":         {code: 0x0D, imm: immLabel},
+	"br_table":      {code: 0x0E, imm: immBrTable},
+	"return":        {code: 0x0F},
+	"call":          {code: 0x10, imm: immFunc},
+	"call_indirect": {code: 0x11, imm: immCallIndirect},
+	"drop":          {code: 0x1A},
+	"select":        {code: 0x1B, imm: immSelect},
+	"local.get":     {code: 0x20, imm: immLocal},
+	"local.set":     {code: 0x21, imm: immLocal},
+	"local.tee":     {code: 0x22, imm: immLocal},
+	"global.get":    {code: 0x23, imm: immGlobal},
+	"global.set":    {code: 0x24, imm: immGlobal},
+
+	"i32.load":     {code: 0x28, imm: immMemArg, align: 2},
+	"i64.load":     {code: 0x29, imm: immMemArg, align: 3},
+	"f32.load":     {code: 0x2A, imm: immMemArg, align: 2},
+	"f64.load":     {code: 0x2B, imm: immMemArg, align: 3},
+	"i32.load8_s":  {code: 0x2C, imm: immMemArg, align: 0},
+	"i32.load8_u":  {code: 0x2D, imm: immMemArg, align: 0},
+	"i32.load16_s": {code: 0x2E, imm: immMemArg, align: 1},
+	"i32.load16_u": {code: 0x2F, imm: immMemArg, align: 1},
+	"i64.load8_s":  {code: 0x30, imm: immMemArg, align: 0},
+	"i64.load8_u":  {code: 0x31, imm: immMemArg, align: 0},
+	"i64.load16_s": {code: 0x32, imm: immMemArg, align: 1},
+	"i64.load16_u": {code: 0x33, imm: immMemArg, align: 1},
+	"i64.load32_s": {code: 0x34, imm: immMemArg, align: 2},
+	"i64.load32_u": {code: 0x35, imm: immMemArg, align: 2},
+	"i32.store":    {code: 0x36, imm: immMemArg, align: 2},
+	"i64.store":    {code: 0x37, imm: immMemArg, align: 3},
+	"f32.store":    {code: 0x38, imm: immMemArg, align: 2},
+	"f64.store":    {code: 0x39, imm: immMemArg, align: 3},
+	"i32.store8":   {code: 0x3A, imm: immMemArg, align: 0},
+	"i32.store16":  {code: 0x3B, imm: immMemArg, align: 1},
+	"i64.store8":   {code: 0x3C, imm: immMemArg, align: 0},
+	"i64.store16":  {code: 0x3D, imm: immMemArg, align: 1},
+	"i64.store32":  {code: 0x3E, imm: immMemArg, align: 2},
+	"memory.size":  {code: 0x3F, imm: immMemIndex},
+	"memory.grow":  {code: 0x40, imm: immMemIndex},
+
+	"i32.const": {code: 0x41, imm: immI32},
+	"i64.const": {code: 0x42, imm: immI64},
+	"f32.const": {code: 0x43, imm: immF32},
+	"f64.const": {code: 0x44, imm: immF64},
+
+	"ref.null":    {code: 0xD0, imm: immRefType},
+	"ref.is_null": {code: 0xD1},
+	"ref.func":    {code: 0xD2, imm: immFunc},
+
+	"i32.trunc_sat_f32_s": {prefix: 0xFC, code: 0},
+	"i32.trunc_sat_f32_u": {prefix: 0xFC, code: 1},
+	"i32.trunc_sat_f64_s": {prefix: 0xFC, code: 2},
+	"i32.trunc_sat_f64_u": {prefix: 0xFC, code: 3},
+	"i64.trunc_sat_f32_s": {prefix: 0xFC, code: 4},
+	"i64.trunc_sat_f32_u": {prefix: 0xFC, code: 5},
+	"i64.trunc_sat_f64_s": {prefix: 0xFC, code: 6},
+	"i64.trunc_sat_f64_u": {prefix: 0xFC, code: 7},
+	"memory.init":         {prefix: 0xFC, code: 8, imm: immData},
+	"data.drop":           {prefix: 0xFC, code: 9, imm: immData},
+	"memory.copy":         {prefix: 0xFC, code: 10, imm: immMemCopy},
+	"memory.fill":         {prefix: 0xFC, code: 11, imm: immMemIndex},
+}
+
+func init() {
+	// The numeric instructions have no immediates and are contiguous blocks of opcodes, so they
+	// are declared here in opcode order instead of individually.
+	numeric := []struct {
+		start uint32
+		names []string
+	}{
+		{0x45, []string{"i32.eqz", "i32.eq", "i32.ne", "i32.lt_s", "i32.lt_u", "i32.gt_s", "i32.gt_u",
+			"i32.le_s", "i32.le_u", "i32.ge_s", "i32.ge_u"}},
+		{0x50, []string{"i64.eqz", "i64.eq", "i64.ne", "i64.lt_s", "i64.lt_u", "i64.gt_s", "i64.gt_u",
+			"i64.le_s", "i64.le_u", "i64.ge_s", "i64.ge_u"}},
+		{0x5B, []string{"f32.eq", "f32.ne", "f32.lt", "f32.gt", "f32.le", "f32.ge"}},
+		{0x61, []string{"f64.eq", "f64.ne", "f64.lt", "f64.gt", "f64.le", "f64.ge"}},
+		{0x67, []string{"i32.clz", "i32.ctz", "i32.popcnt", "i32.add", "i32.sub", "i32.mul", "i32.div_s",
+			"i32.div_u", "i32.rem_s", "i32.rem_u", "i32.and", "i32.or", "i32.xor", "i32.shl", "i32.shr_s",
+			"i32.shr_u", "i32.rotl", "i32.rotr"}},
+		{0x79, []string{"i64.clz", "i64.ctz", "i64.popcnt", "i64.add", "i64.sub", "i64.mul", "i64.div_s",
+			"i64.div_u", "i64.rem_s", "i64.rem_u", "i64.and", "i64.or", "i64.xor", "i64.shl", "i64.shr_s",
+			"i64.shr_u", "i64.rotl", "i64.rotr"}},
+		{0x8B, []string{"f32.abs", "f32.neg", "f32.ceil", "f32.floor", "f32.trunc", "f32.nearest",
+			"f32.sqrt", "f32.add", "f32.sub", "f32.mul", "f32.div", "f32.min", "f32.max", "f32.copysign"}},
+		{0x99, []string{"f64.abs", "f64.neg", "f64.ceil", "f64.floor", "f64.trunc", "f64.nearest",
+			"f64.sqrt", "f64.add", "f64.sub", "f64.mul", "f64.div", "f64.min", "f64.max", "f64.copysign"}},
+		{0xA7, []string{"i32.wrap_i64", "i32.trunc_f32_s", "i32.trunc_f32_u", "i32.trunc_f64_s",
+			"i32.trunc_f64_u", "i64.extend_i32_s", "i64.extend_i32_u", "i64.trunc_f32_s", "i64.trunc_f32_u",
+			"i64.trunc_f64_s", "i64.trunc_f64_u", "f32.convert_i32_s", "f32.convert_i32_u",
+			"f32.convert_i64_s", "f32.convert_i64_u", "f32.demote_f64", "f64.convert_i32_s",
+			"f64.convert_i32_u", "f64.convert_i64_s", "f64.convert_i64_u", "f64.promote_f32",
+			"i32.reinterpret_f32", "i64.reinterpret_f64", "f32.reinterpret_i32", "f64.reinterpret_i64",
+			"i32.extend8_s", "i32.extend16_s", "i64.extend8_s", "i64.extend16_s", "i64.extend32_s"}},
+	}
+	for _, block := range numeric {
+		for i, name := range block.names {
+			ops[name] = op{code: block.start + uint32(i)}
+		}
+	}
+}
+
+// valueTypes maps the text form of each supported value type to its binary encoding.
+var valueTypes = map[string]byte{
+	"i32":       0x7F,
+	"i64":       0x7E,
+	"f32":       0x7D,
+	"f64":       0x7C,
+	"funcref":   0x70,
+	"externref": 0x6F,
+}
+
+// refTypes maps the heap types accepted by `ref.null` to their binary encoding.
+var refTypes = map[string]byte{
+	"func":   0x70,
+	"extern": 0x6F,
+}
@@ -0,0 +1,341 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+/*
+The wat package contains a pure-Go converter from the WebAssembly text format (WAT) to the
+binary format, for use by runtimes that do not provide their own.
+
+It supports the subset of the text format required by lens modules - the MVP instruction set,
+plus sign-extension, saturating truncation and bulk memory instructions, in both the flat and
+folded forms. SIMD, threads, exceptions and multiple tables are not supported.
+*/
+package wat
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// wasmMagic is the magic number that every binary wasm module begins with.
+var wasmMagic = []byte("\x00asm")
+
+// byteOrderMark is the UTF-8 encoded byte order mark that text modules may begin with.
+const byteOrderMark = "\ufeff"
+
+// IsText returns true if the given module content is in the text format, rather than being a
+// wasm binary.
+func IsText(content []byte) bool {
+	if bytes.HasPrefix(content, wasmMagic) || !utf8.Valid(content) {
+		return false
+	}
+	// Every text module begins with an s-expression or a comment, leading whitespace and a byte
+	// order mark are permitted.
+	trimmed := bytes.TrimLeft(TrimBOM(content), " \t\r\n")
+	return bytes.HasPrefix(trimmed, []byte("(")) || bytes.HasPrefix(trimmed, []byte(";;"))
+}
+
+// TrimBOM returns the given text module content without its leading byte order mark, if any, which
+// is not part of the text format and so must be removed before the content is converted.
+func TrimBOM(content []byte) []byte {
+	return bytes.TrimPrefix(content, []byte(byteOrderMark))
+}
+
+// Wat2Wasm converts the given WebAssembly text format module into the binary format.
+func Wat2Wasm(wat string) ([]byte, error) {
+	root, err := parse(wat)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := root
+	// The `(module ...)` wrapper is optional, a sequence of module fields is also a valid module.
+	if len(root) == 1 && root[0].isList() && root[0].keyword() == "module" {
+		fields = root[0].list[1:]
+		// Skip the optional module name.
+		if len(fields) > 0 && fields[0].isID() {
+			fields = fields[1:]
+		}
+	}
+
+	m := newModuleBuilder()
+	err = m.build(fields)
+	if err != nil {
+		return nil, err
+	}
+	return m.encode()
+}
+
+// node is a single element of an s-expression.
+//
+// It is either a list of child nodes, a string literal, or any other atom (keyword, id or number).
+type node struct {
+	list     []*node
+	isListed bool
+	atom     string
+	isString bool
+	str      []byte
+
+	line int
+	col  int
+}
+
+func (n *node) isList() bool {
+	return n.isListed
+}
+
+func (n *node) isAtom() bool {
+	return !n.isListed && !n.isString
+}
+
+func (n *node) isID() bool {
+	return n.isAtom() && len(n.atom) > 1 && n.atom[0] == '$'
+}
+
+// keyword returns the leading keyword of a list node, or an empty string if there is none.
+func (n *node) keyword() string {
+	if !n.isList() || len(n.list) == 0 || !n.list[0].isAtom() {
+		return ""
+	}
+	return n.list[0].atom
+}
+
+func (n *node) errorf(format string, args ...any) error {
+	return fmt.Errorf("wat: %d:%d: %s", n.line, n.col, fmt.Sprintf(format, args...))
+}
+
+// parse tokenizes and parses the given text into a sequence of s-expressions.
+func parse(text string) ([]*node, error) {
+	p := &parser{text: strings.TrimPrefix(text, byteOrderMark), line: 1, col: 1}
+	nodes := []*node{}
+	for {
+		err := p.skipSpace()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.text) {
+			return nodes, nil
+		}
+		n, err := p.parseNode()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+}
+
+type parser struct {
+	text string
+	pos  int
+	line int
+	col  int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("wat: %d:%d: %s", p.line, p.col, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) advance(n int) {
+	for i := 0; i < n && p.pos < len(p.text); i++ {
+		if p.text[p.pos] == '\n' {
+			p.line++
+			p.col = 1
+		} else {
+			p.col++
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) hasPrefix(prefix string) bool {
+	return len(p.text)-p.pos >= len(prefix) && p.text[p.pos:p.pos+len(prefix)] == prefix
+}
+
+// skipSpace skips any whitespace and comments.
+func (p *parser) skipSpace() error {
+	for p.pos < len(p.text) {
+		switch {
+		case p.text[p.pos] == ' ' || p.text[p.pos] == '\t' || p.text[p.pos] == '\n' || p.text[p.pos] == '\r':
+			p.advance(1)
+
+		case p.hasPrefix(";;"):
+			for p.pos < len(p.text) && p.text[p.pos] != '\n' {
+				p.advance(1)
+			}
+
+		case p.hasPrefix("(;"):
+			depth := 0
+			for {
+				if p.pos >= len(p.text) {
+					return p.errorf("unterminated block comment")
+				}
+				if p.hasPrefix("(;") {
+					depth++
+					p.advance(2)
+				} else if p.hasPrefix(";)") {
+					depth--
+					p.advance(2)
+					if depth == 0 {
+						break
+					}
+				} else {
+					p.advance(1)
+				}
+			}
+
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseNode() (*node, error) {
+	n := &node{line: p.line, col: p.col}
+
+	switch p.text[p.pos] {
+	case '(':
+		p.advance(1)
+		n.isListed = true
+		for {
+			err := p.skipSpace()
+			if err != nil {
+				return nil, err
+			}
+			if p.pos >= len(p.text) {
+				return nil, n.errorf("unclosed parenthesis")
+			}
+			if p.text[p.pos] == ')' {
+				p.advance(1)
+				return n, nil
+			}
+			child, err := p.parseNode()
+			if err != nil {
+				return nil, err
+			}
+			n.list = append(n.list, child)
+		}
+
+	case ')':
+		return nil, p.errorf("unexpected ')'")
+
+	case '"':
+		str, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		n.isString = true
+		n.str = str
+		return n, nil
+
+	default:
+		start := p.pos
+		for p.pos < len(p.text) && isAtomChar(p.text[p.pos]) {
+			p.advance(1)
+		}
+		if p.pos == start {
+			return nil, p.errorf("unexpected character %q", p.text[p.pos])
+		}
+		n.atom = p.text[start:p.pos]
+		return n, nil
+	}
+}
+
+func isAtomChar(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '(', ')', '"', ';':
+		return false
+	}
+	return c > ' ' && c < 0x7f
+}
+
+func (p *parser) parseString() ([]byte, error) {
+	// Unterminated strings are reported at their opening quote, rather than where the input ends.
+	start := *p
+	// Skip the opening quote.
+	p.advance(1)
+
+	var out []byte
+	for {
+		if p.pos >= len(p.text) || p.text[p.pos] == '\n' {
+			return nil, start.errorf("unterminated string")
+		}
+		c := p.text[p.pos]
+		switch c {
+		case '"':
+			p.advance(1)
+			return out, nil
+
+		case '\\':
+			if p.pos+1 >= len(p.text) {
+				return nil, start.errorf("unterminated string")
+			}
+			escape := p.text[p.pos+1]
+			switch escape {
+			case 'n':
+				out = append(out, '\n')
+				p.advance(2)
+			case 't':
+				out = append(out, '\t')
+				p.advance(2)
+			case 'r':
+				out = append(out, '\r')
+				p.advance(2)
+			case '"', '\'', '\\':
+				out = append(out, escape)
+				p.advance(2)
+			case 'u':
+				p.advance(2)
+				if !p.hasPrefix("{") {
+					return nil, p.errorf("invalid unicode escape")
+				}
+				p.advance(1)
+				var r rune
+				digits := 0
+				for p.pos < len(p.text) && p.text[p.pos] != '}' {
+					v, ok := hexValue(p.text[p.pos])
+					if !ok || r > utf8.MaxRune {
+						return nil, p.errorf("invalid unicode escape")
+					}
+					r = r*16 + rune(v)
+					digits++
+					p.advance(1)
+				}
+				if digits == 0 || p.pos >= len(p.text) || !utf8.ValidRune(r) {
+					return nil, p.errorf("invalid unicode escape")
+				}
+				p.advance(1)
+				out = utf8.AppendRune(out, r)
+			default:
+				if p.pos+2 >= len(p.text) {
+					return nil, p.errorf("invalid string escape")
+				}
+				hi, ok1 := hexValue(p.text[p.pos+1])
+				lo, ok2 := hexValue(p.text[p.pos+2])
+				if !ok1 || !ok2 {
+					return nil, p.errorf("invalid string escape")
+				}
+				out = append(out, hi<<4|lo)
+				p.advance(3)
+			}
+
+		default:
+			out = append(out, c)
+			p.advance(1)
+		}
+	}
+}
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
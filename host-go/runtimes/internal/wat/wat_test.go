@@ -0,0 +1,307 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !js
+
+package wat
+
+import (
+	"testing"
+
+	"github.com/bytecodealliance/wasmtime-go/v21"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// watCorpus holds modules exercising the text format supported by the converter, the binaries it
+// produces are compared with those produced by wasmtime.
+var watCorpus = map[string]string{
+	"empty": `(module)`,
+
+	"fields without module": `(func (export "f") (result i32) (i32.const 1))`,
+
+	"lens identity": `
+(module $lens
+  (import "lens" "next" (func $next (result i32)))
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  ;; A bump allocator, memory is never freed.
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $heap))
+    (global.set $heap (i32.add (local.get $ptr) (local.get $size)))
+    (local.get $ptr))
+
+  (func (export "transform") (result i32)
+    (call $next)))`,
+
+	"types and type uses": `
+(module
+  (type $binary (func (param i32 i32) (result i32)))
+  (type (func))
+  (func $add (type $binary) (i32.add (local.get 0) (local.get 1)))
+  (func $sub (type $binary) (param $a i32) (param $b i32) (result i32)
+    (i32.sub (local.get $a) (local.get $b)))
+  (func $noop (type 1))
+  (func (param i64 f32) (param $x f64) (result f64)
+    (local i32 i64) (local $y f64)
+    (local.get $x)))`,
+
+	"import forms": `
+(module
+  (import "env" "f" (func $f (param i32)))
+  (import "env" "mem" (memory 1 2))
+  (import "env" "g" (global $g i32))
+  (import "env" "gm" (global $gm (mut i64)))
+  (import "env" "table" (table 2 funcref))
+  (func $h (import "env" "h") (result i32))
+  (func (export "call") (call $f (call $h)) (drop (global.get $g))))`,
+
+	"export forms": `
+(module
+  (func $f (export "a") (export "b"))
+  (memory $m (export "memory") 1)
+  (global $g (export "global") i32 (i32.const 7))
+  (table $t (export "table") 1 funcref)
+  (export "c" (func $f))
+  (export "m2" (memory $m))
+  (export "g2" (global $g))
+  (export "t2" (table $t)))`,
+
+	"control flow": `
+(module
+  (func $control (param $n i32) (result i32)
+    (local $i i32)
+    (block $done
+      (loop $loop
+        (br_if $done (i32.ge_s (local.get $i) (local.get $n)))
+        (local.set $i (i32.add (local.get $i) (i32.const 1)))
+        (br $loop)))
+    (if (result i32) (i32.eqz (local.get $i))
+      (then (i32.const 0))
+      (else (local.get $i))))
+
+  (func $flat (param i32) (result i32)
+    block $outer (result i32)
+      block $inner
+        local.get 0
+        br_table $inner $outer 0
+      end
+      i32.const 1
+      return
+    end
+    local.get 0
+    if (result i32)
+      i32.const 2
+    else
+      i32.const 3
+    end
+    i32.add)
+
+  (func $select (param i32 i32 i32) (result i32)
+    (select (local.get 0) (local.get 1) (local.get 2)))
+
+  (func $typed_select (param f64 f64 i32) (result f64)
+    (select (result f64) (local.get 0) (local.get 1) (local.get 2)))
+
+  (func $misc
+    (nop)
+    (block (unreachable))
+    (loop (br 1))))`,
+
+	"numeric": `
+(module
+  (func (result i32)
+    (i32.const -1) (drop)
+    (i32.const 0x7fffffff) (drop)
+    (i32.const 0xffff_ffff) (drop)
+    (i32.extend8_s (i32.const 200)) (drop)
+    (i32.clz (i32.const 16)) (drop)
+    (i32.rotl (i32.const 1) (i32.const 3)) (drop)
+    (i32.wrap_i64 (i64.const -9223372036854775808)) (drop)
+    (i32.trunc_sat_f32_s (f32.const 1.5)) (drop)
+    (i32.reinterpret_f32 (f32.const -0x1.8p+1)) (drop)
+    (i32.const 42))
+
+  (func (result i64)
+    (i64.extend_i32_u (i32.const -1)) (drop)
+    (i64.extend32_s (i64.const 0xffffffff)) (drop)
+    (i64.trunc_sat_f64_u (f64.const 1e10)) (drop)
+    (i64.popcnt (i64.const 0x0f0f)) (drop)
+    (i64.const 18446744073709551615))
+
+  (func (result f32)
+    (f32.const inf) (drop)
+    (f32.const -nan) (drop)
+    (f32.const nan:0x200000) (drop)
+    (f32.demote_f64 (f64.const 3.141592653589793)) (drop)
+    (f32.copysign (f32.const 1) (f32.const -2)) (drop)
+    (f32.convert_i32_s (i32.const 7)))
+
+  (func (result f64)
+    (f64.const 0x1p-1074) (drop)
+    (f64.const 1.7976931348623157e308) (drop)
+    (f64.promote_f32 (f32.const 0.1)) (drop)
+    (f64.sqrt (f64.const 2)) (drop)
+    (f64.const -0)))`,
+
+	"memory": `
+(module
+  (memory 1 16)
+  (func (param $p i32)
+    (i32.store (local.get $p) (i32.load (local.get $p)))
+    (i32.store offset=4 align=2 (local.get $p) (i32.load8_u offset=1 (local.get $p)))
+    (i64.store32 (local.get $p) (i64.load16_s align=1 (local.get $p)))
+    (f64.store offset=0x10 (local.get $p) (f64.load (local.get $p)))
+    (drop (memory.grow (memory.size)))
+    (memory.copy (local.get $p) (i32.const 0) (i32.const 8))
+    (memory.fill (local.get $p) (i32.const 0) (i32.const 8))))`,
+
+	"data segments": `
+(module
+  (memory $mem 1)
+  (data (i32.const 0) "hello")
+  (data (offset (i32.const 16)) "\00\01\ff" "\n\t\"\\\u{41}")
+  (data $passive "passive")
+  (data (memory $mem) (i32.const 32) "explicit")
+  (func (param i32)
+    (memory.init $passive (local.get 0) (i32.const 0) (i32.const 4))
+    (data.drop $passive)))`,
+
+	"inline data": `
+(module
+  (memory (export "memory") (data "inline" "data")))`,
+
+	"tables and elem segments": `
+(module
+  (type $unary (func (param i32) (result i32)))
+  (table $t 4 funcref)
+  (func $double (type $unary) (i32.mul (local.get 0) (i32.const 2)))
+  (func $square (type $unary) (i32.mul (local.get 0) (local.get 0)))
+  (elem (i32.const 0) $double $square)
+  (elem (offset (i32.const 2)) func $square)
+  (func (param $i i32) (result i32)
+    (call_indirect (type $unary) (i32.const 3) (local.get $i))
+    (call_indirect $t (param i32) (result i32) (local.get $i))))`,
+
+	"inline table elem": `
+(module
+  (func $a)
+  (func $b)
+  (table funcref (elem $a $b $a)))`,
+
+	"start": `
+(module
+  (global $ready (mut i32) (i32.const 0))
+  (func $init (global.set $ready (i32.const 1)))
+  (start $init))`,
+
+	"globals": `
+(module
+  (import "env" "base" (global $base i32))
+  (global $a i32 (i32.const 1))
+  (global $b (mut f32) (f32.const 2.5))
+  (global $c i64 (global.get $base)))`,
+
+	"comments": `
+;; A line comment.
+(module (; A block (; nested ;) comment ;)
+  (func ;; trailing
+    (nop)))`,
+}
+
+func TestWat2WasmMatchesWasmtime(t *testing.T) {
+	for name, wat := range watCorpus {
+		t.Run(name, func(t *testing.T) {
+			expected, err := wasmtime.Wat2Wasm(wat)
+			require.NoError(t, err)
+
+			actual, err := Wat2Wasm(wat)
+			require.NoError(t, err)
+
+			assert.Equal(t, sections(t, expected), sections(t, actual))
+		})
+	}
+}
+
+func TestWat2WasmReturnsErrorPositions(t *testing.T) {
+	tests := map[string]struct {
+		wat      string
+		expected string
+	}{
+		"unknown instruction": {
+			wat:      "(module\n  (func (result i32)\n    (i32.foo)))",
+			expected: `wat: 3:5: unknown instruction "i32.foo"`,
+		},
+		"unknown label": {
+			wat:      "(module\n  (func\n    (br $missing)))",
+			expected: "wat: 3:9: unknown label $missing",
+		},
+		"unknown function": {
+			wat:      "(module (func (call $missing)))",
+			expected: "wat: 1:21: unknown func $missing",
+		},
+		"unterminated list": {
+			wat:      "(module (func)",
+			expected: "wat: 1:1: unclosed parenthesis",
+		},
+		"unterminated string": {
+			wat:      `(module (data "abc))`,
+			expected: "wat: 1:15: unterminated string",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Wat2Wasm(test.wat)
+			require.ErrorContains(t, err, test.expected)
+		})
+	}
+}
+
+func TestIsText(t *testing.T) {
+	assert.True(t, IsText([]byte("(module)")))
+	assert.True(t, IsText([]byte("\ufeff  ;; comment\n(module)")))
+	assert.False(t, IsText([]byte("\x00asm\x01\x00\x00\x00")))
+	assert.False(t, IsText([]byte("module")))
+	assert.False(t, IsText([]byte(" \ufeff(module)")))
+}
+
+func TestWat2WasmSkipsByteOrderMark(t *testing.T) {
+	wasm, err := Wat2Wasm("\ufeff(module)")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("\x00asm\x01\x00\x00\x00"), wasm)
+}
+
+// sections returns the non-custom sections of the given wasm binary, custom sections such as the
+// name section are not produced by the converter.
+func sections(t *testing.T, wasm []byte) map[byte][]byte {
+	require.GreaterOrEqual(t, len(wasm), 8)
+	result := map[byte][]byte{}
+	for rest := wasm[8:]; len(rest) > 0; {
+		id := rest[0]
+		size, n := readU32(t, rest[1:])
+		start := 1 + n
+		content := rest[start : start+int(size)]
+		if id != 0 {
+			result[id] = content
+		}
+		rest = rest[start+int(size):]
+	}
+	return result
+}
+
+func readU32(t *testing.T, data []byte) (uint32, int) {
+	var result uint32
+	for i, b := range data {
+		result |= uint32(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return result, i + 1
+		}
+	}
+	t.Fatal("invalid leb128")
+	return 0, 0
+}
@@ -17,6 +17,7 @@ import (
 
 	"github.com/lens-vm/lens/host-go/engine/module"
 	"github.com/lens-vm/lens/host-go/engine/pipes"
+	"github.com/lens-vm/lens/host-go/runtimes/internal/wat"
 )
 
 type wRuntime struct {
@@ -34,6 +35,14 @@ func New() module.Runtime {
 }
 
 func (rt *wRuntime) NewModule(wasmBytes []byte) (module.Module, error) {
+	if wat.IsText(wasmBytes) {
+		var err error
+		wasmBytes, err = wat.Wat2Wasm(string(wasmBytes))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Copy bytes from Go to a JavaScript Uint8Array
 	//
 	// https://developer.mozilla.org/en-US/docs/Web/JavaScript/Reference/Global_Objects/Uint8Array/Uint8Array
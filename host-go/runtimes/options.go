@@ -0,0 +1,66 @@
+package runtimes
+
+import (
+	"fmt"
+	"time"
+)
+
+// Option configures the runtimes returned by New and Default.
+type Option func(*options)
+
+type options struct {
+	cacheDir string
+	fuel     uint64
+	timeout  time.Duration
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithCacheDir enables the persistence of compiled module code in the given directory, if
+// supported by the runtime.
+//
+// This allows compilation to be skipped when the same module is loaded by a later process.
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		o.cacheDir = dir
+	}
+}
+
+// WithFuel limits the number of wasm instructions that the modules hosted by the runtime may
+// execute in total, calls exceeding it fail.
+//
+// Only the wasmtime runtime meters fuel, New returns an error if the option is given to any other.
+func WithFuel(fuel uint64) Option {
+	return func(o *options) {
+		o.fuel = fuel
+	}
+}
+
+// WithTimeout limits the duration of each call from the host into the modules hosted by the
+// runtime, such as a transform yielding an item, calls exceeding it are interrupted and fail.
+//
+// The wasmtime and wazero runtimes support timeouts, New returns an error if the option is given
+// to any other.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// checkLimits returns an error if the options declare a limit that the named runtime does not
+// support.
+func (o *options) checkLimits(name string, fuel bool, timeout bool) error {
+	if o.fuel > 0 && !fuel {
+		return fmt.Errorf("the %s runtime does not support fuel", name)
+	}
+	if o.timeout > 0 && !timeout {
+		return fmt.Errorf("the %s runtime does not support timeouts", name)
+	}
+	return nil
+}
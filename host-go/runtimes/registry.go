@@ -0,0 +1,73 @@
+package runtimes
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lens-vm/lens/host-go/engine/module"
+)
+
+// EnvRuntime is the environment variable that may be used to name the runtime returned by New
+// when no name is given.
+const EnvRuntime = "LENS_RUNTIME"
+
+// Factory creates a new runtime configured with the given options.
+//
+// An error should be returned if given an option that the runtime does not support, rather than
+// ignoring it.
+type Factory func(opts ...Option) (module.Runtime, error)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]Factory{}
+)
+
+// Register registers the given runtime factory against the given name, replacing any existing
+// factory registered against it.
+//
+// The runtimes supported by the target platform are registered by default, these are `wasmtime`
+// and `wazero` on most platforms. The `wasmer` runtime is also registered if built with the
+// `wasmer` tag, it is opt-in as it links against a shared library.
+func Register(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[name] = factory
+}
+
+// Names returns the sorted names of the registered runtimes.
+func Names() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns a new runtime of the type registered against the given name.
+//
+// If name is empty the runtime named by the LENS_RUNTIME environment variable will be returned,
+// falling back to the runtime returned by Default if that is also empty. An error is returned if
+// the runtime does not support any of the given options.
+func New(name string, opts ...Option) (module.Runtime, error) {
+	if name == "" {
+		name = os.Getenv(EnvRuntime)
+	}
+	if name == "" {
+		name = defaultName
+	}
+
+	registryMutex.RLock()
+	factory, ok := registry[name]
+	registryMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown runtime %q, available runtimes: %s", name, strings.Join(Names(), ", "))
+	}
+	return factory(opts...)
+}
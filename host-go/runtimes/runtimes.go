@@ -7,6 +7,32 @@ import (
 	"github.com/lens-vm/lens/host-go/runtimes/wasmtime"
 )
 
-func Default() module.Runtime {
-	return wasmtime.New()
+// defaultName is the name of the runtime returned by Default.
+const defaultName = "wasmtime"
+
+func init() {
+	Register("wasmtime", func(opts ...Option) (module.Runtime, error) {
+		return newWasmtime(opts...), nil
+	})
+	Register("wazero", newCheckedWazero)
+}
+
+// Default returns the wasmtime runtime.
+func Default(opts ...Option) module.Runtime {
+	return newWasmtime(opts...)
+}
+
+func newWasmtime(opts ...Option) module.Runtime {
+	o := newOptions(opts)
+	wasmtimeOpts := []wasmtime.Option{}
+	if o.cacheDir != "" {
+		wasmtimeOpts = append(wasmtimeOpts, wasmtime.WithCacheDir(o.cacheDir))
+	}
+	if o.fuel > 0 {
+		wasmtimeOpts = append(wasmtimeOpts, wasmtime.WithFuel(o.fuel))
+	}
+	if o.timeout > 0 {
+		wasmtimeOpts = append(wasmtimeOpts, wasmtime.WithTimeout(o.timeout))
+	}
+	return wasmtime.New(wasmtimeOpts...)
 }
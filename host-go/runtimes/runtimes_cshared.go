@@ -2,11 +2,16 @@
 
 package runtimes
 
-import (
-	"github.com/lens-vm/lens/host-go/engine/module"
-	"github.com/lens-vm/lens/host-go/runtimes/wazero"
-)
+import "github.com/lens-vm/lens/host-go/engine/module"
 
-func Default() module.Runtime {
-	return wazero.New()
+// defaultName is the name of the runtime returned by Default.
+const defaultName = "wazero"
+
+func init() {
+	Register("wazero", newCheckedWazero)
+}
+
+// Default returns the wazero runtime, fuel is not supported and is ignored.
+func Default(opts ...Option) module.Runtime {
+	return newWazero(opts...)
 }
@@ -5,6 +5,23 @@ import (
 	"github.com/lens-vm/lens/host-go/runtimes/js"
 )
 
-func Default() module.Runtime {
+// defaultName is the name of the runtime returned by Default.
+const defaultName = "js"
+
+func init() {
+	Register("js", func(opts ...Option) (module.Runtime, error) {
+		err := newOptions(opts).checkLimits("js", false, false)
+		if err != nil {
+			return nil, err
+		}
+		return js.New(), nil
+	})
+}
+
+// Default returns the browser's WebAssembly runtime.
+//
+// The options are accepted for parity with other platforms, compiled code caching is managed
+// by the browser and limits are not supported.
+func Default(opts ...Option) module.Runtime {
 	return js.New()
 }
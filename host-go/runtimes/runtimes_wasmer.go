@@ -0,0 +1,28 @@
+//go:build wasmer && !windows && !js && !cshared
+
+package runtimes
+
+import (
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/runtimes/wasmer"
+)
+
+// The wasmer runtime is opt-in, as binaries importing it are dynamically linked against the
+// wasmer shared library.
+func init() {
+	Register("wasmer", newWasmer)
+}
+
+func newWasmer(opts ...Option) (module.Runtime, error) {
+	o := newOptions(opts)
+	err := o.checkLimits("wasmer", false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	wasmerOpts := []wasmer.Option{}
+	if o.cacheDir != "" {
+		wasmerOpts = append(wasmerOpts, wasmer.WithCacheDir(o.cacheDir))
+	}
+	return wasmer.New(wasmerOpts...), nil
+}
@@ -14,22 +14,46 @@ import (
 
 	"github.com/lens-vm/lens/host-go/engine/module"
 	"github.com/lens-vm/lens/host-go/engine/pipes"
+	"github.com/lens-vm/lens/host-go/runtimes/internal/artifacts"
+	"github.com/lens-vm/lens/host-go/runtimes/internal/wat"
 
 	"github.com/wasmerio/wasmer-go/wasmer"
 )
 
+// wasmerModulePath is the Go module path of the wasmer bindings, its version is used to key
+// compiled code.
+const wasmerModulePath = "github.com/wasmerio/wasmer-go"
+
 type wRuntime struct {
-	store *wasmer.Store
+	store    *wasmer.Store
+	cacheDir string
 }
 
 var _ module.Runtime = (*wRuntime)(nil)
 
-func New() module.Runtime {
+// Option configures a wasmer runtime.
+type Option func(*wRuntime)
+
+// WithCacheDir enables the persistence of compiled module code in the given directory, allowing
+// compilation to be skipped when the same module is loaded by a later process.
+//
+// Compiled code is loaded without validation, the directory must not be writable by untrusted parties.
+func WithCacheDir(dir string) Option {
+	return func(rt *wRuntime) {
+		rt.cacheDir = dir
+	}
+}
+
+func New(opts ...Option) module.Runtime {
 	engine := wasmer.NewEngine()
 	store := wasmer.NewStore(engine)
-	return &wRuntime{
+	rt := &wRuntime{
 		store: store,
 	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return rt
 }
 
 type wModule struct {
@@ -40,7 +64,15 @@ type wModule struct {
 var _ module.Module = (*wModule)(nil)
 
 func (rt *wRuntime) NewModule(wasmBytes []byte) (module.Module, error) {
-	module, err := wasmer.NewModule(rt.store, wasmBytes)
+	if wat.IsText(wasmBytes) {
+		var err error
+		wasmBytes, err = wasmer.Wat2Wasm(string(wat.TrimBOM(wasmBytes)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	module, err := rt.compile(wasmBytes)
 	if err != nil {
 		return nil, err
 	}
@@ -51,6 +83,37 @@ func (rt *wRuntime) NewModule(wasmBytes []byte) (module.Module, error) {
 	}, nil
 }
 
+// compile compiles the given wasm binary, using the compiled code cache if one is configured.
+func (rt *wRuntime) compile(wasmBytes []byte) (*wasmer.Module, error) {
+	if rt.cacheDir == "" {
+		return wasmer.NewModule(rt.store, wasmBytes)
+	}
+
+	key := artifacts.Key(wasmBytes, artifacts.ModuleVersion(wasmerModulePath), "")
+	if data, ok := artifacts.Load(rt.cacheDir, key); ok {
+		module, err := wasmer.DeserializeModule(rt.store, data)
+		if err == nil {
+			return module, nil
+		}
+		// If the artifact is incompatible or corrupt we fall through and recompile it,
+		// replacing the bad artifact.
+	}
+
+	module, err := wasmer.NewModule(rt.store, wasmBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := module.Serialize()
+	if err == nil {
+		// The cache is only an optimization, failing to write to it should not prevent the
+		// module from being used.
+		_ = artifacts.Store(rt.cacheDir, key, data)
+	}
+
+	return module, nil
+}
+
 func (m *wModule) NewInstance(functionName string, paramSets ...map[string]any) (module.Instance, error) {
 	importObject := wasmer.NewImportObject()
 
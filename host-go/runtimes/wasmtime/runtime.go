@@ -12,26 +12,109 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"time"
 
 	"github.com/lens-vm/lens/host-go/engine/module"
 	"github.com/lens-vm/lens/host-go/engine/pipes"
+	"github.com/lens-vm/lens/host-go/runtimes/internal/artifacts"
+	"github.com/lens-vm/lens/host-go/runtimes/internal/wat"
 
 	"github.com/bytecodealliance/wasmtime-go/v21"
 )
 
+// wasmtimeModulePath is the Go module path of the wasmtime bindings, its version is used to
+// key compiled code.
+const wasmtimeModulePath = "github.com/bytecodealliance/wasmtime-go/v21"
+
 type wRuntime struct {
-	store *wasmtime.Store
+	store    *wasmtime.Store
+	cacheDir string
+	fuel     uint64
+	timeout  time.Duration
+
+	// calls is the number of calls into wasm currently in progress, calls made by a module
+	// pulling items from its source are nested within the call pulling items from it.
+	calls int
 }
 
 var _ module.Runtime = (*wRuntime)(nil)
 
-func New() module.Runtime {
-	engine := wasmtime.NewEngine()
-	store := wasmtime.NewStore(engine)
+// Option configures a wasmtime runtime.
+type Option func(*wRuntime)
+
+// WithCacheDir enables the persistence of compiled module code in the given directory, allowing
+// compilation to be skipped when the same module is loaded by a later process.
+//
+// Compiled code is loaded without validation, the directory must not be writable by untrusted parties.
+func WithCacheDir(dir string) Option {
+	return func(rt *wRuntime) {
+		rt.cacheDir = dir
+	}
+}
+
+// WithFuel limits the number of wasm instructions that the modules hosted by the runtime may
+// execute in total, calls exceeding it fail.
+func WithFuel(fuel uint64) Option {
+	return func(rt *wRuntime) {
+		rt.fuel = fuel
+	}
+}
+
+// WithTimeout limits the duration of each call from the host into the modules hosted by the
+// runtime, calls exceeding it are interrupted and fail.
+//
+// Calls nested within another call, such as a module pulling items from its source, are covered
+// by the timeout of the outermost call.
+func WithTimeout(timeout time.Duration) Option {
+	return func(rt *wRuntime) {
+		rt.timeout = timeout
+	}
+}
+
+// New creates a new wasmtime wasm runtime.
+func New(opts ...Option) module.Runtime {
+	rt := &wRuntime{}
+	for _, opt := range opts {
+		opt(rt)
+	}
+
+	config := wasmtime.NewConfig()
+	config.SetConsumeFuel(rt.fuel > 0)
+	config.SetEpochInterruption(rt.timeout > 0)
+
+	rt.store = wasmtime.NewStore(wasmtime.NewEngineWithConfig(config))
+	if rt.timeout > 0 {
+		// Instantiation is not subject to the timeout, but is still interrupted at the
+		// deadline, which would otherwise be the current epoch.
+		rt.store.SetEpochDeadline(math.MaxUint32)
+	}
+	if rt.fuel > 0 {
+		// SetFuel can only fail if fuel consumption is not enabled.
+		_ = rt.store.SetFuel(rt.fuel)
+	}
+	return rt
+}
+
+// configKey describes the configuration affecting the code compiled by the runtime.
+func (rt *wRuntime) configKey() string {
+	return fmt.Sprintf("fuel=%t,epoch=%t", rt.fuel > 0, rt.timeout > 0)
+}
 
-	return &wRuntime{
-		store: store,
+// call calls the given wasm function, interrupting it if it exceeds the timeout.
+func (rt *wRuntime) call(f *wasmtime.Func, args ...any) (any, error) {
+	if rt.timeout > 0 && rt.calls == 0 {
+		// The deadline is relative to the current epoch, which is only incremented by the
+		// timer, any earlier increments having been made by timers of previous calls.
+		rt.store.SetEpochDeadline(1)
+		timer := time.AfterFunc(rt.timeout, rt.store.Engine.IncrementEpoch)
+		defer timer.Stop()
 	}
+
+	rt.calls++
+	defer func() {
+		rt.calls--
+	}()
+	return f.Call(rt.store, args...)
 }
 
 type wModule struct {
@@ -42,7 +125,15 @@ type wModule struct {
 var _ module.Module = (*wModule)(nil)
 
 func (rt *wRuntime) NewModule(wasmBytes []byte) (module.Module, error) {
-	module, err := wasmtime.NewModule(rt.store.Engine, wasmBytes)
+	if wat.IsText(wasmBytes) {
+		var err error
+		wasmBytes, err = wasmtime.Wat2Wasm(string(wat.TrimBOM(wasmBytes)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	module, err := rt.compile(wasmBytes)
 	if err != nil {
 		return nil, err
 	}
@@ -53,6 +144,37 @@ func (rt *wRuntime) NewModule(wasmBytes []byte) (module.Module, error) {
 	}, nil
 }
 
+// compile compiles the given wasm binary, using the compiled code cache if one is configured.
+func (rt *wRuntime) compile(wasmBytes []byte) (*wasmtime.Module, error) {
+	if rt.cacheDir == "" {
+		return wasmtime.NewModule(rt.store.Engine, wasmBytes)
+	}
+
+	key := artifacts.Key(wasmBytes, artifacts.ModuleVersion(wasmtimeModulePath), rt.configKey())
+	if data, ok := artifacts.Load(rt.cacheDir, key); ok {
+		module, err := wasmtime.NewModuleDeserialize(rt.store.Engine, data)
+		if err == nil {
+			return module, nil
+		}
+		// If the artifact is incompatible or corrupt we fall through and recompile it,
+		// replacing the bad artifact.
+	}
+
+	module, err := wasmtime.NewModule(rt.store.Engine, wasmBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := module.Serialize()
+	if err == nil {
+		// The cache is only an optimization, failing to write to it should not prevent the
+		// module from being used.
+		_ = artifacts.Store(rt.cacheDir, key, data)
+	}
+
+	return module, nil
+}
+
 func (m *wModule) NewInstance(functionName string, paramSets ...map[string]any) (module.Instance, error) {
 	// We require a non-nil placeholder else Go will panic upon reassignment (nil pointer de-reference)
 	nextFunction := func() module.MemSize { return 0 }
@@ -108,7 +230,7 @@ func (m *wModule) NewInstance(functionName string, paramSets ...map[string]any)
 			return module.Instance{}, err
 		}
 
-		index, err := alloc.Call(m.rt.store, module.TypeIdSize+module.MemSize(len(sourceBytes))+module.LenSize)
+		index, err := m.rt.call(alloc, module.TypeIdSize+module.MemSize(len(sourceBytes))+module.LenSize)
 		if err != nil {
 			return module.Instance{}, err
 		}
@@ -121,7 +243,7 @@ func (m *wModule) NewInstance(functionName string, paramSets ...map[string]any)
 			return module.Instance{}, err
 		}
 
-		index, err = setParam.Call(m.rt.store, index)
+		index, err = m.rt.call(setParam, index)
 		if err != nil {
 			return module.Instance{}, err
 		}
@@ -140,7 +262,7 @@ func (m *wModule) NewInstance(functionName string, paramSets ...map[string]any)
 
 	return module.Instance{
 		Alloc: func(u module.MemSize) (module.MemSize, error) {
-			r, err := alloc.Call(m.rt.store, u)
+			r, err := m.rt.call(alloc, u)
 			if err != nil {
 				return 0, err
 			}
@@ -151,7 +273,7 @@ func (m *wModule) NewInstance(functionName string, paramSets ...map[string]any)
 			// pipeline stages to share the same wasm instance - provided they are not called concurrently.
 			// This also allows module state to be shared across pipeline stages.
 			nextFunction = next
-			r, err := transform.Call(m.rt.store)
+			r, err := m.rt.call(transform)
 			if err != nil {
 				return 0, err
 			}
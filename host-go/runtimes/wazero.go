@@ -0,0 +1,29 @@
+//go:build !js
+
+package runtimes
+
+import (
+	"github.com/lens-vm/lens/host-go/engine/module"
+	"github.com/lens-vm/lens/host-go/runtimes/wazero"
+)
+
+func newWazero(opts ...Option) module.Runtime {
+	o := newOptions(opts)
+	wazeroOpts := []wazero.Option{}
+	if o.cacheDir != "" {
+		wazeroOpts = append(wazeroOpts, wazero.WithCacheDir(o.cacheDir))
+	}
+	if o.timeout > 0 {
+		wazeroOpts = append(wazeroOpts, wazero.WithTimeout(o.timeout))
+	}
+	return wazero.New(wazeroOpts...)
+}
+
+// newCheckedWazero returns a new wazero runtime, failing if given fuel as it is not supported.
+func newCheckedWazero(opts ...Option) (module.Runtime, error) {
+	err := newOptions(opts).checkLimits("wazero", false, true)
+	if err != nil {
+		return nil, err
+	}
+	return newWazero(opts...), nil
+}
@@ -22,3 +22,7 @@ func (m *memory) WriteAt(src []byte, offset int64) (int, error) {
 	m.memory.Write(uint32(offset), src)
 	return len(src), nil
 }
+
+func (m *memory) Size() int64 {
+	return int64(m.memory.Size())
+}
@@ -13,46 +13,148 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"path/filepath"
+	"time"
 
 	"github.com/lens-vm/lens/host-go/engine/module"
 	"github.com/lens-vm/lens/host-go/engine/pipes"
+	"github.com/lens-vm/lens/host-go/runtimes/internal/artifacts"
+	"github.com/lens-vm/lens/host-go/runtimes/internal/wat"
 
 	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
 )
 
 type wRuntime struct {
 	compilationCache wazero.CompilationCache
+	calls            *limiter
 }
 
 var _ module.Runtime = (*wRuntime)(nil)
 
+// Option configures a wazero runtime.
+type Option func(*options)
+
+type options struct {
+	cacheDir string
+	timeout  time.Duration
+}
+
+// WithCacheDir enables the persistence of compiled module code in the given directory, allowing
+// compilation to be skipped when the same module is loaded by a later process.
+//
+// If the directory cannot be used, compiled code will only be cached in memory.
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		o.cacheDir = dir
+	}
+}
+
+// WithTimeout limits the duration of each call from the host into the modules hosted by the
+// runtime, calls exceeding it are interrupted and fail.
+//
+// Calls nested within another call, such as a module pulling items from its source, are covered
+// by the timeout of the outermost call.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
 // New creates a new wazero wasm runtime.
 //
 // WARNING: This runtime does not current allow for instance reuse within a single pipeline.
 // Please see https://github.com/lens-vm/lens/issues/71 for more info.
-func New() module.Runtime {
+func New(opts ...Option) module.Runtime {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	calls := &limiter{
+		timeout: o.timeout,
+	}
+
+	if o.cacheDir != "" {
+		// wazero keys its cache by its own version, but not by the features of the CPU that
+		// the code was compiled for, so we partition the directory by them.
+		dir := filepath.Join(o.cacheDir, artifacts.PlatformKey())
+		if o.timeout > 0 {
+			// Nor is it keyed by whether the code checks for interruption, which it only does
+			// if a timeout is set.
+			dir = filepath.Join(dir, "interruptible")
+		}
+		compilationCache, err := wazero.NewCompilationCacheWithDir(dir)
+		if err == nil {
+			return &wRuntime{
+				compilationCache: compilationCache,
+				calls:            calls,
+			}
+		}
+	}
+
 	return &wRuntime{
 		compilationCache: wazero.NewCompilationCache(),
+		calls:            calls,
 	}
 }
 
+// limiter bounds the duration of the calls into the modules of a runtime.
+type limiter struct {
+	timeout time.Duration
+	// ctx is the context of the outermost call in progress, shared by the calls nested within it.
+	ctx context.Context
+}
+
+// call calls the given wasm function, interrupting it if it exceeds the timeout.
+func (l *limiter) call(f api.Function, params ...uint64) ([]uint64, error) {
+	if l.ctx != nil {
+		return f.Call(l.ctx, params...)
+	}
+
+	ctx := context.TODO()
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+
+	l.ctx = ctx
+	defer func() {
+		l.ctx = nil
+	}()
+	return f.Call(ctx, params...)
+}
+
 type wModule struct {
 	compilationCache wazero.CompilationCache
+	calls            *limiter
 	moduleBytes      []byte
 }
 
 var _ module.Module = (*wModule)(nil)
 
 func (rt *wRuntime) NewModule(wasmBytes []byte) (module.Module, error) {
+	if wat.IsText(wasmBytes) {
+		var err error
+		wasmBytes, err = wat.Wat2Wasm(string(wasmBytes))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &wModule{
 		compilationCache: rt.compilationCache,
+		calls:            rt.calls,
 		moduleBytes:      wasmBytes,
 	}, nil
 }
 
 func (m *wModule) NewInstance(functionName string, paramSets ...map[string]any) (module.Instance, error) {
 	ctx := context.TODO()
-	runtimeConfig := wazero.NewRuntimeConfig().WithCompilationCache(m.compilationCache)
+	runtimeConfig := wazero.NewRuntimeConfig().
+		WithCompilationCache(m.compilationCache).
+		WithCloseOnContextDone(m.calls.timeout > 0)
 	runtime := wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
 
 	var nextFunction = func() module.MemSize { return 0 }
@@ -98,7 +200,7 @@ func (m *wModule) NewInstance(functionName string, paramSets ...map[string]any)
 
 	if len(params) > 0 {
 		setParam := instance.ExportedFunction("set_param")
-		if err != nil {
+		if setParam == nil {
 			return module.Instance{}, errors.New(fmt.Sprintf("Export `%s` does not exist", "set_param"))
 		}
 
@@ -107,7 +209,7 @@ func (m *wModule) NewInstance(functionName string, paramSets ...map[string]any)
 			return module.Instance{}, err
 		}
 
-		index, err := alloc.Call(ctx, uint64(module.TypeIdSize+module.MemSize(len(sourceBytes))+module.LenSize))
+		index, err := m.calls.call(alloc, uint64(module.TypeIdSize+module.MemSize(len(sourceBytes))+module.LenSize))
 		if err != nil {
 			return module.Instance{}, err
 		}
@@ -120,7 +222,7 @@ func (m *wModule) NewInstance(functionName string, paramSets ...map[string]any)
 			return module.Instance{}, err
 		}
 
-		index, err = setParam.Call(ctx, index[0])
+		index, err = m.calls.call(setParam, index[0])
 		if err != nil {
 			return module.Instance{}, err
 		}
@@ -139,7 +241,7 @@ func (m *wModule) NewInstance(functionName string, paramSets ...map[string]any)
 
 	return module.Instance{
 		Alloc: func(u module.MemSize) (module.MemSize, error) {
-			r, err := alloc.Call(ctx, uint64(u))
+			r, err := m.calls.call(alloc, uint64(u))
 			if err != nil {
 				return 0, err
 			}
@@ -150,7 +252,7 @@ func (m *wModule) NewInstance(functionName string, paramSets ...map[string]any)
 			// pipeline stages to share the same wasm instance - provided they are not called concurrently.
 			// This also allows module state to be shared across pipeline stages.
 			nextFunction = next
-			r, err := transform.Call(ctx)
+			r, err := m.calls.call(transform)
 			if err != nil {
 				return 0, err
 			}
@@ -0,0 +1,127 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package integration
+
+import (
+	"testing"
+)
+
+const renameLensFile = `
+{
+	"lenses": [
+		{
+			"path": "builtin:rename",
+			"arguments": {
+				"src": "Name",
+				"dst": "FullName"
+			}
+		}
+	]
+}`
+
+func TestRunCommandWithNDJSON(t *testing.T) {
+	executeCommandTest(
+		t,
+		CommandTestCase{
+			Args:           []string{"run", "-input-format", "ndjson", "-output-format", "ndjson", "<lensfile>"},
+			LensFile:       renameLensFile,
+			Stdin:          "{\"Name\": \"John\"}\n{\"Name\": \"Fred\"}\n",
+			ExpectedStdout: "{\"FullName\":\"John\"}\n{\"FullName\":\"Fred\"}\n",
+		},
+	)
+}
+
+func TestRunCommandWithArgumentOverride(t *testing.T) {
+	executeCommandTest(
+		t,
+		CommandTestCase{
+			Args:           []string{"run", "-set", "lens[0].arguments.dst=Alias", "<lensfile>"},
+			LensFile:       renameLensFile,
+			Stdin:          `[{"Name": "John"}]`,
+			ExpectedStdout: `[{"Alias":"John"}]`,
+		},
+	)
+}
+
+func TestRunCommandReturnsTransformErrorAsJson(t *testing.T) {
+	executeCommandTest(
+		t,
+		CommandTestCase{
+			Args:             []string{"run", "-errors", "json", "<lensfile>"},
+			LensFile:         renameLensFile,
+			Stdin:            `[{"Name": "John"}, {"Age": 3}]`,
+			ExpectedExitCode: 5,
+			ExpectedStdout:   `[{"FullName":"John"}`,
+			ExpectedStderr: `{"stage":"transform","lens":0,"module":"builtin:rename","itemsRead":2,` +
+				`"message":"property not found: Name"}`,
+		},
+	)
+}
+
+func TestRunCommandReturnsInputError(t *testing.T) {
+	executeCommandTest(
+		t,
+		CommandTestCase{
+			Args:             []string{"run", "<lensfile>"},
+			LensFile:         renameLensFile,
+			Stdin:            `[{"Name": "John"}] {}`,
+			ExpectedExitCode: 6,
+			ExpectedStdout:   `[{"FullName":"John"}`,
+			ExpectedStderr:   "reading input item 1: unexpected content after the json array",
+		},
+	)
+}
+
+func TestValidateCommand(t *testing.T) {
+	executeCommandTest(
+		t,
+		CommandTestCase{
+			Args:           []string{"validate", "<lensfile>"},
+			LensFile:       renameLensFile,
+			ExpectedStdout: "<lensfile> is valid\n",
+		},
+	)
+}
+
+func TestValidateCommandReturnsModuleError(t *testing.T) {
+	executeCommandTest(
+		t,
+		CommandTestCase{
+			Args:             []string{"validate", "<lensfile>"},
+			LensFile:         `{"lenses": [{"path": "builtin:unknown"}]}`,
+			ExpectedExitCode: 4,
+			ExpectedStderr:   `lenses[0] (builtin:unknown): unknown builtin module "unknown"`,
+		},
+	)
+}
+
+func TestInvertCommand(t *testing.T) {
+	executeCommandTest(
+		t,
+		CommandTestCase{
+			Args:     []string{"invert", "-format", "yaml", "<lensfile>"},
+			LensFile: renameLensFile,
+			ExpectedStdout: `lenses:
+    - arguments:
+        dst: FullName
+        src: Name
+      inverse: true
+      path: builtin:rename
+`,
+		},
+	)
+}
+
+func TestCommandReturnsUsageError(t *testing.T) {
+	executeCommandTest(
+		t,
+		CommandTestCase{
+			Args:             []string{"run", "-fuel", "1000", "-runtime", "wazero", "<lensfile>"},
+			LensFile:         renameLensFile,
+			ExpectedExitCode: 2,
+			ExpectedStderr:   "the wazero runtime does not support -fuel, use wasmtime",
+		},
+	)
+}
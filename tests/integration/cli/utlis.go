@@ -32,6 +32,28 @@ type TestCase[TSource any, TResult any] struct {
 	ExpectedError string
 }
 
+// CommandTestCase is a test case running a subcommand of the host executable.
+type CommandTestCase struct {
+	// The arguments given to the host executable, "<lensfile>" is replaced by the path of the
+	// LensFile.
+	Args []string
+
+	// The LensFile contents to test as a string, if any
+	LensFile string
+
+	// The content written to stdin
+	Stdin string
+
+	// The exit code the host executable is expected to return
+	ExpectedExitCode int
+
+	// The content expected to be written to stdout
+	ExpectedStdout string
+
+	// Text that the content written to stderr is expected to contain
+	ExpectedStderr string
+}
+
 var hostExecutablePaths = []string{
 	getPathRelativeToProjectRoot(
 		"/host-go/build/host-go.exe",
@@ -125,3 +147,39 @@ func assertError(t *testing.T, expectedError string, err error, stderr bytes.Buf
 	t.Fatal(err)
 	return false
 }
+
+func executeCommandTest(t *testing.T, testCase CommandTestCase) {
+	lensFilePath := path.Join(t.TempDir(), "lensFile.json")
+	if testCase.LensFile != "" {
+		err := os.WriteFile(lensFilePath, []byte(testCase.LensFile), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args := make([]string, len(testCase.Args))
+	for i, arg := range testCase.Args {
+		args[i] = strings.ReplaceAll(arg, "<lensfile>", lensFilePath)
+	}
+
+	for _, hostPath := range hostExecutablePaths {
+		command := exec.Command(hostPath, args...)
+		command.Stdin = strings.NewReader(testCase.Stdin)
+
+		var stdout, stderr bytes.Buffer
+		command.Stdout = &stdout
+		command.Stderr = &stderr
+
+		exitCode := 0
+		err := command.Run()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, testCase.ExpectedExitCode, exitCode, stderr.String())
+		assert.Equal(t, strings.ReplaceAll(testCase.ExpectedStdout, "<lensfile>", lensFilePath), stdout.String())
+		assert.Contains(t, stderr.String(), testCase.ExpectedStderr)
+	}
+}